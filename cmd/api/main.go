@@ -1,11 +1,40 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	schema "github.com/SlavaShagalov/ds-lab1/db"
+	"github.com/SlavaShagalov/ds-lab1/internal/health"
+	healthDelivery "github.com/SlavaShagalov/ds-lab1/internal/health/delivery/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/idempotency"
+	idempotencyStdRepository "github.com/SlavaShagalov/ds-lab1/internal/idempotency/repository/std"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
 	personsDelivery "github.com/SlavaShagalov/ds-lab1/internal/persons/delivery/http"
-	personsRepository "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/pgx"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/outbox"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/purge"
+	personsCache "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/cache"
+	personsMetrics "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/metrics"
+	personsPgxRepository "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/pgx"
+	personsStdRepository "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/std"
+	personsTracing "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/tracing"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/stream"
 	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/cursor"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/lifecycle"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/migrate"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/resp"
 	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/tracing"
+	"github.com/SlavaShagalov/ds-lab1/internal/ratelimit"
+	rateLimitStdRepository "github.com/SlavaShagalov/ds-lab1/internal/ratelimit/repository/std"
+	webhooksDelivery "github.com/SlavaShagalov/ds-lab1/internal/webhooks/delivery/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/webhooks/dispatch"
+	webhooksStdRepository "github.com/SlavaShagalov/ds-lab1/internal/webhooks/repository/std"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"log"
@@ -33,6 +62,16 @@ import (
 //	@BasePath					/api/v1
 func main() {
 	// ===== Configuration =====
+	config.SetDefaultServerConfig()
+	config.SetDefaultPaginationConfig()
+	config.SetDefaultPurgeConfig()
+	config.SetDefaultOutboxConfig()
+	config.SetDefaultWebhookConfig()
+	config.SetDefaultCacheConfig()
+	config.SetDefaultIdempotencyConfig()
+	config.SetDefaultRateLimitConfig()
+	config.SetDefaultTracingConfig()
+	config.SetDefaultStreamConfig()
 	config.SetDefaultPostgresConfig()
 	viper.SetConfigName("api")
 	viper.SetConfigType("yaml")
@@ -46,46 +85,397 @@ func main() {
 
 	// ===== Logger =====
 	logger := pLog.NewDevelopLogger()
-	defer func() {
-		err = logger.Sync()
-		if err != nil {
-			log.Println(err)
-		}
-	}()
 	logger.Info("API service starting...")
 
+	// ===== Tracing =====
+	var exporter tracing.Exporter
+	service := viper.GetString(config.TracingServiceName)
+	switch name := viper.GetString(config.TracingExporter); name {
+	case tracing.ExporterStdout:
+		exporter = tracing.NewWriterExporter(os.Stdout, service)
+	case tracing.ExporterFile:
+		exporter, err = tracing.NewFileExporter(viper.GetString(config.TracingFile), service)
+	case tracing.ExporterOTLP:
+		exporter = tracing.NewOTLPExporter(viper.GetString(config.TracingOTLPEndpoint), service,
+			viper.GetDuration(config.TracingOTLPTimeout))
+	case tracing.ExporterNone:
+	default:
+		err = fmt.Errorf("unknown tracing exporter %q", name)
+	}
+	if err != nil {
+		logger.Error("Failed to set up tracing", zap.Error(err))
+		_ = logger.Sync()
+		os.Exit(1)
+	}
+	// Without an exporter nothing is traced, not even the traceparent of requests is passed on.
+	var (
+		tracer      *tracing.Tracer
+		queryTracer pgx.QueryTracer
+		transport   http.RoundTripper
+	)
+	if exporter != nil {
+		tracer = tracing.NewTracer(exporter, viper.GetFloat64(config.TracingSampleRatio), logger)
+		queryTracer = tracing.NewQueryTracer(tracer)
+		transport = tracing.NewTransport(tracer, nil)
+	}
+
 	// ===== Data Storage =====
-	db, err := postgres.NewStd(logger)
+	var db *sql.DB
+	var pool *pgxpool.Pool
+	switch driver := viper.GetString(config.PostgresDriver); driver {
+	case postgres.DriverPgx:
+		pool, err = postgres.NewPgx(logger, queryTracer)
+		if err == nil {
+			db = postgres.NewStdFromPgx(pool)
+		}
+	case postgres.DriverStd:
+		db, err = postgres.NewStd(logger)
+		if tracer != nil {
+			logger.Warn("Queries are not traced with the std Postgres driver, use pgx to trace them",
+				zap.String("driver", driver))
+		}
+	default:
+		err = fmt.Errorf("unknown Postgres driver %q", driver)
+		logger.Error("Failed to connect to Postgres", zap.Error(err))
+	}
 	if err != nil {
+		_ = logger.Sync()
 		os.Exit(1)
 	}
-	defer func() {
-		db.Close()
-		logger.Info("Postgres connection closed")
-	}()
 
-	personsRepo := personsRepository.New(db, logger)
+	// ===== Migrations =====
+	migrations, err := migrate.Load(schema.Migrations, schema.MigrationsDir)
+	if err != nil {
+		logger.Error("Failed to load migrations", zap.Error(err))
+		_ = logger.Sync()
+		os.Exit(1)
+	}
+	migrator := migrate.New(db, migrations, logger)
+	if viper.GetBool(config.PostgresMigrateOnStart) {
+		if err = migrator.Up(context.Background()); err != nil {
+			logger.Error("Failed to apply migrations", zap.Error(err))
+			_ = logger.Sync()
+			os.Exit(1)
+		}
+	}
+
+	// ===== Metrics =====
+	registry := metrics.NewRegistry()
+	registry.MustRegister(metrics.NewGoCollector(), metrics.NewDBStatsCollector(db, "postgres"))
+	if pool != nil {
+		registry.MustRegister(metrics.NewPgxPoolStatsCollector(pool, "postgres"))
+	}
+
+	var personsRepo pPersons.Repository
+	if pool != nil {
+		personsRepo = personsPgxRepository.New(pool, logger)
+	} else {
+		personsRepo = personsStdRepository.New(db, logger)
+	}
+	personsRepo = personsMetrics.New(personsRepo, registry)
+
+	// ===== Cache =====
+	var (
+		redis        *resp.Client
+		cacheBackend personsCache.Backend
+	)
+	switch backend := viper.GetString(config.CacheBackend); backend {
+	case personsCache.BackendMemory:
+		cacheBackend = personsCache.NewMemory(viper.GetInt(config.CacheSize))
+	case personsCache.BackendRedis:
+		redis = resp.NewClient(resp.Config{
+			Addr:     viper.GetString(config.CacheRedisAddr),
+			Password: viper.GetString(config.CacheRedisPassword),
+			DB:       viper.GetInt(config.CacheRedisDB),
+			PoolSize: viper.GetInt(config.CacheRedisPoolSize),
+			Timeout:  viper.GetDuration(config.CacheRedisTimeout),
+		})
+		cacheBackend = personsCache.NewRedis(redis)
+	case personsCache.BackendNone:
+	default:
+		logger.Error("Failed to set up cache", zap.Error(fmt.Errorf("unknown cache backend %q", backend)))
+		_ = logger.Sync()
+		os.Exit(1)
+	}
+	if cacheBackend != nil {
+		cached := personsCache.New(personsRepo, cacheBackend, viper.GetDuration(config.CacheTTL), logger)
+		registry.MustRegister(cached.Collectors()...)
+		personsRepo = cached
+	}
+	if tracer != nil {
+		personsRepo = personsTracing.New(personsRepo, tracer)
+	}
+	webhooksRepo := webhooksStdRepository.New(db, logger)
+	idempotencyKeys := idempotencyStdRepository.New(db, logger)
 
 	accessLog := mw.NewAccessLog(logger)
 	cors := mw.NewCors()
+	requestInfo := mw.NewRequestInfo(logger)
+	idempotent := mw.NewIdempotency(idempotencyKeys,
+		viper.GetDuration(config.IdempotencyLockTimeout),
+		viper.GetDuration(config.IdempotencyTTL),
+		logger)
+
+	// ===== Rate limiting =====
+	var rateLimitStore ratelimit.Store
+	switch store := viper.GetString(config.RateLimitStore); store {
+	case ratelimit.StoreMemory:
+		rateLimitStore = ratelimit.NewMemory()
+	case ratelimit.StorePostgres:
+		rateLimitStore = rateLimitStdRepository.New(db, logger)
+	default:
+		err = fmt.Errorf("unknown rate limit store %q", store)
+	}
+	rateLimitConfig := mw.RateLimitConfig{
+		APIKeyHeader:   viper.GetString(config.RateLimitAPIKeyHeader),
+		TrustedProxies: viper.GetInt(config.RateLimitTrustedProxies),
+	}
+	if err == nil {
+		err = viper.UnmarshalKey(config.RateLimitRules, &rateLimitConfig.Rules)
+	}
+	var rateLimit func(handler http.Handler) http.Handler
+	if err == nil {
+		rateLimit, err = mw.NewRateLimit(rateLimitStore, rateLimitConfig, logger)
+	}
+	if err != nil {
+		logger.Error("Failed to set up rate limiting", zap.Error(err))
+		_ = logger.Sync()
+		os.Exit(1)
+	}
 
 	router := mux.NewRouter()
+	if tracer != nil {
+		router.Use(mw.TraceRoute)
+	}
+	router.Use(mw.NewMetrics(registry))
+	// Probes and scrapes are not rate limited, so that busy clients sharing their address do not make
+	// the service look down. They are registered first, as routes are matched in order.
+	router.Handle("/metrics", registry).Methods(http.MethodGet)
+	healthDelivery.RegisterHandlers(router, []health.Dependency{
+		{Name: "postgres", Checker: personsRepo},
+		{Name: "migrations", Checker: migrator},
+	}, logger)
+	api := router.NewRoute().Subrouter()
+	api.Use(rateLimit)
 
 	// ===== Delivery =====
-	personsDelivery.RegisterHandlers(router, personsRepo, logger)
+	cursorSecret := []byte(viper.GetString(config.PaginationCursorSecret))
+	if len(cursorSecret) == 0 {
+		cursorSecret = cursor.NewRandomSecret()
+		logger.Warn("CURSOR_SECRET is not set, cursors are signed with a random key and are only valid " +
+			"on this instance until it restarts")
+	}
+	cursors := cursor.NewCodec(cursorSecret)
+	hub := stream.NewHub(viper.GetInt(config.StreamReplaySize))
+	personsDelivery.RegisterHandlers(api, personsRepo, cursors, hub, idempotent,
+		viper.GetString(config.ServerAdminToken), logger)
+	webhooksDelivery.RegisterHandlers(api, webhooksRepo, cursors, viper.GetString(config.ServerAdminToken), logger)
+
+	// ===== Purge =====
+	purgeJob, err := purge.New(personsRepo,
+		viper.GetDuration(config.PurgeRetention),
+		viper.GetDuration(config.PurgeInterval),
+		viper.GetInt64(config.PurgeBatchSize),
+		logger)
+	if err != nil {
+		logger.Error("Failed to set up purging", zap.Error(err))
+		_ = logger.Sync()
+		os.Exit(1)
+	}
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		purgeJob.Run(purgeCtx)
+	}()
+
+	// ===== Rate limit buckets =====
+	rateLimitSweeper := ratelimit.NewSweeper(rateLimitStore,
+		viper.GetDuration(config.RateLimitIdleTimeout),
+		viper.GetDuration(config.RateLimitSweepInterval),
+		logger)
+	rateLimitCtx, stopRateLimitSweeper := context.WithCancel(context.Background())
+	rateLimitDone := make(chan struct{})
+	go func() {
+		defer close(rateLimitDone)
+		rateLimitSweeper.Run(rateLimitCtx)
+	}()
+
+	// ===== Idempotency =====
+	sweeper := idempotency.NewSweeper(idempotencyKeys,
+		viper.GetDuration(config.IdempotencySweepInterval),
+		viper.GetInt64(config.IdempotencySweepBatchSize),
+		logger)
+	sweeperCtx, stopSweeper := context.WithCancel(context.Background())
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		sweeper.Run(sweeperCtx)
+	}()
+
+	// ===== Outbox =====
+	publishers := outbox.Fanout{dispatch.NewDispatcher(webhooksRepo, logger)}
+	if webhookURL := viper.GetString(config.OutboxWebhookURL); webhookURL != "" {
+		publishers = append(publishers,
+			outbox.NewWebhook(webhookURL, viper.GetDuration(config.OutboxWebhookTimeout), transport))
+	}
+	relay := outbox.New(personsRepo, publishers,
+		outbox.Config{
+			Interval:   viper.GetDuration(config.OutboxPollInterval),
+			BatchSize:  viper.GetInt64(config.OutboxBatchSize),
+			Lease:      viper.GetDuration(config.OutboxLease),
+			MaxBackoff: viper.GetDuration(config.OutboxMaxBackoff),
+		},
+		logger)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+
+	// ===== Webhooks =====
+	var webhookTransport http.RoundTripper = dispatch.PublicTransport()
+	if viper.GetBool(config.WebhookAllowPrivateNetworks) {
+		webhookTransport = http.DefaultTransport
+	}
+	if tracer != nil {
+		webhookTransport = tracing.NewTransport(tracer, webhookTransport)
+	}
+	sender := dispatch.NewSender(webhooksRepo,
+		dispatch.Config{
+			Interval:    viper.GetDuration(config.WebhookPollInterval),
+			BatchSize:   viper.GetInt64(config.WebhookBatchSize),
+			Lease:       viper.GetDuration(config.WebhookLease),
+			Timeout:     viper.GetDuration(config.WebhookTimeout),
+			MinBackoff:  viper.GetDuration(config.WebhookMinBackoff),
+			MaxBackoff:  viper.GetDuration(config.WebhookMaxBackoff),
+			MaxAttempts: viper.GetInt(config.WebhookMaxAttempts),
+			Transport:   webhookTransport,
+		},
+		logger)
+	senderCtx, stopSender := context.WithCancel(context.Background())
+	senderDone := make(chan struct{})
+	go func() {
+		defer close(senderDone)
+		sender.Run(senderCtx)
+	}()
+
+	// ===== Stream =====
+	listener := stream.NewListener(postgres.NewPgxConn, hub, logger)
+	listenerCtx, stopListener := context.WithCancel(context.Background())
+	listenerDone := make(chan struct{})
+	go func() {
+		defer close(listenerDone)
+		listener.Run(listenerCtx)
+	}()
 
 	// ===== Swagger =====
 	//router.PathPrefix(constants.ApiPrefix + "/swagger/").Handler(httpSwagger.WrapHandler).Methods(http.MethodGet)
 
 	// ===== Router =====
+	handler := requestInfo(accessLog(cors(router)))
+	if tracer != nil {
+		handler = mw.NewTracing(tracer)(handler)
+	}
 	server := http.Server{
 		Addr:    ":" + viper.GetString(config.ServerPort),
-		Handler: accessLog(cors(router)),
+		Handler: handler,
 	}
+	// Change feeds never finish on their own, so they are ended for the server to shut down.
+	server.RegisterOnShutdown(hub.Close)
+
+	// ===== Lifecycle =====
+	lc := lifecycle.New(&server, viper.GetDuration(config.ServerShutdownTimeout), logger)
+	lc.OnStop("purge", func(ctx context.Context) error {
+		stopPurge()
+		select {
+		case <-purgeDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	lc.OnStop("rate limit", func(ctx context.Context) error {
+		stopRateLimitSweeper()
+		select {
+		case <-rateLimitDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	lc.OnStop("idempotency", func(ctx context.Context) error {
+		stopSweeper()
+		select {
+		case <-sweeperDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	lc.OnStop("outbox", func(ctx context.Context) error {
+		stopRelay()
+		select {
+		case <-relayDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	lc.OnStop("webhooks", func(ctx context.Context) error {
+		stopSender()
+		select {
+		case <-senderDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	lc.OnStop("stream", func(ctx context.Context) error {
+		stopListener()
+		select {
+		case <-listenerDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	lc.OnStop("cache", func(ctx context.Context) error {
+		if redis != nil {
+			return redis.Close()
+		}
+		return nil
+	})
+	lc.OnStop("tracing", func(ctx context.Context) error {
+		if tracer != nil {
+			return tracer.Shutdown(ctx)
+		}
+		return nil
+	})
+	lc.OnStop("postgres", func(ctx context.Context) error {
+		err := db.Close()
+		if pool != nil {
+			pool.Close()
+		}
+		if err == nil {
+			logger.Info("Postgres connection closed")
+		}
+		return err
+	})
+	lc.OnStop("logger", func(ctx context.Context) error {
+		err := logger.Sync()
+		if err != nil {
+			log.Println(err)
+		}
+		return nil
+	})
 
 	// ===== Start =====
 	logger.Info("API service started", zap.String("port", viper.GetString(config.ServerPort)))
-	if err = server.ListenAndServe(); err != nil {
-		logger.Error("API server stopped", zap.Error(err))
+	if err = lc.Run(context.Background()); err != nil {
+		log.Printf("API service stopped: %v\n", err)
+		os.Exit(1)
 	}
+	log.Printf("API service stopped")
 }
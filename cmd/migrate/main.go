@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/SlavaShagalov/ds-lab1/db"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	pLog "github.com/SlavaShagalov/ds-lab1/internal/pkg/log/prod"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/migrate"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  up            apply all pending migrations
+  down          roll back the last applied migration
+  status        print applied and pending migrations
+  goto VERSION  migrate up or down to VERSION (0 rolls back everything)
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// ===== Configuration =====
+	config.SetDefaultPostgresConfig()
+	viper.SetConfigName("api")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("/configs")
+	err := viper.ReadInConfig()
+	if err != nil {
+		log.Printf("Failed to read configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// ===== Logger =====
+	logger := pLog.NewDevelopLogger()
+
+	// ===== Data Storage =====
+	sqlDB, err := postgres.NewStd(logger)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	migrations, err := migrate.Load(db.Migrations, db.MigrationsDir)
+	if err != nil {
+		log.Printf("Failed to load migrations: %v\n", err)
+		os.Exit(1)
+	}
+	migrator := migrate.New(sqlDB, migrations, logger)
+
+	err = run(context.Background(), migrator, os.Args[1:])
+	_ = sqlDB.Close()
+	_ = logger.Sync()
+	if err != nil {
+		log.Printf("Migration failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "goto":
+		if len(args) < 2 {
+			return fmt.Errorf("goto requires a version")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad version %q: %w", args[1], err)
+		}
+		return migrator.Goto(ctx, version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func printStatus(statuses []migrate.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/importer"
+	personsStdRepository "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/std"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	pLog "github.com/SlavaShagalov/ds-lab1/internal/pkg/log/prod"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+// actor is recorded in the audit trail of the persons changed by the CLI.
+const actor = "persons-cli"
+
+const usage = `Usage: persons <command>
+
+Commands:
+  import [-format csv|ndjson] [-dry-run] FILE
+                load persons from a CSV or NDJSON file, - reads standard input;
+                the report is printed as JSON and the exit status is 1 if any row failed
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// ===== Configuration =====
+	config.SetDefaultPostgresConfig()
+	viper.SetConfigName("api")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("/configs")
+	err := viper.ReadInConfig()
+	if err != nil {
+		log.Printf("Failed to read configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// ===== Logger =====
+	logger := pLog.NewDevelopLogger()
+
+	// ===== Data Storage =====
+	db, err := postgres.NewStd(logger)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	ctx := requestinfo.WithActor(context.Background(), actor)
+	err = run(ctx, importer.New(personsStdRepository.New(db, logger), logger), os.Args[1:])
+	_ = db.Close()
+	_ = logger.Sync()
+	if err != nil {
+		log.Printf("Command failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, imp *importer.Importer, args []string) error {
+	switch args[0] {
+	case "import":
+		return runImport(ctx, imp, args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func runImport(ctx context.Context, imp *importer.Importer, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "csv or ndjson, detected from the file extension by default")
+	dryRun := flags.Bool("dry-run", false, "validate the file without importing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("import requires a file")
+	}
+	filename := flags.Arg(0)
+
+	opts := importer.Options{
+		Format: importer.Format(*format),
+		DryRun: *dryRun,
+	}
+	if opts.Format == "" {
+		var ok bool
+		opts.Format, ok = importer.DetectFormat("", filename)
+		if !ok {
+			return fmt.Errorf("can't detect the format of %q, pass -format", filename)
+		}
+	}
+
+	var file io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file = f
+	}
+
+	report, err := imp.Import(ctx, file, opts)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(report); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", report.Failed, report.Rows)
+	}
+	return nil
+}
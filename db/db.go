@@ -0,0 +1,12 @@
+package db
+
+import (
+	"embed"
+)
+
+// Migrations holds the versioned schema files applied by internal/pkg/migrate.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+const MigrationsDir = "migrations"
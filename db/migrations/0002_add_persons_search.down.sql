@@ -0,0 +1,4 @@
+drop index if exists persons_search_idx;
+
+alter table persons
+    drop column if exists search;
@@ -0,0 +1,9 @@
+alter table persons
+    add column if not exists search tsvector
+        generated always as (
+            setweight(to_tsvector('simple', name), 'A') ||
+            setweight(to_tsvector('simple', work), 'B') ||
+            setweight(to_tsvector('simple', address), 'C')
+        ) stored;
+
+create index if not exists persons_search_idx on persons using gin (search);
@@ -0,0 +1,2 @@
+alter table persons
+    add column if not exists version bigint not null default 1;
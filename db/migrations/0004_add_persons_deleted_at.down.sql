@@ -0,0 +1,4 @@
+drop index if exists persons_deleted_at_idx;
+
+alter table persons
+    drop column if exists deleted_at;
@@ -0,0 +1,3 @@
+drop table if exists person_audit;
+
+drop function if exists person_audit_append_only();
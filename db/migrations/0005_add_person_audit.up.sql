@@ -0,0 +1,27 @@
+create table if not exists person_audit
+(
+    id         bigserial primary key,
+    person_id  bigint      not null,
+    operation  text        not null,
+    before     jsonb,
+    after      jsonb,
+    actor      text        not null,
+    request_id text        not null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists person_audit_person_id_idx on person_audit (person_id, id);
+
+create or replace function person_audit_append_only() returns trigger as
+$$
+begin
+    raise exception 'person_audit is append-only';
+end;
+$$ language plpgsql;
+
+drop trigger if exists person_audit_append_only on person_audit;
+create trigger person_audit_append_only
+    before update or delete
+    on person_audit
+    for each row
+execute function person_audit_append_only();
@@ -0,0 +1,14 @@
+create table if not exists outbox
+(
+    id              bigserial primary key,
+    event_type      text        not null,
+    person_id       bigint      not null,
+    payload         jsonb       not null,
+    attempts        integer     not null default 0,
+    last_error      text,
+    next_attempt_at timestamptz not null default now(),
+    created_at      timestamptz not null default now(),
+    published_at    timestamptz
+);
+
+create index if not exists outbox_pending_idx on outbox (next_attempt_at, id) where published_at is null;
@@ -0,0 +1,28 @@
+create table if not exists webhooks
+(
+    id          bigserial primary key,
+    url         text        not null,
+    event_types jsonb       not null default '[]',
+    secret      text        not null,
+    created_at  timestamptz not null default now()
+);
+
+create table if not exists webhook_deliveries
+(
+    id              bigserial primary key,
+    webhook_id      bigint      not null references webhooks (id) on delete cascade,
+    event_id        bigint      not null,
+    event_type      text        not null,
+    payload         jsonb       not null,
+    status          text        not null default 'pending',
+    attempts        integer     not null default 0,
+    response_status integer,
+    last_error      text,
+    next_attempt_at timestamptz not null default now(),
+    created_at      timestamptz not null default now(),
+    delivered_at    timestamptz,
+    unique (webhook_id, event_id)
+);
+
+create index if not exists webhook_deliveries_pending_idx on webhook_deliveries (next_attempt_at, id)
+    where status = 'pending';
@@ -0,0 +1,5 @@
+drop trigger if exists persons_notify_change on persons;
+
+drop function if exists persons_notify_change();
+
+drop sequence if exists persons_changes_seq;
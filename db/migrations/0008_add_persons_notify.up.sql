@@ -0,0 +1,43 @@
+create sequence if not exists persons_changes_seq;
+
+-- persons_notify_change announces person changes on the persons_changes channel. Changes of deleted persons
+-- are not visible to clients, so they are not announced.
+create or replace function persons_notify_change() returns trigger as
+$$
+declare
+    change_type text;
+begin
+    if tg_op = 'INSERT' then
+        change_type := 'create';
+    elsif old.deleted_at is null and new.deleted_at is not null then
+        change_type := 'delete';
+    elsif old.deleted_at is not null and new.deleted_at is null then
+        change_type := 'restore';
+    elsif new.deleted_at is not null then
+        return null;
+    else
+        change_type := 'update';
+    end if;
+
+    perform pg_notify('persons_changes', json_build_object(
+        'id', nextval('persons_changes_seq'),
+        'type', change_type,
+        'person', json_build_object(
+            'id', new.id,
+            'name', new.name,
+            'age', new.age,
+            'address', new.address,
+            'work', new.work,
+            'version', new.version
+        )
+    )::text);
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists persons_notify_change on persons;
+create trigger persons_notify_change
+    after insert or update
+    on persons
+    for each row
+execute function persons_notify_change();
@@ -0,0 +1,15 @@
+create table if not exists idempotency_keys
+(
+    scope            text        not null,
+    key              text        not null,
+    fingerprint      text        not null,
+    response_status  integer,
+    response_headers jsonb,
+    response_body    bytea,
+    locked_until     timestamptz not null,
+    created_at       timestamptz not null default now(),
+    expires_at       timestamptz not null,
+    primary key (scope, key)
+);
+
+create index if not exists idempotency_keys_expires_at_idx on idempotency_keys (expires_at);
@@ -0,0 +1,10 @@
+-- Buckets are refilled over time anyway, so they are not worth the write-ahead log.
+create unlogged table if not exists rate_limit_buckets
+(
+    key        text             primary key,
+    tokens     double precision not null,
+    allowed    boolean          not null,
+    updated_at timestamptz      not null
+);
+
+create index if not exists rate_limit_buckets_updated_at_idx on rate_limit_buckets (updated_at);
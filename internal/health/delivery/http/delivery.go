@@ -0,0 +1,118 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	pHealth "github.com/SlavaShagalov/ds-lab1/internal/health"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+)
+
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+)
+
+// checkFailed is reported for errors that are not defined by the service, they may hold addresses or
+// server messages and are only logged.
+const checkFailed = "check failed"
+
+type delivery struct {
+	deps []pHealth.Dependency
+	log  *zap.Logger
+}
+
+func RegisterHandlers(mux *mux.Router, deps []pHealth.Dependency, log *zap.Logger) {
+	del := delivery{
+		deps: deps,
+		log:  log,
+	}
+
+	mux.HandleFunc(livenessPath, del.liveness).Methods(http.MethodGet)
+	mux.HandleFunc(readinessPath, del.readiness).Methods(http.MethodGet)
+}
+
+// liveness godoc
+//
+//	@Summary		Process liveness probe
+//	@Description	Reports that the process is running and able to serve HTTP
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	healthResponse
+//	@Router			/healthz [get]
+func (del *delivery) liveness(w http.ResponseWriter, r *http.Request) {
+	pHTTP.SendJSON(w, r, http.StatusOK, healthResponse{
+		Status: statusUp,
+		Checks: []checkResponse{},
+	})
+}
+
+// readiness godoc
+//
+//	@Summary		Readiness probe
+//	@Description	Checks every dependency and reports its status and latency
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	healthResponse
+//	@Failure		503	{object}	healthResponse
+//	@Router			/readyz [get]
+func (del *delivery) readiness(w http.ResponseWriter, r *http.Request) {
+	checks := make([]checkResponse, len(del.deps))
+
+	var wg sync.WaitGroup
+	for i, dep := range del.deps {
+		wg.Add(1)
+		go func(i int, dep pHealth.Dependency) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := dep.Checker.HealthCheck(r.Context())
+			checks[i] = checkResponse{
+				Name:      dep.Name,
+				Status:    statusUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				del.logger(r).Warn("Dependency health check failed", zap.String("dependency", dep.Name), zap.Error(err))
+				checks[i].Status = statusDown
+				checks[i].Error = checkFailed
+				if cause := errors.Cause(err); isKnown(cause) {
+					checks[i].Error = cause.Error()
+				}
+			}
+		}(i, dep)
+	}
+	wg.Wait()
+
+	response := healthResponse{
+		Status: statusUp,
+		Checks: checks,
+	}
+	status := http.StatusOK
+	for _, check := range checks {
+		if check.Status == statusDown {
+			response.Status = statusDown
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	pHTTP.SendJSON(w, r, status, response)
+}
+
+// logger returns the logger of the request.
+func (del *delivery) logger(r *http.Request) *zap.Logger {
+	return requestinfo.Logger(r.Context(), del.log)
+}
+
+func isKnown(err error) bool {
+	_, ok := pErrors.GetHTTPCodeByError(err)
+	return ok
+}
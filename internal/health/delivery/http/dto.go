@@ -0,0 +1,19 @@
+package http
+
+const (
+	statusUp   = "up"
+	statusDown = "down"
+)
+
+// API responses
+type checkResponse struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string          `json:"status"`
+	Checks []checkResponse `json:"checks"`
+}
@@ -0,0 +1,22 @@
+package health
+
+import (
+	"context"
+)
+
+type Checker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// CheckerFunc adapts an ordinary function to the Checker interface.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Dependency is an external resource the service needs to be ready to serve traffic.
+type Dependency struct {
+	Name    string
+	Checker Checker
+}
@@ -0,0 +1,35 @@
+// Package idempotency keeps the responses of requests sent with an Idempotency-Key, so that a retried request
+// gets the response of the first one instead of being applied again.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Response is a stored response, Header holds only the replayed headers.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is the state of a key. Response is nil while the first request is still being handled.
+type Record struct {
+	Fingerprint string
+	Response    *Response
+}
+
+type Store interface {
+	// Acquire reserves the key of scope for a request with fingerprint for ttl. The request may take up to lock
+	// to complete, after that the key is given to a retry. If the key is reserved, its record is returned instead.
+	Acquire(ctx context.Context, scope, key, fingerprint string, lock, ttl time.Duration) (
+		record *Record, acquired bool, err error)
+	// Complete stores the response of the request that acquired the key.
+	Complete(ctx context.Context, scope, key string, response *Response) error
+	// Release frees a key that has no response, so that a retry is handled anew.
+	Release(ctx context.Context, scope, key string) error
+	// DeleteExpired removes at most limit expired keys and returns the number of removed keys.
+	DeleteExpired(ctx context.Context, limit int64) (int64, error)
+}
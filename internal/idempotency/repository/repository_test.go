@@ -0,0 +1,199 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/idempotency"
+	"github.com/SlavaShagalov/ds-lab1/internal/idempotency/repository/std"
+	pkgErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+func TestAcquire(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare  func(f *fields)
+		record   *idempotency.Record
+		acquired bool
+		err      error
+	}
+
+	const acquireCmd = `
+	INSERT INTO idempotency_keys (scope, key, fingerprint, locked_until, expires_at)
+	VALUES ($1, $2, $3, now() + $4 * interval '1 millisecond', now() + $5 * interval '1 millisecond')
+	ON CONFLICT (scope, key) DO UPDATE
+	SET fingerprint = excluded.fingerprint, response_status = NULL, response_headers = NULL, response_body = NULL,
+		locked_until = excluded.locked_until, created_at = now(), expires_at = excluded.expires_at
+	WHERE idempotency_keys.expires_at <= now()
+		OR (idempotency_keys.response_status IS NULL AND idempotency_keys.locked_until <= now())
+	RETURNING true;`
+
+	const getCmd = `
+	SELECT fingerprint, response_status, response_headers, response_body
+	FROM idempotency_keys
+	WHERE scope = $1 AND key = $2;`
+
+	const scope = "anonymous POST /api/v1/persons"
+	args := []driver.Value{scope, "key", "abc", 60000, 86400000}
+	columns := []string{"fingerprint", "response_status", "response_headers", "response_body"}
+
+	tests := map[string]testCase{
+		"new key": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(acquireCmd)).
+					WithArgs(args...).
+					WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+			},
+			record:   nil,
+			acquired: true,
+			err:      nil,
+		},
+		"completed key": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(acquireCmd)).
+					WithArgs(args...).
+					WillReturnError(sql.ErrNoRows)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(getCmd)).
+					WithArgs(scope, "key").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("abc", 201, []byte(`{"Location":["/api/v1/persons/3"]}`), []byte{}))
+			},
+			record: &idempotency.Record{
+				Fingerprint: "abc",
+				Response: &idempotency.Response{
+					Status: 201,
+					Header: http.Header{"Location": {"/api/v1/persons/3"}},
+					Body:   []byte{},
+				},
+			},
+			acquired: false,
+			err:      nil,
+		},
+		"key in progress": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(acquireCmd)).
+					WithArgs(args...).
+					WillReturnRows(sqlmock.NewRows([]string{"bool"}))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(getCmd)).
+					WithArgs(scope, "key").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("def", nil, nil, nil))
+			},
+			record:   &idempotency.Record{Fingerprint: "def"},
+			acquired: false,
+			err:      nil,
+		},
+		"key released meanwhile": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(acquireCmd)).
+					WithArgs(args...).
+					WillReturnRows(sqlmock.NewRows([]string{"bool"}))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(getCmd)).
+					WithArgs(scope, "key").
+					WillReturnRows(sqlmock.NewRows(columns))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(acquireCmd)).
+					WithArgs(args...).
+					WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+			},
+			record:   nil,
+			acquired: true,
+			err:      nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(acquireCmd)).
+					WithArgs(args...).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			record:   nil,
+			acquired: false,
+			err:      pkgErrors.ErrDb,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("can't create mock: %s", err)
+			}
+			defer db.Close()
+
+			repo := std.New(db, zap.NewNop())
+
+			f := fields{mock: mock}
+			if test.prepare != nil {
+				test.prepare(&f)
+			}
+
+			record, acquired, err := repo.Acquire(context.TODO(), scope, "key", "abc", time.Minute, 24*time.Hour)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if acquired != test.acquired {
+				t.Errorf("\nExpected: %t\nGot: %t", test.acquired, acquired)
+			}
+			if !reflect.DeepEqual(record, test.record) {
+				t.Errorf("\nExpected: %+v\nGot: %+v", test.record, record)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("\nThere were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	const completeCmd = `
+	UPDATE idempotency_keys
+	SET response_status = $3, response_headers = $4, response_body = $5
+	WHERE scope = $1 AND key = $2 AND response_status IS NULL;`
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	mock.
+		ExpectExec(regexp.QuoteMeta(completeCmd)).
+		WithArgs("scope", "key", 201, `{"Location":["/api/v1/persons/3"]}`, []byte{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	repo := std.New(db, zap.NewNop())
+	err = repo.Complete(context.TODO(), "scope", "key", &idempotency.Response{
+		Status: 201,
+		Header: http.Header{"Location": {"/api/v1/persons/3"}},
+	})
+	if err != nil {
+		t.Errorf("\nExpected: %v\nGot: %s", nil, err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("\nThere were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,202 @@
+package std
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/idempotency"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+// acquireAttempts bounds the retries of Acquire when the key is released between its statements.
+const acquireAttempts = 3
+
+type timeouts struct {
+	create time.Duration
+	get    time.Duration
+	delete time.Duration
+	purge  time.Duration
+}
+
+type repository struct {
+	db       *sql.DB
+	log      *zap.Logger
+	timeouts timeouts
+}
+
+func New(db *sql.DB, log *zap.Logger) idempotency.Store {
+	return &repository{
+		db:  db,
+		log: log,
+		timeouts: timeouts{
+			create: viper.GetDuration(config.PostgresCreateTimeout),
+			get:    viper.GetDuration(config.PostgresGetTimeout),
+			delete: viper.GetDuration(config.PostgresDeleteTimeout),
+			purge:  viper.GetDuration(config.PostgresPurgeTimeout),
+		},
+	}
+}
+
+// acquireCmd inserts the key, or takes over a key that expired or was abandoned by a request that
+// did not complete within its lock. Concurrent requests with the same key conflict on the primary key,
+// so exactly one of them gets a row back.
+const acquireCmd = `
+	INSERT INTO idempotency_keys (scope, key, fingerprint, locked_until, expires_at)
+	VALUES ($1, $2, $3, now() + $4 * interval '1 millisecond', now() + $5 * interval '1 millisecond')
+	ON CONFLICT (scope, key) DO UPDATE
+	SET fingerprint = excluded.fingerprint, response_status = NULL, response_headers = NULL, response_body = NULL,
+		locked_until = excluded.locked_until, created_at = now(), expires_at = excluded.expires_at
+	WHERE idempotency_keys.expires_at <= now()
+		OR (idempotency_keys.response_status IS NULL AND idempotency_keys.locked_until <= now())
+	RETURNING true;`
+
+const getCmd = `
+	SELECT fingerprint, response_status, response_headers, response_body
+	FROM idempotency_keys
+	WHERE scope = $1 AND key = $2;`
+
+func (repo *repository) Acquire(ctx context.Context, scope, key, fingerprint string, lock, ttl time.Duration) (
+	*idempotency.Record, bool, error) {
+	for attempt := 0; attempt < acquireAttempts; attempt++ {
+		acquired, err := repo.acquire(ctx, scope, key, fingerprint, lock, ttl)
+		if err != nil || acquired {
+			return nil, acquired, err
+		}
+
+		record, err := repo.get(ctx, scope, key)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return record, false, err
+		}
+		// The key was released after the insert conflicted, so it may be acquired now.
+	}
+	return nil, false, errors.Wrapf(pErrors.ErrDb, "idempotency key %q changes too often", key)
+}
+
+func (repo *repository) acquire(ctx context.Context, scope, key, fingerprint string, lock, ttl time.Duration) (
+	bool, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.create)
+	defer cancel()
+
+	var acquired bool
+	err := repo.db.QueryRowContext(ctx, acquireCmd, scope, key, fingerprint, lock.Milliseconds(),
+		ttl.Milliseconds()).Scan(&acquired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", acquireCmd))
+		return false, postgres.QueryError(ctx, err)
+	}
+	return acquired, nil
+}
+
+func (repo *repository) get(ctx context.Context, scope, key string) (*idempotency.Record, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.get)
+	defer cancel()
+
+	var (
+		record  idempotency.Record
+		status  sql.NullInt64
+		headers []byte
+		body    []byte
+	)
+	err := repo.db.QueryRowContext(ctx, getCmd, scope, key).Scan(&record.Fingerprint, &status, &headers, &body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", getCmd))
+		return nil, postgres.QueryError(ctx, err)
+	}
+
+	if status.Valid {
+		record.Response = &idempotency.Response{Status: int(status.Int64), Body: body}
+		if len(headers) > 0 {
+			err = json.Unmarshal(headers, &record.Response.Header)
+			if err != nil {
+				return nil, errors.Wrap(pErrors.ErrDb, err.Error())
+			}
+		}
+	}
+	return &record, nil
+}
+
+const completeCmd = `
+	UPDATE idempotency_keys
+	SET response_status = $3, response_headers = $4, response_body = $5
+	WHERE scope = $1 AND key = $2 AND response_status IS NULL;`
+
+func (repo *repository) Complete(ctx context.Context, scope, key string, response *idempotency.Response) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.create)
+	defer cancel()
+
+	headers, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	body := response.Body
+	if body == nil {
+		body = []byte{}
+	}
+
+	_, err = repo.db.ExecContext(ctx, completeCmd, scope, key, response.Status, string(headers), body)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", completeCmd))
+		return postgres.QueryError(ctx, err)
+	}
+	return nil
+}
+
+const releaseCmd = `
+	DELETE FROM idempotency_keys
+	WHERE scope = $1 AND key = $2 AND response_status IS NULL;`
+
+func (repo *repository) Release(ctx context.Context, scope, key string) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.delete)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, releaseCmd, scope, key)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", releaseCmd))
+		return postgres.QueryError(ctx, err)
+	}
+	return nil
+}
+
+const deleteExpiredCmd = `
+	DELETE FROM idempotency_keys
+	WHERE (scope, key) IN (
+		SELECT scope, key
+		FROM idempotency_keys
+		WHERE expires_at <= now()
+		LIMIT $1
+	);`
+
+func (repo *repository) DeleteExpired(ctx context.Context, limit int64) (int64, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.purge)
+	defer cancel()
+
+	result, err := repo.db.ExecContext(ctx, deleteExpiredCmd, limit)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", deleteExpiredCmd))
+		return 0, postgres.QueryError(ctx, err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// logger returns the logger of the request that ctx belongs to.
+func (repo *repository) logger(ctx context.Context) *zap.Logger {
+	return requestinfo.Logger(ctx, repo.log)
+}
@@ -0,0 +1,61 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Sweeper periodically removes expired keys along with their responses.
+type Sweeper struct {
+	store     Store
+	interval  time.Duration
+	batchSize int64
+	log       *zap.Logger
+}
+
+func NewSweeper(store Store, interval time.Duration, batchSize int64, log *zap.Logger) *Sweeper {
+	return &Sweeper{
+		store:     store,
+		interval:  interval,
+		batchSize: batchSize,
+		log:       log,
+	}
+}
+
+// Run removes expired keys right away and then once per interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.DeleteExpired(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.log.Error("Failed to delete expired idempotency keys", zap.Int64("deleted", deleted), zap.Error(err))
+		} else if deleted > 0 {
+			s.log.Info("Expired idempotency keys deleted", zap.Int64("deleted", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeleteExpired removes expired keys in batches and returns the number of removed keys.
+func (s *Sweeper) DeleteExpired(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		deleted, err := s.store.DeleteExpired(ctx, s.batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+		if deleted < s.batchSize {
+			return total, nil
+		}
+	}
+}
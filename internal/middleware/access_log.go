@@ -1,20 +1,125 @@
 package middleware
 
 import (
-	"go.uber.org/zap"
+	"bufio"
+	"net"
 	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
 )
 
+// NewAccessLog logs every request once it is handled, with the status, the size of the response body and
+// the time it took. Requests that fail on the server are logged as warnings. It must run after NewRequestInfo
+// so that the entries carry the request id.
 func NewAccessLog(log *zap.Logger) func(handler http.Handler) http.Handler {
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Info("New request",
-				zap.String("method", r.Method),
-				zap.String("url", r.URL.String()),
-				zap.String("protocol", r.Proto),
-				zap.String("origin", r.Header.Get("Origin")))
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+
+			defer func() {
+				// A handler that panics, such as one that aborts a streamed response, is logged as well.
+				p := recover()
+
+				fields := []zap.Field{
+					zap.String("method", r.Method),
+					zap.String("url", r.URL.String()),
+					zap.String("protocol", r.Proto),
+					zap.String("origin", r.Header.Get("Origin")),
+					zap.String("remote_addr", r.RemoteAddr),
+					zap.String("user_agent", r.UserAgent()),
+					zap.Int("status", rw.statusCode()),
+					zap.Int64("bytes", rw.bytes),
+					zap.Duration("latency", time.Since(start)),
+				}
+				if rw.hijacked {
+					fields = append(fields, zap.Bool("hijacked", true))
+				}
+				if p != nil {
+					fields = append(fields, zap.Bool("aborted", true))
+				}
+
+				requestLog := requestinfo.Logger(r.Context(), log)
+				if p != nil || rw.statusCode() >= http.StatusInternalServerError {
+					requestLog.Warn("Request failed", fields...)
+				} else {
+					requestLog.Info("Request handled", fields...)
+				}
+
+				if p != nil {
+					panic(p)
+				}
+			}()
 
-			handler.ServeHTTP(w, r)
+			handler.ServeHTTP(rw, r)
 		})
 	}
 }
+
+// responseWriter records the status and the size of a response. It keeps the optional interfaces of the
+// writer it wraps that handlers rely on: streamed responses flush and upgraded connections are hijacked.
+type responseWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	// Informational responses are followed by the final one.
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the wrapped writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode is the status sent to the client, a handler that writes nothing sends 200.
+func (w *responseWriter) statusCode() int {
+	switch {
+	case w.status != 0:
+		return w.status
+	case w.hijacked:
+		return http.StatusSwitchingProtocols
+	default:
+		return http.StatusOK
+	}
+}
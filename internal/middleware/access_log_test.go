@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+)
+
+func TestAccessLog(t *testing.T) {
+	type testCase struct {
+		handler http.HandlerFunc
+		level   zapcore.Level
+		status  int64
+		bytes   int64
+	}
+
+	tests := map[string]testCase{
+		"ok": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"id":1}`))
+			},
+			level:  zapcore.InfoLevel,
+			status: http.StatusOK,
+			bytes:  8,
+		},
+		"created": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.WriteHeader(http.StatusOK)
+			},
+			level:  zapcore.InfoLevel,
+			status: http.StatusCreated,
+			bytes:  0,
+		},
+		"server error": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(`{}`))
+			},
+			level:  zapcore.WarnLevel,
+			status: http.StatusServiceUnavailable,
+			bytes:  2,
+		},
+		"flushed stream": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				flusher, ok := w.(http.Flusher)
+				if !ok {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				_, _ = w.Write([]byte("data: {}\n\n"))
+				flusher.Flush()
+			},
+			level:  zapcore.InfoLevel,
+			status: http.StatusOK,
+			bytes:  10,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zapcore.DebugLevel)
+			log := zap.New(core)
+			handler := NewRequestInfo(log)(NewAccessLog(log)(test.handler))
+
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/persons/1", nil)
+			r.Header.Set(RequestIDHeader, "abc")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			entries := logs.AllUntimed()
+			if len(entries) != 1 {
+				t.Fatalf("\nExpected: 1 entry\nGot: %v", entries)
+			}
+			fields := entries[0].ContextMap()
+			if entries[0].Level != test.level {
+				t.Errorf("\nExpected: %s\nGot: %s", test.level, entries[0].Level)
+			}
+			if fields["status"] != test.status || fields["bytes"] != test.bytes {
+				t.Errorf("\nExpected: status %d, %d bytes\nGot: %v", test.status, test.bytes, fields)
+			}
+			if fields["request_id"] != "abc" || fields["method"] != http.MethodGet {
+				t.Errorf("\nExpected: request id and method\nGot: %v", fields)
+			}
+			if _, ok := fields["latency"]; !ok {
+				t.Errorf("\nExpected: latency\nGot: %v", fields)
+			}
+		})
+	}
+}
+
+func TestAccessLogAborted(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := NewAccessLog(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("id,name\n"))
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("\nExpected: %v\nGot: %v", http.ErrAbortHandler, p)
+		}
+		entries := logs.FilterField(zap.Bool("aborted", true)).AllUntimed()
+		if len(entries) != 1 || entries[0].Level != zapcore.WarnLevel {
+			t.Errorf("\nExpected: aborted request warning\nGot: %v", logs.AllUntimed())
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/persons/export", nil))
+}
+
+func TestRequestInfoLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(core)
+	handler := NewRequestInfo(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestinfo.Logger(r.Context(), zap.NewNop()).Info("Handled")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/persons", nil)
+	r.Header.Set(ActorHeader, "alice")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	requestID := w.Header().Get(RequestIDHeader)
+	entries := logs.FilterField(zap.String("request_id", requestID)).FilterField(zap.String("actor", "alice"))
+	if requestID == "" || entries.Len() != 1 {
+		t.Errorf("\nExpected: entry with request id %q and actor\nGot: %v", requestID, logs.AllUntimed())
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/idempotency"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks responses that were stored for an earlier request with the same key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers stored along with the status and the body.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// NewIdempotency makes requests with an Idempotency-Key header safe to retry. The response to the first request
+// is stored for ttl and returned for later requests with the same key, the same actor and the same body;
+// another body is rejected with 422 and a request that comes while the first one is running gets 409.
+// Server errors are not stored, so the request is handled anew when it is retried.
+func NewIdempotency(store idempotency.Store, lock, ttl time.Duration, log *zap.Logger) func(
+	handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength || !printable(key) {
+				pHTTP.HandleError(w, r, pErrors.ErrInvalidIdempotencyKey)
+				return
+			}
+
+			requestLog := requestinfo.Logger(r.Context(), log)
+			body, err := pHTTP.ReadBody(r, requestLog)
+			if err != nil {
+				pHTTP.HandleError(w, r, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.Sum256(body)
+			fingerprint := hex.EncodeToString(sum[:])
+			scope := requestinfo.Actor(r.Context()) + " " + r.Method + " " + r.URL.Path
+
+			record, acquired, err := store.Acquire(r.Context(), scope, key, fingerprint, lock, ttl)
+			if err != nil {
+				pHTTP.HandleError(w, r, err)
+				return
+			}
+			if !acquired {
+				replay(w, r, record, fingerprint)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w}
+			handler.ServeHTTP(rec, r)
+
+			// The response has been sent, storing it must not depend on the client still waiting.
+			ctx := context.WithoutCancel(r.Context())
+			status := rec.Status()
+			if status >= http.StatusInternalServerError || status == pErrors.StatusClientClosedRequest {
+				err = store.Release(ctx, scope, key)
+			} else {
+				err = store.Complete(ctx, scope, key, &idempotency.Response{
+					Status: status,
+					Header: rec.header,
+					Body:   rec.body.Bytes(),
+				})
+			}
+			if err != nil {
+				requestLog.Error("Failed to save idempotency key", zap.Error(err), zap.String("key", key),
+					zap.Int("status", status))
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, record *idempotency.Record, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		pHTTP.HandleError(w, r, pErrors.ErrIdempotencyKeyReused)
+		return
+	}
+	if record.Response == nil {
+		w.Header().Set("Retry-After", "1")
+		pHTTP.HandleError(w, r, pErrors.ErrIdempotencyKeyInUse)
+		return
+	}
+
+	for name, values := range record.Response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Response.Status)
+	_, _ = w.Write(record.Response.Body)
+}
+
+// responseRecorder passes the response on while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = make(http.Header)
+		for _, name := range replayedHeaders {
+			if values := rec.ResponseWriter.Header().Values(name); len(values) > 0 {
+				rec.header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Status returns the status of the response, handlers that write nothing respond with 200.
+func (rec *responseRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
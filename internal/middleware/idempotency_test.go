@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/idempotency"
+)
+
+// store keeps keys in memory, they never expire.
+type store struct {
+	mu      sync.Mutex
+	records map[string]*idempotency.Record
+}
+
+func (s *store) Acquire(_ context.Context, scope, key, fingerprint string, _, _ time.Duration) (
+	*idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[scope+key]; ok {
+		return record, false, nil
+	}
+	s.records[scope+key] = &idempotency.Record{Fingerprint: fingerprint}
+	return nil, true, nil
+}
+
+func (s *store) Complete(_ context.Context, scope, key string, response *idempotency.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[scope+key].Response = response
+	return nil
+}
+
+func (s *store) Release(_ context.Context, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, scope+key)
+	return nil
+}
+
+func (s *store) DeleteExpired(context.Context, int64) (int64, error) {
+	return 0, nil
+}
+
+func TestIdempotency(t *testing.T) {
+	type request struct {
+		key  string
+		body string
+	}
+
+	type testCase struct {
+		// status is the status of the first response.
+		status   int
+		requests []request
+		// statuses and calls are the responses and the number of times the handler was run.
+		statuses []int
+		calls    int
+	}
+
+	tests := map[string]testCase{
+		"retried request": {
+			status:   http.StatusCreated,
+			requests: []request{{key: "a", body: `{"name":"Johnny"}`}, {key: "a", body: `{"name":"Johnny"}`}},
+			statuses: []int{http.StatusCreated, http.StatusCreated},
+			calls:    1,
+		},
+		"another body": {
+			status:   http.StatusCreated,
+			requests: []request{{key: "a", body: `{"name":"Johnny"}`}, {key: "a", body: `{"name":"Den"}`}},
+			statuses: []int{http.StatusCreated, http.StatusUnprocessableEntity},
+			calls:    1,
+		},
+		"another key": {
+			status:   http.StatusCreated,
+			requests: []request{{key: "a", body: `{"name":"Johnny"}`}, {key: "b", body: `{"name":"Johnny"}`}},
+			statuses: []int{http.StatusCreated, http.StatusCreated},
+			calls:    2,
+		},
+		"no key": {
+			status:   http.StatusCreated,
+			requests: []request{{body: `{"name":"Johnny"}`}, {body: `{"name":"Johnny"}`}},
+			statuses: []int{http.StatusCreated, http.StatusCreated},
+			calls:    2,
+		},
+		"stored client error": {
+			status:   http.StatusBadRequest,
+			requests: []request{{key: "a", body: `{}`}, {key: "a", body: `{}`}},
+			statuses: []int{http.StatusBadRequest, http.StatusBadRequest},
+			calls:    1,
+		},
+		"released server error": {
+			status:   http.StatusInternalServerError,
+			requests: []request{{key: "a", body: `{}`}, {key: "a", body: `{}`}},
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			calls:    2,
+		},
+		"invalid key": {
+			status:   http.StatusCreated,
+			requests: []request{{key: strings.Repeat("a", 256), body: `{}`}},
+			statuses: []int{http.StatusBadRequest},
+			calls:    0,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				body, _ := io.ReadAll(r.Body)
+				w.Header().Set("Location", "/api/v1/persons/3")
+				w.WriteHeader(test.status)
+				_, _ = w.Write(body)
+			})
+			idempotent := NewIdempotency(&store{records: map[string]*idempotency.Record{}}, time.Minute, time.Hour,
+				zap.NewNop())(handler)
+
+			for i, req := range test.requests {
+				r := httptest.NewRequest(http.MethodPost, "/api/v1/persons", strings.NewReader(req.body))
+				if req.key != "" {
+					r.Header.Set(IdempotencyKeyHeader, req.key)
+				}
+				w := httptest.NewRecorder()
+				idempotent.ServeHTTP(w, r)
+
+				if w.Code != test.statuses[i] {
+					t.Errorf("\nExpected: %d\nGot: %d", test.statuses[i], w.Code)
+				}
+				if w.Code == test.status && w.Body.String() != req.body {
+					t.Errorf("\nExpected: %s\nGot: %s", req.body, w.Body.String())
+				}
+				replayed := i > 0 && test.calls < len(test.requests) && w.Code == test.status
+				if replayed && (w.Header().Get(IdempotentReplayedHeader) != "true" ||
+					w.Header().Get("Location") != "/api/v1/persons/3") {
+					t.Errorf("\nExpected: replayed response\nGot: %v", w.Header())
+				}
+			}
+			if calls != test.calls {
+				t.Errorf("\nExpected: %d calls\nGot: %d", test.calls, calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	s := &store{records: map[string]*idempotency.Record{}}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	})
+	idempotent := NewIdempotency(s, time.Minute, time.Hour, zap.NewNop())(handler)
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/persons", strings.NewReader(`{}`))
+		r.Header.Set(IdempotencyKeyHeader, "a")
+		return r
+	}
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		idempotent.ServeHTTP(first, newRequest())
+	}()
+	<-started
+
+	second := httptest.NewRecorder()
+	idempotent.ServeHTTP(second, newRequest())
+	if second.Code != http.StatusConflict {
+		t.Errorf("\nExpected: %d\nGot: %d", http.StatusConflict, second.Code)
+	}
+
+	close(release)
+	<-done
+	if first.Code != http.StatusCreated {
+		t.Errorf("\nExpected: %d\nGot: %d", http.StatusCreated, first.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics"
+)
+
+// NewMetrics counts requests and measures their latency per route. Routes are reported by their path template,
+// such as /api/v1/persons/{id}, so that the number of series does not grow with the ids in the URLs. It must be
+// used as a router middleware to see the matched route.
+func NewMetrics(registry *metrics.Registry) func(handler http.Handler) http.Handler {
+	requests := metrics.NewCounterVec("http_requests_total",
+		"Number of HTTP requests handled, by method, route and status.", "method", "route", "status")
+	latency := metrics.NewHistogramVec("http_request_duration_seconds",
+		"Time it took to handle HTTP requests, by method and route.", metrics.DefaultBuckets, "method", "route")
+	var inFlight atomic.Int64
+	registry.MustRegister(requests, latency,
+		metrics.NewGaugeFunc("http_requests_in_flight", "Number of HTTP requests being handled.", func() float64 {
+			return float64(inFlight.Load())
+		}))
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := "unknown"
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+
+			start := time.Now()
+			inFlight.Add(1)
+			rw := &responseWriter{ResponseWriter: w}
+			defer func() {
+				inFlight.Add(-1)
+				requests.Inc(r.Method, route, strconv.Itoa(rw.statusCode()))
+				latency.Observe(time.Since(start).Seconds(), r.Method, route)
+			}()
+
+			handler.ServeHTTP(rw, r)
+		})
+	}
+}
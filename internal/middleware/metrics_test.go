@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics/metricstest"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	router := mux.NewRouter()
+	router.Use(NewMetrics(registry))
+	router.HandleFunc("/api/v1/persons/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "0" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods(http.MethodGet)
+	router.Handle("/metrics", registry).Methods(http.MethodGet)
+
+	for _, path := range []string{"/api/v1/persons/1", "/api/v1/persons/2", "/api/v1/persons/0"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	samples, _, err := metricstest.Parse(strings.NewReader(w.Body.String()))
+	if err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %v\n%s", nil, err, w.Body.String())
+	}
+
+	const route = "/api/v1/persons/{id}"
+	expected := map[string]float64{
+		metricstest.Key("http_requests_total", "method", "GET", "route", route, "status", "200"): 2,
+		metricstest.Key("http_requests_total", "method", "GET", "route", route, "status", "404"): 1,
+		metricstest.Key("http_request_duration_seconds_count", "method", "GET", "route", route):  3,
+		metricstest.Key("http_request_duration_seconds_bucket", "method", "GET", "route", route,
+			"le", "+Inf"): 3,
+		// The scrape itself is in flight.
+		"http_requests_in_flight": 1,
+	}
+	for key, value := range expected {
+		if got, ok := samples[key]; !ok || got != value {
+			t.Errorf("%s\nExpected: %v\nGot: %v", key, value, got)
+		}
+	}
+	for key := range samples {
+		if strings.Contains(key, "/api/v1/persons/1") {
+			t.Errorf("\nExpected: route templates only\nGot: %s", key)
+		}
+	}
+}
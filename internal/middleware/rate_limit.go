@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/ratelimit"
+)
+
+// Clients are told their limits with the headers of the IETF RateLimit header fields draft.
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	retryAfterHeader         = "Retry-After"
+	forwardedForHeader       = "X-Forwarded-For"
+)
+
+// What requests share a bucket.
+const (
+	RateLimitByIP     = "ip"
+	RateLimitByAPIKey = "api_key"
+	RateLimitByRoute  = "route"
+)
+
+// RateLimitRule limits the requests that match Method and Path. Requests with the same client IP, API key or
+// route, depending on By, share a bucket; requests without an API key are not limited by api_key rules.
+type RateLimitRule struct {
+	Name string `mapstructure:"name"`
+	By   string `mapstructure:"by"`
+	// Method matches any method if empty.
+	Method string `mapstructure:"method"`
+	// Path is a prefix of the request path, it matches any path if empty.
+	Path  string  `mapstructure:"path"`
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+type RateLimitConfig struct {
+	Rules        []RateLimitRule
+	APIKeyHeader string
+	// TrustedProxies is the number of proxies in front of the service that append the address they were
+	// connected from to X-Forwarded-For. The client IP is the entry that many from the right, entries left
+	// of it are set by the client. Zero ignores the header.
+	TrustedProxies int
+}
+
+// NewRateLimit rejects requests over any matching rule with 429. It must be used as a router middleware,
+// so that route rules see the matched route. A request that can not be checked because the store failed
+// is let through.
+// Rules are checked in order until one rejects the request, which then takes no token from the rules after
+// it; listing narrow rules first keeps requests they reject from using up the broader buckets.
+func NewRateLimit(store ratelimit.Store, config RateLimitConfig, log *zap.Logger) (func(
+	handler http.Handler) http.Handler, error) {
+	if config.TrustedProxies < 0 {
+		return nil, fmt.Errorf("rate limit: trusted proxies must not be negative, got %d", config.TrustedProxies)
+	}
+	config.Rules = slices.Clone(config.Rules)
+	names := make(map[string]bool, len(config.Rules))
+	for i, rule := range config.Rules {
+		switch {
+		case rule.Name == "" || names[rule.Name]:
+			return nil, fmt.Errorf("rate limit rule %d: name is empty or not unique", i)
+		case rule.By != RateLimitByIP && rule.By != RateLimitByAPIKey && rule.By != RateLimitByRoute:
+			return nil, fmt.Errorf("rate limit rule %q: unknown by %q", rule.Name, rule.By)
+		case rule.Rate <= 0 || rule.Burst < 1:
+			return nil, fmt.Errorf("rate limit rule %q: rate must be positive and burst at least 1", rule.Name)
+		}
+		names[rule.Name] = true
+		config.Rules[i].Method = strings.ToUpper(rule.Method)
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tightest *ratelimit.Result
+			for _, rule := range config.Rules {
+				key, ok := rateLimitKey(r, &rule, &config)
+				if !ok {
+					continue
+				}
+
+				result, err := store.Take(r.Context(), key, ratelimit.Limit{Rate: rule.Rate, Burst: rule.Burst})
+				if err != nil {
+					log.Warn("Failed to check rate limit", zap.Error(err), zap.String("rule", rule.Name))
+					continue
+				}
+				if !result.Allowed {
+					tightest = &result
+					break
+				}
+				// Otherwise the rule with the fewest remaining requests is reported.
+				if tightest == nil || result.Remaining < tightest.Remaining {
+					tightest = &result
+				}
+			}
+
+			if tightest == nil {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set(RateLimitLimitHeader, strconv.Itoa(tightest.Limit))
+			w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(tightest.Remaining))
+			w.Header().Set(RateLimitResetHeader, strconv.Itoa(seconds(tightest.Reset)))
+			if !tightest.Allowed {
+				w.Header().Set(retryAfterHeader, strconv.Itoa(max(seconds(tightest.RetryAfter), 1)))
+				pHTTP.HandleError(w, r, pErrors.ErrTooManyRequests)
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// rateLimitKey returns the bucket of the request under rule, or false if the rule does not apply to it.
+func rateLimitKey(r *http.Request, rule *RateLimitRule, config *RateLimitConfig) (string, bool) {
+	if rule.Method != "" && rule.Method != r.Method || !strings.HasPrefix(r.URL.Path, rule.Path) {
+		return "", false
+	}
+
+	var subject string
+	switch rule.By {
+	case RateLimitByIP:
+		subject = clientIP(r, config.TrustedProxies)
+	case RateLimitByAPIKey:
+		apiKey := r.Header.Get(config.APIKeyHeader)
+		if apiKey == "" {
+			apiKey, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if apiKey == "" {
+			return "", false
+		}
+		// Keys are secrets, they are not kept in the store as is.
+		sum := sha256.Sum256([]byte(apiKey))
+		subject = hex.EncodeToString(sum[:16])
+	case RateLimitByRoute:
+		subject = r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				subject = template
+			}
+		}
+		subject = r.Method + " " + subject
+	}
+	return rule.Name + ":" + subject, true
+}
+
+// clientIP returns the address the request came from, taken from X-Forwarded-For behind trustedProxies.
+// With fewer entries than proxies, all of them were set by the proxies and the leftmost one is the client.
+func clientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		var forwarded []string
+		for _, value := range r.Header.Values(forwardedForHeader) {
+			forwarded = append(forwarded, strings.Split(value, ",")...)
+		}
+		if len(forwarded) > 0 {
+			return strings.TrimSpace(forwarded[max(len(forwarded)-trustedProxies, 0)])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
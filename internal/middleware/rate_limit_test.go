@@ -0,0 +1,260 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/ratelimit"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func (failingStore) DeleteIdle(context.Context, time.Duration) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestRateLimit(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		ip     string
+		apiKey string
+	}
+
+	type testCase struct {
+		rules    []RateLimitRule
+		requests []request
+		statuses []int
+	}
+
+	get := func(path, ip string) request {
+		return request{method: http.MethodGet, path: path, ip: ip}
+	}
+
+	tests := map[string]testCase{
+		"by ip": {
+			rules: []RateLimitRule{{Name: "client", By: RateLimitByIP, Rate: 1, Burst: 2}},
+			requests: []request{get("/api/v1/persons", "10.0.0.1"), get("/api/v1/persons/1", "10.0.0.1"),
+				get("/api/v1/persons", "10.0.0.1"), get("/api/v1/persons", "10.0.0.2")},
+			statuses: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		"by route": {
+			rules: []RateLimitRule{{Name: "route", By: RateLimitByRoute, Rate: 1, Burst: 1}},
+			requests: []request{get("/api/v1/persons/1", "10.0.0.1"), get("/api/v1/persons/2", "10.0.0.2"),
+				get("/api/v1/persons", "10.0.0.1")},
+			statuses: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		"by api key": {
+			rules: []RateLimitRule{{Name: "key", By: RateLimitByAPIKey, Rate: 1, Burst: 1}},
+			requests: []request{
+				{method: http.MethodGet, path: "/api/v1/persons", ip: "10.0.0.1", apiKey: "a"},
+				{method: http.MethodGet, path: "/api/v1/persons", ip: "10.0.0.2", apiKey: "a"},
+				{method: http.MethodGet, path: "/api/v1/persons", ip: "10.0.0.1", apiKey: "b"},
+				get("/api/v1/persons", "10.0.0.1"),
+				get("/api/v1/persons", "10.0.0.1"),
+			},
+			statuses: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		"method and path": {
+			rules: []RateLimitRule{{Name: "export", By: RateLimitByIP, Method: "get", Path: "/api/v1/persons/export",
+				Rate: 1, Burst: 1}},
+			requests: []request{get("/api/v1/persons/export", "10.0.0.1"), get("/api/v1/persons/export", "10.0.0.1"),
+				get("/api/v1/persons", "10.0.0.1"), {method: http.MethodPost, path: "/api/v1/persons/export",
+					ip: "10.0.0.1"}},
+			statuses: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK},
+		},
+		"tightest rule": {
+			rules: []RateLimitRule{{Name: "loose", By: RateLimitByIP, Rate: 1, Burst: 10},
+				{Name: "tight", By: RateLimitByIP, Path: "/api/v1/persons/1", Rate: 1, Burst: 1}},
+			requests: []request{get("/api/v1/persons/1", "10.0.0.1"), get("/api/v1/persons/1", "10.0.0.1"),
+				get("/api/v1/persons/2", "10.0.0.1")},
+			statuses: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		"rejected request not counted by later rules": {
+			rules: []RateLimitRule{{Name: "tight", By: RateLimitByIP, Path: "/api/v1/persons/1", Rate: 1, Burst: 1},
+				{Name: "client", By: RateLimitByIP, Rate: 1, Burst: 2}},
+			requests: []request{get("/api/v1/persons/1", "10.0.0.1"), get("/api/v1/persons/1", "10.0.0.1"),
+				get("/api/v1/persons/1", "10.0.0.1"), get("/api/v1/persons/2", "10.0.0.1"),
+				get("/api/v1/persons/2", "10.0.0.1")},
+			statuses: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK,
+				http.StatusTooManyRequests},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rateLimit, err := NewRateLimit(ratelimit.NewMemory(), RateLimitConfig{
+				Rules:        test.rules,
+				APIKeyHeader: "X-API-Key",
+			}, zap.NewNop())
+			if err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+			}
+			router := mux.NewRouter()
+			router.Use(rateLimit)
+			router.HandleFunc("/api/v1/persons", func(http.ResponseWriter, *http.Request) {})
+			router.HandleFunc("/api/v1/persons/{id}", func(http.ResponseWriter, *http.Request) {})
+
+			for i, req := range test.requests {
+				r := httptest.NewRequest(req.method, req.path, nil)
+				r.RemoteAddr = req.ip + ":51000"
+				if req.apiKey != "" {
+					r.Header.Set("X-API-Key", req.apiKey)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+
+				if w.Code != test.statuses[i] {
+					t.Errorf("request %d\nExpected: %d\nGot: %d", i, test.statuses[i], w.Code)
+				}
+				if w.Code == http.StatusTooManyRequests && (w.Header().Get("Retry-After") != "1" ||
+					w.Header().Get(RateLimitRemainingHeader) != "0" || w.Header().Get(RateLimitResetHeader) == "") {
+					t.Errorf("request %d\nExpected: Retry-After and RateLimit headers\nGot: %v", i, w.Header())
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	rateLimit, err := NewRateLimit(ratelimit.NewMemory(), RateLimitConfig{
+		Rules:          []RateLimitRule{{Name: "client", By: RateLimitByIP, Rate: 0.1, Burst: 5}},
+		TrustedProxies: 1,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+	}
+	handler := rateLimit(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/persons", nil)
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	expected := map[string]string{
+		RateLimitLimitHeader:     "5",
+		RateLimitRemainingHeader: "4",
+		RateLimitResetHeader:     "10",
+		"Retry-After":            "",
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("%s\nExpected: %q\nGot: %q", header, value, got)
+		}
+	}
+}
+
+// TestRateLimitForwardedFor sends requests with X-Forwarded-For entries set by the client left of the ones
+// the proxies append, which must not give the client a bucket of its own.
+func TestRateLimitForwardedFor(t *testing.T) {
+	tests := map[string]struct {
+		trustedProxies int
+		forwarded      [][]string
+		statuses       []int
+	}{
+		"spoofed leftmost": {
+			trustedProxies: 1,
+			forwarded:      [][]string{{"198.51.100.1, 203.0.113.7"}, {"198.51.100.2, 203.0.113.7"}},
+			statuses:       []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		"two proxies": {
+			trustedProxies: 2,
+			forwarded:      [][]string{{"198.51.100.1, 203.0.113.7, 10.0.0.1"}, {"203.0.113.7", "10.0.0.2"}},
+			statuses:       []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		"different clients": {
+			trustedProxies: 1,
+			forwarded:      [][]string{{"203.0.113.7, 203.0.113.8"}, {"203.0.113.8, 203.0.113.7"}},
+			statuses:       []int{http.StatusOK, http.StatusOK},
+		},
+		"not trusted": {
+			trustedProxies: 0,
+			forwarded:      [][]string{{"203.0.113.7"}, {"203.0.113.8"}},
+			statuses:       []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rateLimit, err := NewRateLimit(ratelimit.NewMemory(), RateLimitConfig{
+				Rules:          []RateLimitRule{{Name: "client", By: RateLimitByIP, Rate: 0.1, Burst: 1}},
+				TrustedProxies: test.trustedProxies,
+			}, zap.NewNop())
+			if err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+			}
+			handler := rateLimit(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+			for i, forwarded := range test.forwarded {
+				r := httptest.NewRequest(http.MethodGet, "/api/v1/persons", nil)
+				for _, value := range forwarded {
+					r.Header.Add("X-Forwarded-For", value)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				if w.Code != test.statuses[i] {
+					t.Errorf("request %d\nExpected: %d\nGot: %d", i, test.statuses[i], w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitFailOpen(t *testing.T) {
+	rateLimit, err := NewRateLimit(failingStore{}, RateLimitConfig{
+		Rules: []RateLimitRule{{Name: "client", By: RateLimitByIP, Rate: 1, Burst: 1}},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+	}
+	handler := rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/persons", nil))
+		if w.Code != http.StatusNoContent || w.Header().Get(RateLimitLimitHeader) != "" {
+			t.Errorf("\nExpected: %d without RateLimit headers\nGot: %d %v", http.StatusNoContent, w.Code, w.Header())
+		}
+	}
+}
+
+func TestNewRateLimitInvalidRules(t *testing.T) {
+	tests := map[string][]RateLimitRule{
+		"no name":        {{By: RateLimitByIP, Rate: 1, Burst: 1}},
+		"duplicate name": {{Name: "a", By: RateLimitByIP, Rate: 1, Burst: 1}, {Name: "a", By: RateLimitByIP, Rate: 1, Burst: 1}},
+		"unknown by":     {{Name: "a", By: "user", Rate: 1, Burst: 1}},
+		"zero rate":      {{Name: "a", By: RateLimitByIP, Burst: 1}},
+		"zero burst":     {{Name: "a", By: RateLimitByIP, Rate: 1}},
+	}
+
+	for name, rules := range tests {
+		rules := rules
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := NewRateLimit(ratelimit.NewMemory(), RateLimitConfig{Rules: rules}, zap.NewNop()); err == nil {
+				t.Errorf("\nExpected: error\nGot: %v", err)
+			}
+		})
+	}
+}
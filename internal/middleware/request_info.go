@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/tracing"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// ActorHeader names the user on whose behalf the request is made, it is set by the authenticating proxy.
+	ActorHeader = "X-Actor"
+)
+
+const maxHeaderValueLength = 128
+
+// NewRequestInfo stores the actor, the request id and a logger that reports both, and the trace if there is one,
+// into the request context.
+// A request id sent by the client is kept, otherwise a random one is generated; either way it is echoed
+// in the response.
+func NewRequestInfo(log *zap.Logger) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validHeaderValue(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := requestinfo.WithRequestID(r.Context(), requestID)
+			requestLog := log.With(zap.String("request_id", requestID))
+			if actor := r.Header.Get(ActorHeader); validHeaderValue(actor) {
+				ctx = requestinfo.WithActor(ctx, actor)
+				requestLog = requestLog.With(zap.String("actor", actor))
+			}
+			// The trace of the request, started by NewTracing, lets its logs be found from its spans.
+			if sc := tracing.SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+				requestLog = requestLog.With(zap.String("trace_id", sc.TraceID.String()),
+					zap.String("span_id", sc.SpanID.String()))
+			}
+			ctx = requestinfo.WithLogger(ctx, requestLog)
+
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validHeaderValue accepts non-empty values of printable ASCII characters that are short enough to be logged.
+func validHeaderValue(value string) bool {
+	return value != "" && len(value) <= maxHeaderValueLength && printable(value)
+}
+
+func printable(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < 0x20 || value[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
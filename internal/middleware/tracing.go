@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/tracing"
+)
+
+// NewTracing starts the server span of every request, continuing the trace of the traceparent header if there
+// is a valid one. It wraps the router, TraceRoute names the span once the route is matched.
+func NewTracing(tracer *tracing.Tracer) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracer.Start(ctx, r.Method, tracing.SpanKindServer,
+				tracing.String("http.request.method", r.Method),
+				tracing.String("url.path", r.URL.Path),
+				tracing.String("network.protocol.version", r.Proto),
+				tracing.String("client.address", clientIP(r, 0)),
+				tracing.String("user_agent.original", r.UserAgent()))
+
+			rw := &responseWriter{ResponseWriter: w}
+			defer func() {
+				status := rw.statusCode()
+				span.SetAttributes(tracing.Int64("http.response.status_code", int64(status)))
+				if status >= http.StatusInternalServerError {
+					span.RecordError(statusError(status))
+				}
+				span.End()
+			}()
+
+			handler.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+// TraceRoute names the server span after the path template of the matched route, such as
+// GET /api/v1/persons/{id}. It must be used as a router middleware.
+func TraceRoute(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				span := tracing.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + template)
+				span.SetAttributes(tracing.String("http.route", template))
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type statusError int
+
+func (e statusError) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/tracing"
+)
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *spanRecorder) Export(_ context.Context, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *spanRecorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	type testCase struct {
+		traceparent string
+		status      int
+		failed      bool
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := map[string]testCase{
+		"new trace": {
+			status: http.StatusNotFound,
+		},
+		"continued trace": {
+			traceparent: "00-" + traceID + "-00f067aa0ba902b7-01",
+			status:      http.StatusOK,
+		},
+		"server error": {
+			status: http.StatusInternalServerError,
+			failed: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			exporter := &spanRecorder{}
+			tracer := tracing.NewTracer(exporter, 1, zap.NewNop())
+			core, logs := observer.New(zapcore.DebugLevel)
+
+			router := mux.NewRouter()
+			router.Use(TraceRoute)
+			router.HandleFunc("/api/v1/persons/{id}", func(w http.ResponseWriter, r *http.Request) {
+				requestinfo.Logger(r.Context(), zap.NewNop()).Info("Handled")
+				w.WriteHeader(test.status)
+			}).Methods(http.MethodGet)
+			handler := NewTracing(tracer)(NewRequestInfo(zap.New(core))(router))
+
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/persons/1", nil)
+			if test.traceparent != "" {
+				r.Header.Set(tracing.TraceparentHeader, test.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			if err := tracer.Shutdown(context.Background()); err != nil {
+				t.Fatalf("\nExpected: nil\nGot: %s", err)
+			}
+
+			if len(exporter.spans) != 1 {
+				t.Fatalf("\nExpected: 1 span\nGot: %+v", exporter.spans)
+			}
+			span := exporter.spans[0]
+			if span.Name != "GET /api/v1/persons/{id}" || span.Kind != tracing.SpanKindServer {
+				t.Errorf("\nExpected: %s\nGot: %s", "GET /api/v1/persons/{id}", span.Name)
+			}
+			if test.traceparent != "" && (span.SpanContext.TraceID.String() != traceID || !span.Parent.IsValid()) {
+				t.Errorf("\nExpected: child in trace %s\nGot: %+v", traceID, span)
+			}
+			if (span.Status == tracing.StatusError) != test.failed {
+				t.Errorf("\nExpected: failed %t\nGot: %+v", test.failed, span)
+			}
+			attributes := make(map[string]any, len(span.Attributes))
+			for _, attribute := range span.Attributes {
+				attributes[attribute.Key] = attribute.Value
+			}
+			if attributes["http.route"] != "/api/v1/persons/{id}" ||
+				attributes["http.response.status_code"] != int64(test.status) {
+				t.Errorf("\nExpected: route and status %d\nGot: %v", test.status, attributes)
+			}
+
+			entries := logs.FilterField(zap.String("trace_id", span.SpanContext.TraceID.String())).
+				FilterField(zap.String("span_id", span.SpanContext.SpanID.String()))
+			if entries.Len() != 1 {
+				t.Errorf("\nExpected: entry with trace id\nGot: %v", logs.AllUntimed())
+			}
+		})
+	}
+}
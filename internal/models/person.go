@@ -1,9 +1,15 @@
 package models
 
+import "time"
+
 type Person struct {
-	ID      int64  `json:"id"`
-	Name    string `json:"name"`
-	Age     int    `json:"age"`
-	Address string `json:"address"`
-	Work    string `json:"work"`
+	ID      int64  `json:"id" db:"id"`
+	Name    string `json:"name" db:"name"`
+	Age     int    `json:"age" db:"age"`
+	Address string `json:"address" db:"address"`
+	Work    string `json:"work" db:"work"`
+	// Version is bumped on every update, it is exposed as the ETag of the person resource.
+	Version int64 `json:"-" db:"version"`
+	// DeletedAt is set while the person is soft deleted, such persons are only listed on request.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Operations recorded in the audit trail of a person.
+const (
+	OperationCreate  = "create"
+	OperationUpdate  = "update"
+	OperationDelete  = "delete"
+	OperationRestore = "restore"
+)
+
+// PersonChange is an entry of the append-only audit trail of a person.
+type PersonChange struct {
+	ID        int64  `json:"id" db:"id"`
+	PersonID  int64  `json:"person_id" db:"person_id"`
+	Operation string `json:"operation" db:"operation"`
+	// Before and After hold the person fields touched by the change: all of them after a create or restore
+	// and before a delete, only the changed ones around an update.
+	Before    json.RawMessage `json:"before,omitempty" db:"before"`
+	After     json.RawMessage `json:"after,omitempty" db:"after"`
+	Actor     string          `json:"actor" db:"actor"`
+	RequestID string          `json:"request_id" db:"request_id"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
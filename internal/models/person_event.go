@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Types of the domain events published about persons.
+const (
+	EventPersonCreated  = "PersonCreated"
+	EventPersonUpdated  = "PersonUpdated"
+	EventPersonDeleted  = "PersonDeleted"
+	EventPersonRestored = "PersonRestored"
+)
+
+// PersonEvent is a domain event queued in the outbox along with the change it reports.
+type PersonEvent struct {
+	ID       int64  `json:"id" db:"id"`
+	Type     string `json:"type" db:"event_type"`
+	PersonID int64  `json:"person_id" db:"person_id"`
+	// Payload is the audit trail entry of the change, see PersonChange.
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	Attempts  int             `json:"attempts" db:"attempts"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is a subscription of a client to person events.
+type Webhook struct {
+	ID  int64  `json:"id" db:"id"`
+	URL string `json:"url" db:"url"`
+	// EventTypes filters the delivered events, empty means all of them.
+	EventTypes []string `json:"event_types" db:"event_types"`
+	// Secret signs the deliveries, it is never returned to clients.
+	Secret    string    `json:"-" db:"secret"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryDead marks deliveries that are not retried any more after too many failed attempts.
+	DeliveryDead = "dead"
+)
+
+// WebhookDelivery is an event sent or to be sent to a webhook.
+type WebhookDelivery struct {
+	ID        int64           `json:"id" db:"id"`
+	WebhookID int64           `json:"webhook_id" db:"webhook_id"`
+	EventID   int64           `json:"event_id" db:"event_id"`
+	EventType string          `json:"event_type" db:"event_type"`
+	Payload   json.RawMessage `json:"-" db:"payload"`
+	Status    string          `json:"status" db:"status"`
+	Attempts  int             `json:"attempts" db:"attempts"`
+	// ResponseStatus and LastError describe the last attempt, both are empty if the receiver could not be reached.
+	ResponseStatus *int       `json:"response_status" db:"response_status"`
+	LastError      *string    `json:"last_error" db:"last_error"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at" db:"delivered_at"`
+}
@@ -0,0 +1,283 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+const (
+	batchModeParam      = "mode"
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+
+	maxBatchSize = 1000
+)
+
+func parseBatchMode(queryParams url.Values) (pPersons.BatchMode, error) {
+	switch queryParams.Get(batchModeParam) {
+	case "", batchModeAtomic:
+		return pPersons.BatchAtomic, nil
+	case batchModeBestEffort:
+		return pPersons.BatchBestEffort, nil
+	default:
+		return 0, errors.Wrapf(pErrors.ErrBadQueryParam, "%s must be %s or %s",
+			batchModeParam, batchModeAtomic, batchModeBestEffort)
+	}
+}
+
+// readBatch decodes a JSON array of batch items from the request body.
+func readBatch[T any](r *http.Request, del *delivery) ([]T, error) {
+	body, err := pHTTP.ReadBody(r, del.logger(r))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	err = json.Unmarshal(body, &items)
+	if err != nil {
+		return nil, pErrors.ErrReadBody
+	}
+	if len(items) > maxBatchSize {
+		return nil, errors.Wrapf(pErrors.ErrBatchTooLarge, "batch can contain at most %d items", maxBatchSize)
+	}
+	return items, nil
+}
+
+// runBatch validates every item and passes the valid ones to apply. Invalid items of an atomic batch
+// abort it before anything is written, in best-effort mode they are just skipped.
+// The results are in the order of items.
+func runBatch[T any](items []T, mode pPersons.BatchMode, validate func(item *T) error,
+	apply func(valid []T) ([]pPersons.BatchResult, error)) ([]pPersons.BatchResult, error) {
+	results := make([]pPersons.BatchResult, len(items))
+	valid := make([]T, 0, len(items))
+	indexes := make([]int, 0, len(items))
+	for i := range items {
+		if err := validate(&items[i]); err != nil {
+			results[i].Err = err
+			continue
+		}
+		valid = append(valid, items[i])
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) < len(items) && mode == pPersons.BatchAtomic {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = pErrors.ErrBatchAborted
+			}
+		}
+		return results, nil
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	applied, err := apply(valid)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range applied {
+		results[indexes[i]] = result
+	}
+	return results, nil
+}
+
+func newBatchResponse(results []pPersons.BatchResult, successStatus int) *batchResponse {
+	response := &batchResponse{
+		Results: make([]batchItemResult, len(results)),
+	}
+	for i, result := range results {
+		item := batchItemResult{
+			Index:  i,
+			Status: successStatus,
+		}
+		if result.Err != nil {
+			errCause := errors.Cause(result.Err)
+			item.Status, _ = pErrors.GetHTTPCodeByError(errCause)
+			item.Error = errCause.Error()
+			var validationErrs validation.Errors
+			if errors.As(result.Err, &validationErrs) {
+				item.Errors = validationErrs
+			}
+		} else if result.Person != nil {
+			item.Person = newGetResponse(result.Person)
+			item.ETag = formatETag(result.Person.Version)
+		}
+		response.Results[i] = item
+	}
+	return response
+}
+
+// createBatch godoc
+//
+//	@Summary		Create persons in batch
+//	@Description	Creates every person of the array. In atomic mode either all persons are created or none of them,
+//	@Description	in best_effort mode each person is created independently.
+//	@Description	The response has a result with its own status for each item, in the order of the items.
+//	@Tags			persons
+//	@Accept			json
+//	@Produce		json
+//	@Param			mode				query		string			false	"atomic (default) or best_effort"
+//	@Param			PersonsCreateData	body		[]createRequest	true	"Persons to create"
+//	@Success		200					{object}	batchResponse	"Per-item results"
+//	@Failure		400					{object}	http.JSONError
+//	@Failure		401					{object}	http.JSONError
+//	@Failure		413					{object}	http.JSONError	"More than 1000 items"
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons:batch [post]
+//
+//	@Security		cookieAuth
+func (del *delivery) createBatch(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBatchMode(r.URL.Query())
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	requests, err := readBatch[createRequest](r, del)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	results, err := runBatch(requests, mode, (*createRequest).validate,
+		func(valid []createRequest) ([]pPersons.BatchResult, error) {
+			params := make([]pPersons.CreateParams, len(valid))
+			for i, request := range valid {
+				params[i] = pPersons.CreateParams{
+					Name:    request.Name,
+					Age:     request.Age,
+					Address: request.Address,
+					Work:    request.Work,
+				}
+			}
+			return del.repo.CreateBatch(r.Context(), params, mode)
+		})
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	response := newBatchResponse(results, http.StatusCreated)
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
+}
+
+// partialUpdateBatch godoc
+//
+//	@Summary		Partial update of persons in batch
+//	@Description	Applies every partial update of the array, an item may require the person to still have
+//	@Description	the given version. Modes and results are the same as for batch create.
+//	@Tags			persons
+//	@Accept			json
+//	@Produce		json
+//	@Param			mode				query		string					false	"atomic (default) or best_effort"
+//	@Param			PersonsUpdateData	body		[]batchUpdateRequest	true	"Persons data to update"
+//	@Success		200					{object}	batchResponse			"Per-item results"
+//	@Failure		400					{object}	http.JSONError
+//	@Failure		401					{object}	http.JSONError
+//	@Failure		413					{object}	http.JSONError			"More than 1000 items"
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons:batch [patch]
+//
+//	@Security		cookieAuth
+func (del *delivery) partialUpdateBatch(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBatchMode(r.URL.Query())
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	requests, err := readBatch[batchUpdateRequest](r, del)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	results, err := runBatch(requests, mode, (*batchUpdateRequest).validate,
+		func(valid []batchUpdateRequest) ([]pPersons.BatchResult, error) {
+			params := make([]pPersons.PartialUpdateParams, len(valid))
+			for i, request := range valid {
+				params[i] = pPersons.PartialUpdateParams{
+					ID:              request.ID,
+					Name:            request.Name,
+					Age:             request.Age,
+					Address:         request.Address,
+					Work:            request.Work,
+					ExpectedVersion: request.Version,
+				}
+			}
+			return del.repo.PartialUpdateBatch(r.Context(), params, mode)
+		})
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	response := newBatchResponse(results, http.StatusOK)
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
+}
+
+// deleteBatch godoc
+//
+//	@Summary		Delete persons in batch
+//	@Description	Deletes persons by the array of ids. Modes and results are the same as for batch create.
+//	@Tags			persons
+//	@Accept			json
+//	@Produce		json
+//	@Param			mode		query		string			false	"atomic (default) or best_effort"
+//	@Param			PersonIDs	body		[]int64			true	"Ids of persons to delete"
+//	@Success		200			{object}	batchResponse	"Per-item results"
+//	@Failure		400			{object}	http.JSONError
+//	@Failure		401			{object}	http.JSONError
+//	@Failure		413			{object}	http.JSONError	"More than 1000 items"
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons:batch [delete]
+//
+//	@Security		cookieAuth
+func (del *delivery) deleteBatch(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBatchMode(r.URL.Query())
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	personIDs, err := readBatch[int64](r, del)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	seen := make(map[int64]struct{}, len(personIDs))
+	validate := func(personID *int64) error {
+		if *personID <= 0 {
+			return validation.Errors{"id": "must be positive"}
+		}
+		if _, ok := seen[*personID]; ok {
+			return validation.Errors{"id": "is duplicated"}
+		}
+		seen[*personID] = struct{}{}
+		return nil
+	}
+
+	results, err := runBatch(personIDs, mode, validate, func(valid []int64) ([]pPersons.BatchResult, error) {
+		return del.repo.DeleteBatch(r.Context(), valid, mode)
+	})
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	response := newBatchResponse(results, http.StatusNoContent)
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
+}
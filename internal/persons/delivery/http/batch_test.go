@@ -0,0 +1,88 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+func TestRunBatch(t *testing.T) {
+	type testCase struct {
+		items   []int64
+		mode    pPersons.BatchMode
+		applied []int64
+		errs    []error
+	}
+
+	tests := map[string]testCase{
+		"all valid": {
+			items:   []int64{1, 2},
+			mode:    pPersons.BatchAtomic,
+			applied: []int64{1, 2},
+			errs:    []error{nil, nil},
+		},
+		"atomic with invalid item": {
+			items:   []int64{1, -2, 3},
+			mode:    pPersons.BatchAtomic,
+			applied: nil,
+			errs:    []error{pErrors.ErrBatchAborted, pErrors.ErrInvalidData, pErrors.ErrBatchAborted},
+		},
+		"best effort with invalid item": {
+			items:   []int64{1, -2, 3},
+			mode:    pPersons.BatchBestEffort,
+			applied: []int64{1, 3},
+			errs:    []error{nil, pErrors.ErrInvalidData, nil},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			validate := func(item *int64) error {
+				if *item <= 0 {
+					return validation.Errors{"id": "must be positive"}
+				}
+				return nil
+			}
+
+			var applied []int64
+			results, err := runBatch(test.items, test.mode, validate,
+				func(valid []int64) ([]pPersons.BatchResult, error) {
+					applied = valid
+					results := make([]pPersons.BatchResult, len(valid))
+					for i, id := range valid {
+						results[i].Person = &models.Person{ID: id}
+					}
+					return results, nil
+				})
+			if err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+			}
+
+			if len(applied) != len(test.applied) {
+				t.Fatalf("\nExpected: %v\nGot: %v", test.applied, applied)
+			}
+			for i := range test.applied {
+				if applied[i] != test.applied[i] {
+					t.Errorf("\nExpected: %v\nGot: %v", test.applied, applied)
+				}
+			}
+
+			for i, result := range results {
+				if !errors.Is(result.Err, test.errs[i]) {
+					t.Errorf("\nItem %d\nExpected: %s\nGot: %s", i, test.errs[i], result.Err)
+				}
+				if test.errs[i] == nil && (result.Person == nil || result.Person.ID != test.items[i]) {
+					t.Errorf("\nItem %d\nExpected: person %d\nGot: %v", i, test.items[i], result.Person)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+const sortParam = "sort"
+
+// reservedParams are list query parameters that are not filters.
+var reservedParams = map[string]struct{}{
+	"limit":             {},
+	"offset":            {},
+	"cursor":            {},
+	sortParam:           {},
+	formatParam:         {},
+	fieldsParam:         {},
+	includeDeletedParam: {},
+}
+
+// operators are ordered so that two-character operators are matched before their one-character prefixes.
+var operators = []pPersons.Operator{
+	pPersons.OpContains,
+	pPersons.OpGe,
+	pPersons.OpLe,
+	pPersons.OpNe,
+	pPersons.OpGt,
+	pPersons.OpLt,
+	pPersons.OpEq,
+}
+
+var allowedOperators = map[pPersons.FieldType]map[pPersons.Operator]struct{}{
+	pPersons.FieldInt: {
+		pPersons.OpEq: {}, pPersons.OpNe: {},
+		pPersons.OpGt: {}, pPersons.OpGe: {},
+		pPersons.OpLt: {}, pPersons.OpLe: {},
+	},
+	pPersons.FieldString: {
+		pPersons.OpEq: {}, pPersons.OpNe: {},
+		pPersons.OpContains: {},
+	},
+}
+
+// parseCriteria turns a raw query such as "name~=ann&age>=18&sort=-age,name" into list criteria.
+// The raw query is used because url.ParseQuery would split "age>=18" into the key "age>" and the value "18",
+// and keep "age>18" as a key without a value.
+func parseCriteria(rawQuery string) (pPersons.Criteria, error) {
+	var criteria pPersons.Criteria
+	errs := validation.Errors{}
+
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+
+		i := strings.IndexAny(part, "~<>!=")
+		if i < 0 {
+			i = len(part)
+		}
+		field, err := url.QueryUnescape(part[:i])
+		if err != nil {
+			errs[part] = "is not properly escaped"
+			continue
+		}
+
+		var op pPersons.Operator
+		for _, candidate := range operators {
+			if strings.HasPrefix(part[i:], string(candidate)) {
+				op = candidate
+				break
+			}
+		}
+		value, err := url.QueryUnescape(strings.TrimPrefix(part[i:], string(op)))
+		if err != nil {
+			errs[field] = "is not properly escaped"
+			continue
+		}
+
+		if field == sortParam {
+			criteria.Sort, err = parseSort(value)
+			if err != nil {
+				errs[field] = err.Error()
+			}
+			continue
+		}
+		if _, ok := reservedParams[field]; ok {
+			continue
+		}
+
+		condition, err := parseCondition(field, op, value)
+		if err != nil {
+			errs[field] = err.Error()
+			continue
+		}
+		criteria.Conditions = append(criteria.Conditions, condition)
+	}
+
+	if len(errs) > 0 {
+		return pPersons.Criteria{}, errs
+	}
+	return criteria, nil
+}
+
+func parseCondition(field string, op pPersons.Operator, value string) (pPersons.Condition, error) {
+	fieldType, ok := pPersons.Fields[field]
+	if !ok {
+		return pPersons.Condition{}, fmt.Errorf("unknown field")
+	}
+	if op == "" {
+		return pPersons.Condition{}, fmt.Errorf("operator is missing")
+	}
+	if _, ok = allowedOperators[fieldType][op]; !ok {
+		return pPersons.Condition{}, fmt.Errorf("operator %s is not supported", op)
+	}
+
+	condition := pPersons.Condition{
+		Field: field,
+		Op:    op,
+		Value: value,
+	}
+	if fieldType == pPersons.FieldInt {
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return pPersons.Condition{}, fmt.Errorf("must be an integer")
+		}
+		condition.Value = number
+	}
+	return condition, nil
+}
+
+// parseSort parses a comma separated field list where a "-" prefix means descending order.
+func parseSort(value string) ([]pPersons.SortField, error) {
+	var sort []pPersons.SortField
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		field := pPersons.SortField{
+			Field: strings.TrimLeft(item, "+-"),
+			Desc:  strings.HasPrefix(item, "-"),
+		}
+		if _, ok := pPersons.Fields[field.Field]; !ok {
+			return nil, fmt.Errorf("unknown field %q", field.Field)
+		}
+		sort = append(sort, field)
+	}
+	return sort, nil
+}
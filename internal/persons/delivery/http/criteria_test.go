@@ -0,0 +1,71 @@
+package http
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+func TestParseCriteria(t *testing.T) {
+	type testCase struct {
+		rawQuery string
+		criteria pPersons.Criteria
+		errs     validation.Errors
+	}
+
+	tests := map[string]testCase{
+		"filters and sort": {
+			rawQuery: "name~=ann&age>=18&age<65&work=Yandex%20LLC&sort=-age,name&limit=10&cursor=",
+			criteria: pPersons.Criteria{
+				Conditions: []pPersons.Condition{
+					{Field: "name", Op: pPersons.OpContains, Value: "ann"},
+					{Field: "age", Op: pPersons.OpGe, Value: int64(18)},
+					{Field: "age", Op: pPersons.OpLt, Value: int64(65)},
+					{Field: "work", Op: pPersons.OpEq, Value: "Yandex LLC"},
+				},
+				Sort: []pPersons.SortField{{Field: "age", Desc: true}, {Field: "name"}},
+			},
+		},
+		"empty query": {
+			rawQuery: "",
+			criteria: pPersons.Criteria{},
+		},
+		"bad fields and operators": {
+			rawQuery: "salary>100&name>=a&age=old&id&sort=height",
+			errs: validation.Errors{
+				"salary": "unknown field",
+				"name":   "operator >= is not supported",
+				"age":    "must be an integer",
+				"id":     "operator is missing",
+				"sort":   `unknown field "height"`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			criteria, err := parseCriteria(test.rawQuery)
+			if test.errs == nil {
+				if err != nil {
+					t.Fatalf("\nExpected: nil\nGot: %s", err)
+				}
+				if !reflect.DeepEqual(criteria, test.criteria) {
+					t.Errorf("\nExpected: %+v\nGot: %+v", test.criteria, criteria)
+				}
+				return
+			}
+
+			var errs validation.Errors
+			if !errors.As(err, &errs) || !reflect.DeepEqual(errs, test.errs) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.errs, err)
+			}
+		})
+	}
+}
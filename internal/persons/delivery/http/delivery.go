@@ -4,14 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/importer"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/stream"
 	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/cursor"
 	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
 	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/jsonpatch"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
 
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
@@ -19,25 +28,61 @@ const (
 	personsPrefix = "/persons"
 
 	personsPath = constants.ApiPrefix + personsPrefix
-	personPath  = personsPath + "/{id}"
+	personPath  = personsPath + "/{id:[0-9]+}"
+	restorePath = personPath + ":restore"
+	historyPath = personPath + "/history"
+	searchPath  = personsPath + "/search"
+	streamPath  = personsPath + "/stream"
+	batchPath   = personsPath + ":batch"
+	exportPath  = personsPath + "/export"
+	importPath  = personsPath + "/import"
 )
 
+const (
+	defaultPageLimit   = 50
+	maxPageLimit       = 1000
+	defaultSearchLimit = 20
+)
+
+const includeDeletedParam = "include_deleted"
+
 type delivery struct {
-	repo pPersons.Repository
-	log  *zap.Logger
+	repo       pPersons.Repository
+	importer   *importer.Importer
+	cursors    *cursor.Codec
+	hub        *stream.Hub
+	adminToken string
+	log        *zap.Logger
 }
 
-func RegisterHandlers(mux *mux.Router, repo pPersons.Repository, log *zap.Logger) {
+// RegisterHandlers registers the persons API. The idempotent middleware guards the creation of persons
+// against duplicates when clients retry.
+func RegisterHandlers(mux *mux.Router, repo pPersons.Repository, cursors *cursor.Codec, hub *stream.Hub,
+	idempotent func(handler http.Handler) http.Handler, adminToken string, log *zap.Logger) {
 	del := delivery{
-		repo: repo,
-		log:  log,
+		repo:       repo,
+		importer:   importer.New(repo, log),
+		cursors:    cursors,
+		hub:        hub,
+		adminToken: adminToken,
+		log:        log,
 	}
 
-	mux.HandleFunc(personsPath, del.create).Methods(http.MethodPost)
+	mux.Handle(personsPath, idempotent(http.HandlerFunc(del.create))).Methods(http.MethodPost)
 	mux.HandleFunc(personPath, del.get).Methods(http.MethodGet)
 	mux.HandleFunc(personsPath, del.list).Methods(http.MethodGet)
+	mux.HandleFunc(searchPath, del.search).Methods(http.MethodGet)
+	mux.HandleFunc(streamPath, del.stream).Methods(http.MethodGet)
+	mux.HandleFunc(exportPath, del.export).Methods(http.MethodGet)
+	mux.HandleFunc(importPath, del.importPersons).Methods(http.MethodPost)
+	mux.HandleFunc(personPath, del.replace).Methods(http.MethodPut)
 	mux.HandleFunc(personPath, del.partialUpdate).Methods(http.MethodPatch)
 	mux.HandleFunc(personPath, del.delete).Methods(http.MethodDelete)
+	mux.HandleFunc(restorePath, del.restore).Methods(http.MethodPost)
+	mux.HandleFunc(historyPath, del.history).Methods(http.MethodGet)
+	mux.HandleFunc(batchPath, del.createBatch).Methods(http.MethodPost)
+	mux.HandleFunc(batchPath, del.partialUpdateBatch).Methods(http.MethodPatch)
+	mux.HandleFunc(batchPath, del.deleteBatch).Methods(http.MethodDelete)
 }
 
 // create godoc
@@ -49,14 +94,17 @@ func RegisterHandlers(mux *mux.Router, repo pPersons.Repository, log *zap.Logger
 //	@Produce		json
 //	@Param			id				path		int				true	"Workspace ID"
 //	@Param			PersonCreateData	body		createRequest	true	"Person create data"
+//	@Param			Idempotency-Key	header		string			false	"Key to retry the request safely with"
 //	@Success		200				{object}	createResponse	"Created person data."
-//	@Failure		400				{object}	http.JSONError
+//	@Failure		400				{object}	http.ValidationErrorResponse
 //	@Failure		401				{object}	http.JSONError
+//	@Failure		409				{object}	http.JSONError	"Request with the same key is in progress"
+//	@Failure		422				{object}	http.JSONError	"Key was used with another request body"
 //	@Failure		405
 //	@Failure		500
 //	@Router			/workspaces/{id}/persons [post]
 func (del *delivery) create(w http.ResponseWriter, r *http.Request) {
-	body, err := pHTTP.ReadBody(r, del.log)
+	body, err := pHTTP.ReadBody(r, del.logger(r))
 	if err != nil {
 		pHTTP.HandleError(w, r, err)
 		return
@@ -69,6 +117,12 @@ func (del *delivery) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = request.validate()
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
 	params := pPersons.CreateParams{
 		Name:    request.Name,
 		Age:     request.Age,
@@ -92,11 +146,14 @@ func (del *delivery) create(w http.ResponseWriter, r *http.Request) {
 //	@Description	Returns person by id
 //	@Tags			persons
 //	@Produce		json
-//	@Param			id	path		int			true	"Person ID"
-//	@Success		200	{object}	getResponse	"Person data"
-//	@Failure		400	{object}	http.JSONError
-//	@Failure		401	{object}	http.JSONError
-//	@Failure		404	{object}	http.JSONError
+//	@Param			id				path		int			true	"Person ID"
+//	@Param			If-None-Match	header		string		false	"ETag of a cached copy"
+//	@Success		200				{object}	getResponse	"Person data"
+//	@Header			200				{string}	ETag		"Person version"
+//	@Success		304				"Cached copy is up to date"
+//	@Failure		400				{object}	http.JSONError
+//	@Failure		401				{object}	http.JSONError
+//	@Failure		404				{object}	http.JSONError
 //	@Failure		405
 //	@Failure		500
 //	@Router			/persons/{id} [get]
@@ -114,20 +171,36 @@ func (del *delivery) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set(etagHeader, formatETag(person.Version))
+	if notModified(r, person.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	response := newGetResponse(person)
 	pHTTP.SendJSON(w, r, http.StatusOK, response)
 }
 
 // list godoc
 //
-//	@Summary		Returns persons by workspace id
-//	@Description	Returns persons by workspace id
+//	@Summary		Returns persons
+//	@Description	Returns all persons ordered by id. Passing the cursor parameter (empty for the first page)
+//	@Description	switches to keyset pagination and wraps the page into listResponse.
+//	@Description	Any other parameter is a filter <field><op><value> on id, name, age, address or work,
+//	@Description	where op is one of =, !=, >, >=, <, <= for numbers and =, !=, ~= (contains) for strings.
+//	@Description	Deleted persons are hidden unless an admin passes include_deleted=true.
 //	@Tags			persons
 //	@Produce		json
-//	@Param			title	query		string			true	"Title filter"
-//	@Success		200		{object}	listResponse	"Persons data"
-//	@Failure		400		{object}	http.JSONError
-//	@Failure		401		{object}	http.JSONError
+//	@Param			limit			query		int				false	"Page size"
+//	@Param			offset			query		int				false	"Number of persons to skip (offset mode only)"
+//	@Param			cursor			query		string			false	"next_cursor or prev_cursor of the previous page"
+//	@Param			sort			query		string			false	"Comma separated fields, '-' prefix for descending order, e.g. -age,name"
+//	@Param			include_deleted	query		bool			false	"List deleted persons too, admin only"
+//	@Param			Authorization	header		string			false	"Bearer admin token"
+//	@Success		200				{object}	listResponse	"Persons data"
+//	@Failure		400				{object}	http.JSONError
+//	@Failure		401				{object}	http.JSONError
+//	@Failure		403				{object}	http.JSONError	"include_deleted without admin token"
 //	@Failure		405
 //	@Failure		500
 //	@Router			/persons [get]
@@ -135,46 +208,226 @@ func (del *delivery) get(w http.ResponseWriter, r *http.Request) {
 //	@Security		cookieAuth
 func (del *delivery) list(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
-	var err error
-	var limit int64 = 0
-	if queryParams.Get("limit") != "" {
-		limit, err = strconv.ParseInt(queryParams.Get("limit"), 10, 64)
-		if err != nil {
-			pHTTP.HandleError(w, r, err)
+	limit, err := parseInt64Param(queryParams, "limit")
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	criteria, err := parseCriteria(r.URL.RawQuery)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	includeDeleted, err := parseBoolParam(queryParams, includeDeletedParam)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+	if includeDeleted && !pHTTP.IsAdmin(r, del.adminToken) {
+		pHTTP.HandleError(w, r, errors.Wrapf(pErrors.ErrForbidden, "%s is for admins only", includeDeletedParam))
+		return
+	}
+
+	if queryParams.Has("cursor") {
+		if len(criteria.Sort) > 0 {
+			pHTTP.HandleError(w, r, validation.Errors{sortParam: "is not supported with cursor pagination"})
 			return
 		}
+		del.listPage(w, r, queryParams.Get("cursor"), limit, criteria, includeDeleted)
+		return
+	}
+
+	offset, err := parseInt64Param(queryParams, "offset")
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	persons, err := del.repo.List(r.Context(), &pPersons.ListParams{
+		Offset:         offset,
+		Limit:          limit,
+		Criteria:       criteria,
+		IncludeDeleted: includeDeleted,
+	})
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
 	}
-	var offset int64 = 0
-	if queryParams.Get("offset") != "" {
-		offset, err = strconv.ParseInt(queryParams.Get("offset"), 10, 64)
+
+	pHTTP.SendJSON(w, r, http.StatusOK, persons)
+}
+
+// listPage serves one keyset page. One extra person is requested to find out whether there is a page beyond this one.
+func (del *delivery) listPage(w http.ResponseWriter, r *http.Request, token string, limit int64,
+	criteria pPersons.Criteria, includeDeleted bool) {
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	var current pageCursor
+	if token != "" {
+		err := del.cursors.Decode(pageCursorPurpose, token, &current)
 		if err != nil {
 			pHTTP.HandleError(w, r, err)
 			return
 		}
 	}
 
-	persons, err := del.repo.List(r.Context(), offset, limit)
+	persons, err := del.repo.List(r.Context(), &pPersons.ListParams{
+		Limit: limit + 1,
+		Cursor: &pPersons.Cursor{
+			ID:       current.ID,
+			Backward: current.Backward,
+		},
+		Criteria:       criteria,
+		IncludeDeleted: includeDeleted,
+	})
 	if err != nil {
 		pHTTP.HandleError(w, r, err)
 		return
 	}
 
-	//response := newListResponse(persons)
-	pHTTP.SendJSON(w, r, http.StatusOK, persons)
+	hasMore := int64(len(persons)) > limit
+	if hasMore {
+		if current.Backward {
+			persons = persons[1:]
+		} else {
+			persons = persons[:limit]
+		}
+	}
+
+	var next, prev *pageCursor
+	if len(persons) > 0 {
+		first, last := persons[0].ID, persons[len(persons)-1].ID
+		if current.Backward {
+			next = &pageCursor{ID: last}
+			if hasMore {
+				prev = &pageCursor{ID: first, Backward: true}
+			}
+		} else {
+			if hasMore {
+				next = &pageCursor{ID: last}
+			}
+			if token != "" {
+				prev = &pageCursor{ID: first, Backward: true}
+			}
+		}
+	} else if token != "" {
+		// An empty page still lets the client step back to the rows it came from.
+		if current.Backward {
+			next = &pageCursor{ID: current.ID - 1}
+		} else {
+			prev = &pageCursor{ID: current.ID + 1, Backward: true}
+		}
+	}
+
+	response := newListResponse(persons)
+	response.NextCursor, err = del.encodeCursor(next)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+	response.PrevCursor, err = del.encodeCursor(prev)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
+}
+
+func (del *delivery) encodeCursor(c *pageCursor) (*string, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	token, err := del.cursors.Encode(pageCursorPurpose, c)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// search godoc
+//
+//	@Summary		Full-text search of persons
+//	@Description	Finds persons whose name, address or work contain every word of q as a prefix.
+//	@Description	Results are ordered by relevance, matches in snippet are wrapped into <mark></mark>.
+//	@Tags			persons
+//	@Produce		json
+//	@Param			q		query		string			true	"Search text"
+//	@Param			limit	query		int				false	"Page size"
+//	@Param			offset	query		int				false	"Number of results to skip"
+//	@Success		200		{object}	searchResponse	"Found persons"
+//	@Failure		400		{object}	http.ValidationErrorResponse
+//	@Failure		401		{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons/search [get]
+//
+//	@Security		cookieAuth
+func (del *delivery) search(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	query := strings.TrimSpace(queryParams.Get("q"))
+	if query == "" {
+		pHTTP.HandleError(w, r, validation.Errors{"q": "is required"})
+		return
+	}
+
+	limit, err := parseInt64Param(queryParams, "limit")
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	offset, err := parseInt64Param(queryParams, "offset")
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	results, err := del.repo.Search(r.Context(), &pPersons.SearchParams{
+		Query:  query,
+		Offset: offset,
+		Limit:  limit,
+	})
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	response := newSearchResponse(results, offset, limit)
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
 }
 
 // partialUpdate godoc
 //
 //	@Summary		Partial update of person
-//	@Description	Partial update of person
+//	@Description	Partial update of person. Besides plain JSON with the fields to change, the body can be
+//	@Description	an RFC 7396 merge patch (application/merge-patch+json) or an RFC 6902 patch
+//	@Description	(application/json-patch+json) with test, add, replace and remove operations.
 //	@Tags			persons
 //	@Accept			json
+//	@Accept			application/merge-patch+json
+//	@Accept			application/json-patch+json
 //	@Produce		json
 //	@Param			id				path		int						true	"Person ID"
+//	@Param			If-Match			header		string					false	"ETag the person must still have"
 //	@Param			PersonUpdateData	body		partialUpdateRequest	true	"Person data to update"
 //	@Success		200				{object}	getResponse				"Updated person data."
-//	@Failure		400				{object}	http.JSONError
+//	@Header			200				{string}	ETag					"New person version"
+//	@Failure		400				{object}	http.ValidationErrorResponse
 //	@Failure		401				{object}	http.JSONError
+//	@Failure		412				{object}	http.JSONError			"Person was changed since If-Match version"
+//	@Failure		415				{object}	http.JSONError
+//	@Failure		422				{object}	http.JSONError			"Patch can not be applied"
 //	@Failure		405
 //	@Failure		500
 //	@Router			/persons/{id}  [patch]
@@ -188,12 +441,25 @@ func (del *delivery) partialUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := pHTTP.ReadBody(r, del.log)
+	body, err := pHTTP.ReadBody(r, del.logger(r))
 	if err != nil {
 		pHTTP.HandleError(w, r, err)
 		return
 	}
 
+	switch mediaType(r) {
+	case jsonpatch.MergePatchMediaType:
+		del.patch(w, r, personID, body, jsonpatch.MergePatch)
+		return
+	case jsonpatch.JSONPatchMediaType:
+		del.patch(w, r, personID, body, jsonpatch.Apply)
+		return
+	case "", jsonMediaType:
+	default:
+		pHTTP.HandleError(w, r, errors.Wrap(pErrors.ErrUnsupportedMediaType, r.Header.Get("Content-Type")))
+		return
+	}
+
 	var request partialUpdateRequest
 	err = json.Unmarshal(body, &request)
 	if err != nil {
@@ -201,12 +467,25 @@ func (del *delivery) partialUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = request.validate()
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	expectedVersion, err := del.expectedVersion(r.Context(), r, personID)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
 	params := pPersons.PartialUpdateParams{
-		ID:      personID,
-		Name:    request.Name,
-		Age:     request.Age,
-		Address: request.Address,
-		Work:    request.Work,
+		ID:              personID,
+		Name:            request.Name,
+		Age:             request.Age,
+		Address:         request.Address,
+		Work:            request.Work,
+		ExpectedVersion: expectedVersion,
 	}
 
 	person, err := del.repo.PartialUpdate(r.Context(), &params)
@@ -215,21 +494,106 @@ func (del *delivery) partialUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set(etagHeader, formatETag(person.Version))
 	response := newGetResponse(person)
 	pHTTP.SendJSON(w, r, http.StatusOK, response)
 }
 
+// replace godoc
+//
+//	@Summary		Replace person
+//	@Description	Replaces all fields of the person. A person that does not exist is created with the given id
+//	@Description	if the id was issued before, such as to a person that was purged.
+//	@Tags			persons
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int				true	"Person ID"
+//	@Param			If-Match		header		string			false	"ETag the person must still have"
+//	@Param			PersonData		body		createRequest	true	"Person data"
+//	@Success		200				{object}	getResponse		"Replaced person data."
+//	@Success		201				{object}	getResponse		"Created person data."
+//	@Header			200,201			{string}	ETag			"Person version"
+//	@Failure		400				{object}	http.ValidationErrorResponse
+//	@Failure		401				{object}	http.JSONError
+//	@Failure		404				{object}	http.JSONError	"Person was deleted, it is brought back by restore, or its id was never issued"
+//	@Failure		412				{object}	http.JSONError	"Person was changed since If-Match version"
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons/{id}  [put]
+//
+//	@Security		cookieAuth
+func (del *delivery) replace(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	personID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	body, err := pHTTP.ReadBody(r, del.logger(r))
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	var request createRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		pHTTP.HandleError(w, r, pErrors.ErrReadBody)
+		return
+	}
+
+	err = request.validate()
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	expectedVersion, err := del.expectedVersion(r.Context(), r, personID)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	params := pPersons.ReplaceParams{
+		ID:              personID,
+		Name:            request.Name,
+		Age:             request.Age,
+		Address:         request.Address,
+		Work:            request.Work,
+		ExpectedVersion: expectedVersion,
+	}
+
+	person, created, err := del.repo.Replace(r.Context(), &params)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	w.Header().Set(etagHeader, formatETag(person.Version))
+	status := http.StatusOK
+	if created {
+		w.Header().Add("Location", fmt.Sprintf(personsPath+"/%d", person.ID))
+		status = http.StatusCreated
+	}
+
+	response := newGetResponse(person)
+	pHTTP.SendJSON(w, r, status, response)
+}
+
 // delete godoc
 //
 //	@Summary		Delete person by id
 //	@Description	Delete person by id
 //	@Tags			persons
 //	@Produce		json
-//	@Param			id	path	int	true	"Person ID"
-//	@Success		204	"Person deleted successfully"
-//	@Failure		400	{object}	http.JSONError
-//	@Failure		401	{object}	http.JSONError
-//	@Failure		404	{object}	http.JSONError
+//	@Param			id			path	int		true	"Person ID"
+//	@Param			If-Match	header	string	false	"ETag the person must still have"
+//	@Success		204			"Person deleted successfully"
+//	@Failure		400			{object}	http.JSONError
+//	@Failure		401			{object}	http.JSONError
+//	@Failure		404			{object}	http.JSONError
+//	@Failure		412			{object}	http.JSONError	"Person was changed since If-Match version"
 //	@Failure		405
 //	@Failure		500
 //	@Router			/persons/{id} [delete]
@@ -243,7 +607,13 @@ func (del *delivery) delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = del.repo.Delete(r.Context(), personID)
+	expectedVersion, err := del.expectedVersion(r.Context(), r, personID)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	err = del.repo.Delete(r.Context(), personID, expectedVersion)
 	if err != nil {
 		pHTTP.HandleError(w, r, err)
 		return
@@ -251,3 +621,139 @@ func (del *delivery) delete(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// restore godoc
+//
+//	@Summary		Restore deleted person
+//	@Description	Undoes the deletion of a person that has not been purged yet. A person that is not deleted
+//	@Description	is returned as is.
+//	@Tags			persons
+//	@Produce		json
+//	@Param			id	path		int			true	"Person ID"
+//	@Success		200	{object}	getResponse	"Restored person data"
+//	@Header			200	{string}	ETag		"Person version"
+//	@Failure		400	{object}	http.JSONError
+//	@Failure		401	{object}	http.JSONError
+//	@Failure		404	{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons/{id}:restore [post]
+//
+//	@Security		cookieAuth
+func (del *delivery) restore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	personID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	person, err := del.repo.Restore(r.Context(), personID)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	w.Header().Set(etagHeader, formatETag(person.Version))
+	response := newGetResponse(person)
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
+}
+
+// history godoc
+//
+//	@Summary		Returns person change history
+//	@Description	Returns the audit trail of a person, newest changes first. Every change holds the operation,
+//	@Description	the changed fields before and after it, the actor (X-Actor header) and the request id.
+//	@Tags			persons
+//	@Produce		json
+//	@Param			id		path		int				true	"Person ID"
+//	@Param			limit	query		int				false	"Page size"
+//	@Param			cursor	query		string			false	"next_cursor of the previous page"
+//	@Success		200		{object}	historyResponse	"Person changes"
+//	@Failure		400		{object}	http.JSONError
+//	@Failure		401		{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons/{id}/history [get]
+//
+//	@Security		cookieAuth
+func (del *delivery) history(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	personID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	limit, err := parseInt64Param(queryParams, "limit")
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	var current historyCursor
+	if token := queryParams.Get("cursor"); token != "" {
+		err = del.cursors.Decode(historyCursorPurpose, token, &current)
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+	}
+
+	changes, err := del.repo.History(r.Context(), &pPersons.HistoryParams{
+		PersonID: personID,
+		BeforeID: current.ID,
+		Limit:    limit + 1,
+	})
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	response := newHistoryResponse(changes)
+	if int64(len(changes)) > limit {
+		response.Changes = changes[:limit]
+		token, err := del.cursors.Encode(historyCursorPurpose, historyCursor{ID: changes[limit-1].ID})
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+		response.NextCursor = &token
+	}
+
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
+}
+
+func parseInt64Param(queryParams url.Values, name string) (int64, error) {
+	if queryParams.Get(name) == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(queryParams.Get(name), 10, 64)
+	if err != nil || value < 0 {
+		return 0, errors.Wrapf(pErrors.ErrBadQueryParam, "%s must be a non-negative integer", name)
+	}
+	return value, nil
+}
+
+func parseBoolParam(queryParams url.Values, name string) (bool, error) {
+	if queryParams.Get(name) == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(queryParams.Get(name))
+	if err != nil {
+		return false, errors.Wrapf(pErrors.ErrBadQueryParam, "%s must be a boolean", name)
+	}
+	return value, nil
+}
+
+// logger returns the logger of the request.
+func (del *delivery) logger(r *http.Request) *zap.Logger {
+	return requestinfo.Logger(r.Context(), del.log)
+}
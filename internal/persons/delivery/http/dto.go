@@ -2,6 +2,8 @@ package http
 
 import (
 	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
 )
 
 type Person struct {
@@ -12,6 +14,23 @@ type Person struct {
 	Work    string
 }
 
+// Purposes of the cursors, which bind tokens to the listing that issued them.
+const (
+	pageCursorPurpose    = "persons.page"
+	historyCursorPurpose = "persons.history"
+)
+
+// pageCursor is the state behind opaque next_cursor/prev_cursor tokens.
+type pageCursor struct {
+	ID       int64 `json:"id"`
+	Backward bool  `json:"backward,omitempty"`
+}
+
+// historyCursor is the state behind opaque history next_cursor tokens, ID is the last change of the page.
+type historyCursor struct {
+	ID int64 `json:"id"`
+}
+
 // API requests
 type createRequest struct {
 	Name    string `json:"name"`
@@ -20,6 +39,16 @@ type createRequest struct {
 	Work    string `json:"work"`
 }
 
+func (req *createRequest) validate() error {
+	params := pPersons.CreateParams{
+		Name:    req.Name,
+		Age:     req.Age,
+		Address: req.Address,
+		Work:    req.Work,
+	}
+	return params.Validate()
+}
+
 type partialUpdateRequest struct {
 	Name    *string `json:"name"`
 	Age     *int    `json:"age"`
@@ -27,6 +56,15 @@ type partialUpdateRequest struct {
 	Work    *string `json:"work"`
 }
 
+func (req *partialUpdateRequest) validate() error {
+	return validation.Validate(
+		validation.Field("name", req.Name, validation.NotBlank(), validation.MaxLength(pPersons.NameMaxLength)),
+		validation.Field("age", req.Age, validation.Range(pPersons.MinAge, pPersons.MaxAge)),
+		validation.Field("address", req.Address, validation.MaxLength(pPersons.AddressMaxLength)),
+		validation.Field("work", req.Work, validation.MaxLength(pPersons.WorkMaxLength)),
+	)
+}
+
 // API responses
 type createResponse struct {
 	ID      int64  `json:"id"`
@@ -47,7 +85,9 @@ func newCreateResponse(person *models.Person) *createResponse {
 }
 
 type listResponse struct {
-	Persons []models.Person `json:"persons"`
+	Persons    []models.Person `json:"persons"`
+	NextCursor *string         `json:"next_cursor"`
+	PrevCursor *string         `json:"prev_cursor"`
 }
 
 func newListResponse(persons []models.Person) *listResponse {
@@ -56,6 +96,56 @@ func newListResponse(persons []models.Person) *listResponse {
 	}
 }
 
+type historyResponse struct {
+	Changes    []models.PersonChange `json:"changes"`
+	NextCursor *string               `json:"next_cursor"`
+}
+
+func newHistoryResponse(changes []models.PersonChange) *historyResponse {
+	if changes == nil {
+		changes = []models.PersonChange{}
+	}
+	return &historyResponse{
+		Changes: changes,
+	}
+}
+
+type searchResult struct {
+	ID      int64   `json:"id"`
+	Name    string  `json:"name"`
+	Age     int     `json:"age"`
+	Address string  `json:"address"`
+	Work    string  `json:"work"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchResponse struct {
+	Results []searchResult `json:"results"`
+	Offset  int64          `json:"offset"`
+	Limit   int64          `json:"limit"`
+}
+
+func newSearchResponse(results []pPersons.SearchResult, offset, limit int64) *searchResponse {
+	response := &searchResponse{
+		Results: make([]searchResult, len(results)),
+		Offset:  offset,
+		Limit:   limit,
+	}
+	for i, result := range results {
+		response.Results[i] = searchResult{
+			ID:      result.ID,
+			Name:    result.Name,
+			Age:     result.Age,
+			Address: result.Address,
+			Work:    result.Work,
+			Rank:    result.Rank,
+			Snippet: result.Snippet,
+		}
+	}
+	return response
+}
+
 type getResponse struct {
 	ID      int64  `json:"id"`
 	Name    string `json:"name"`
@@ -73,3 +163,33 @@ func newGetResponse(person *models.Person) *getResponse {
 		Work:    person.Work,
 	}
 }
+
+// batchUpdateRequest is an item of a batch partial update, Version is the ETag version the person must still have.
+type batchUpdateRequest struct {
+	ID      int64 `json:"id"`
+	Version int64 `json:"version"`
+	partialUpdateRequest
+}
+
+func (req *batchUpdateRequest) validate() error {
+	if req.ID <= 0 {
+		return validation.Errors{"id": "is required"}
+	}
+	if req.Version < 0 {
+		return validation.Errors{"version": "must be positive"}
+	}
+	return req.partialUpdateRequest.validate()
+}
+
+type batchItemResult struct {
+	Index  int               `json:"index"`
+	Status int               `json:"status"`
+	Person *getResponse      `json:"person,omitempty"`
+	ETag   string            `json:"etag,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Errors validation.Errors `json:"errors,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchItemResult `json:"results"`
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+const (
+	etagHeader        = "ETag"
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
+// formatETag renders a person version as a strong entity tag.
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// entityTag is a parsed element of an If-Match or If-None-Match list.
+type entityTag struct {
+	version int64
+	weak    bool
+}
+
+// parseETags parses a comma separated list of entity tags. Tags that were not issued by formatETag can never match,
+// so they are skipped.
+func parseETags(header string) []entityTag {
+	var tags []entityTag
+	for _, item := range strings.Split(header, ",") {
+		item = strings.TrimSpace(item)
+
+		var tag entityTag
+		if rest, ok := strings.CutPrefix(item, "W/"); ok {
+			tag.weak = true
+			item = rest
+		}
+		if len(item) < 2 || item[0] != '"' || item[len(item)-1] != '"' {
+			continue
+		}
+
+		version, err := strconv.ParseInt(item[1:len(item)-1], 10, 64)
+		if err != nil || version <= 0 {
+			continue
+		}
+		tag.version = version
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// expectedVersion returns the version that If-Match requires the person to have, 0 if any version is acceptable.
+// If-Match uses strong comparison, so weak tags never match. When several tags are listed, the current version
+// is looked up and, if it is one of them, the write is conditioned on it.
+func (del *delivery) expectedVersion(ctx context.Context, r *http.Request, personID int64) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get(ifMatchHeader))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	versions := make([]int64, 0, 1)
+	for _, tag := range parseETags(header) {
+		if !tag.weak {
+			versions = append(versions, tag.version)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, errors.Wrap(pErrors.ErrVersionMismatch, "If-Match has no acceptable entity tags")
+	case 1:
+		return versions[0], nil
+	}
+
+	person, err := del.repo.Get(ctx, personID)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, person.Version) {
+		return 0, errors.Wrapf(pErrors.ErrVersionMismatch, "current version is %d", person.Version)
+	}
+	return person.Version, nil
+}
+
+// notModified reports whether If-None-Match lists the current version. Weak comparison is used, as required for GET.
+func notModified(r *http.Request, version int64) bool {
+	header := strings.TrimSpace(r.Header.Get(ifNoneMatchHeader))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	for _, tag := range parseETags(header) {
+		if tag.version == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseETags(t *testing.T) {
+	type testCase struct {
+		header string
+		tags   []entityTag
+	}
+
+	tests := map[string]testCase{
+		"single tag": {
+			header: `"3"`,
+			tags:   []entityTag{{version: 3}},
+		},
+		"list with weak tag": {
+			header: ` "3", W/"4" ,"5"`,
+			tags:   []entityTag{{version: 3}, {version: 4, weak: true}, {version: 5}},
+		},
+		"foreign tags": {
+			header: `"abc", 3, "", "-1", W/`,
+			tags:   nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tags := parseETags(test.header)
+			if !reflect.DeepEqual(tags, test.tags) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.tags, tags)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	type testCase struct {
+		header      string
+		notModified bool
+	}
+
+	tests := map[string]testCase{
+		"no header":       {header: "", notModified: false},
+		"any":             {header: "*", notModified: true},
+		"current version": {header: formatETag(7), notModified: true},
+		"weak match":      {header: `"5", W/"7"`, notModified: true},
+		"stale version":   {header: `"6"`, notModified: false},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("GET", "/api/v1/persons/1", nil)
+			if test.header != "" {
+				r.Header.Set(ifNoneMatchHeader, test.header)
+			}
+
+			if got := notModified(r, 7); got != test.notModified {
+				t.Errorf("\nExpected: %t\nGot: %t", test.notModified, got)
+			}
+		})
+	}
+}
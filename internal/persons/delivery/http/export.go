@@ -0,0 +1,285 @@
+package http
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+const (
+	formatParam = "format"
+	fieldsParam = "fields"
+
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	csvMediaType    = "text/csv; charset=utf-8"
+	ndjsonMediaType = "application/x-ndjson"
+)
+
+// exportFlushRows is the number of rows written to the client between flushes.
+const exportFlushRows = 500
+
+// exportFields are the exported person fields in their default order.
+var exportFields = []string{"id", "name", "age", "address", "work"}
+
+// parseExportFields parses a comma separated list of person fields, all of them are exported if it is empty.
+func parseExportFields(value string) ([]string, error) {
+	if value == "" {
+		return exportFields, nil
+	}
+
+	fields := strings.Split(value, ",")
+	seen := make(map[string]struct{}, len(fields))
+	for i, field := range fields {
+		field = strings.TrimSpace(field)
+		if _, ok := pPersons.Fields[field]; !ok {
+			return nil, validation.Errors{fieldsParam: "unknown field " + strconv.Quote(field)}
+		}
+		if _, ok := seen[field]; ok {
+			return nil, validation.Errors{fieldsParam: "duplicated field " + strconv.Quote(field)}
+		}
+		seen[field] = struct{}{}
+		fields[i] = field
+	}
+	return fields, nil
+}
+
+func fieldValue(person *models.Person, field string) any {
+	switch field {
+	case "id":
+		return person.ID
+	case "name":
+		return person.Name
+	case "age":
+		return person.Age
+	case "address":
+		return person.Address
+	default:
+		return person.Work
+	}
+}
+
+// exportEncoder writes persons in one of the export formats. Output may be buffered until Flush.
+type exportEncoder interface {
+	// Begin writes whatever precedes the first person, such as a header row.
+	Begin() error
+	Encode(person *models.Person) error
+	Flush() error
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	fields []string
+	record []string
+}
+
+func newCSVEncoder(w io.Writer, fields []string) *csvEncoder {
+	return &csvEncoder{
+		writer: csv.NewWriter(w),
+		fields: fields,
+		record: make([]string, len(fields)),
+	}
+}
+
+func (enc *csvEncoder) Begin() error {
+	return enc.writer.Write(enc.fields)
+}
+
+func (enc *csvEncoder) Encode(person *models.Person) error {
+	for i, field := range enc.fields {
+		switch value := fieldValue(person, field).(type) {
+		case string:
+			enc.record[i] = value
+		case int:
+			enc.record[i] = strconv.Itoa(value)
+		case int64:
+			enc.record[i] = strconv.FormatInt(value, 10)
+		}
+	}
+	return enc.writer.Write(enc.record)
+}
+
+func (enc *csvEncoder) Flush() error {
+	enc.writer.Flush()
+	return enc.writer.Error()
+}
+
+// ndjsonEncoder writes one JSON object per line with the members in the order of fields.
+type ndjsonEncoder struct {
+	writer *bufio.Writer
+	fields []string
+	line   []byte
+}
+
+func newNDJSONEncoder(w io.Writer, fields []string) *ndjsonEncoder {
+	return &ndjsonEncoder{
+		writer: bufio.NewWriter(w),
+		fields: fields,
+	}
+}
+
+func (enc *ndjsonEncoder) Begin() error {
+	return nil
+}
+
+func (enc *ndjsonEncoder) Encode(person *models.Person) error {
+	enc.line = append(enc.line[:0], '{')
+	for i, field := range enc.fields {
+		if i > 0 {
+			enc.line = append(enc.line, ',')
+		}
+		enc.line = strconv.AppendQuote(enc.line, field)
+		enc.line = append(enc.line, ':')
+
+		value, err := json.Marshal(fieldValue(person, field))
+		if err != nil {
+			return err
+		}
+		enc.line = append(enc.line, value...)
+	}
+	enc.line = append(enc.line, '}', '\n')
+
+	_, err := enc.writer.Write(enc.line)
+	return err
+}
+
+func (enc *ndjsonEncoder) Flush() error {
+	return enc.writer.Flush()
+}
+
+// export godoc
+//
+//	@Summary		Export persons
+//	@Description	Streams persons as CSV with a header row or as newline delimited JSON. Accepts the filters,
+//	@Description	sort, offset and limit of the persons list. The response is written while persons are read,
+//	@Description	so a failure in the middle of the export breaks the connection instead of returning an error.
+//	@Tags			persons
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Param			format	query		string	false	"csv (default) or ndjson"
+//	@Param			fields	query		string	false	"Comma separated fields to export, e.g. id,name"
+//	@Param			sort	query		string	false	"Comma separated fields, '-' prefix for descending order"
+//	@Param			limit	query		int		false	"Maximum number of persons"
+//	@Param			offset	query		int		false	"Number of persons to skip"
+//	@Success		200		{string}	string	"Persons"
+//	@Failure		400		{object}	http.ValidationErrorResponse
+//	@Failure		401		{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons/export [get]
+//
+//	@Security		cookieAuth
+func (del *delivery) export(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	fields, err := parseExportFields(queryParams.Get(fieldsParam))
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	var contentType string
+	var encoder exportEncoder
+	switch format := queryParams.Get(formatParam); format {
+	case "", formatCSV:
+		contentType = csvMediaType
+		encoder = newCSVEncoder(w, fields)
+	case formatNDJSON:
+		contentType = ndjsonMediaType
+		encoder = newNDJSONEncoder(w, fields)
+	default:
+		pHTTP.HandleError(w, r, validation.Errors{formatParam: "must be csv or ndjson"})
+		return
+	}
+
+	limit, err := parseInt64Param(queryParams, "limit")
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+	offset, err := parseInt64Param(queryParams, "offset")
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+	criteria, err := parseCriteria(r.URL.RawQuery)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	// The response starts with the first person, so that a failed query can still be reported with a status code.
+	controller := http.NewResponseController(w)
+	started := false
+	begin := func() error {
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		return encoder.Begin()
+	}
+
+	rows := 0
+	err = del.repo.Export(r.Context(), &pPersons.ListParams{
+		Offset:   offset,
+		Limit:    limit,
+		Criteria: criteria,
+	}, func(person *models.Person) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(person); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushRows == 0 {
+			return flush(encoder, controller)
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = flush(encoder, controller)
+	}
+	if err == nil {
+		return
+	}
+
+	if !started {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+	// The status line has already been sent, breaking the connection is the only way to tell the client
+	// that the export is incomplete.
+	del.logger(r).Error("Export interrupted", zap.Error(err), zap.Int("rows", rows))
+	panic(http.ErrAbortHandler)
+}
+
+// flush sends buffered rows to the client.
+func flush(encoder exportEncoder, controller *http.ResponseController) error {
+	if err := encoder.Flush(); err != nil {
+		return err
+	}
+	err := controller.Flush()
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return errors.Wrap(pErrors.ErrRequestCanceled, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+func TestParseExportFields(t *testing.T) {
+	type testCase struct {
+		value  string
+		fields []string
+		err    error
+	}
+
+	tests := map[string]testCase{
+		"all fields":       {value: "", fields: exportFields},
+		"selected fields":  {value: "work, id", fields: []string{"work", "id"}},
+		"unknown field":    {value: "id,version", err: pErrors.ErrInvalidData},
+		"duplicated field": {value: "id,name,id", err: pErrors.ErrInvalidData},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fields, err := parseExportFields(test.value)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.fields, fields)
+			}
+		})
+	}
+}
+
+func TestExportEncoders(t *testing.T) {
+	type testCase struct {
+		newEncoder func(buf *bytes.Buffer) exportEncoder
+		output     string
+	}
+
+	persons := []models.Person{
+		{ID: 1, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 3},
+		{ID: 2, Name: `Den "the man"`, Age: 30, Address: "Kazan", Work: "VK", Version: 1},
+	}
+
+	tests := map[string]testCase{
+		"csv": {
+			newEncoder: func(buf *bytes.Buffer) exportEncoder {
+				return newCSVEncoder(buf, exportFields)
+			},
+			output: "id,name,age,address,work\n" +
+				"1,Johnny,22,\"Moscow, Red Square\",Yandex\n" +
+				"2,\"Den \"\"the man\"\"\",30,Kazan,VK\n",
+		},
+		"csv selected fields": {
+			newEncoder: func(buf *bytes.Buffer) exportEncoder {
+				return newCSVEncoder(buf, []string{"name", "id"})
+			},
+			output: "name,id\n" +
+				"Johnny,1\n" +
+				"\"Den \"\"the man\"\"\",2\n",
+		},
+		"ndjson": {
+			newEncoder: func(buf *bytes.Buffer) exportEncoder {
+				return newNDJSONEncoder(buf, exportFields)
+			},
+			output: `{"id":1,"name":"Johnny","age":22,"address":"Moscow, Red Square","work":"Yandex"}` + "\n" +
+				`{"id":2,"name":"Den \"the man\"","age":30,"address":"Kazan","work":"VK"}` + "\n",
+		},
+		"ndjson selected fields": {
+			newEncoder: func(buf *bytes.Buffer) exportEncoder {
+				return newNDJSONEncoder(buf, []string{"age"})
+			},
+			output: `{"age":22}` + "\n" + `{"age":30}` + "\n",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			encoder := test.newEncoder(&buf)
+			if err := encoder.Begin(); err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+			}
+			for i := range persons {
+				if err := encoder.Encode(&persons[i]); err != nil {
+					t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+				}
+			}
+			if err := encoder.Flush(); err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+			}
+
+			if buf.String() != test.output {
+				t.Errorf("\nExpected: %s\nGot: %s", test.output, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/importer"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+const (
+	dryRunParam = "dry_run"
+	// importFileField is the form field of a multipart/form-data upload that holds the file.
+	importFileField = "file"
+)
+
+// maxImportSize bounds the size of an uploaded file.
+const maxImportSize = 64 << 20
+
+// importSource finds the uploaded file and its format. The file is either the request body or the file field
+// of a multipart/form-data form. The format query parameter takes precedence over the media type and name of the file.
+func importSource(r *http.Request) (io.Reader, importer.Format, error) {
+	var file io.Reader = r.Body
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	filename := ""
+	if contentType == "multipart/form-data" {
+		form, err := r.MultipartReader()
+		if err != nil {
+			return nil, "", errors.Wrap(pErrors.ErrReadBody, err.Error())
+		}
+		for {
+			part, err := form.NextPart()
+			if errors.Is(err, io.EOF) {
+				return nil, "", validation.Errors{importFileField: "is required"}
+			}
+			if err != nil {
+				return nil, "", errors.Wrap(pErrors.ErrReadBody, err.Error())
+			}
+			if part.FormName() == importFileField {
+				file = part
+				contentType, _, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+				filename = part.FileName()
+				break
+			}
+		}
+	}
+
+	switch format := importer.Format(r.URL.Query().Get(formatParam)); format {
+	case importer.FormatCSV, importer.FormatNDJSON:
+		return file, format, nil
+	case "":
+	default:
+		return nil, "", validation.Errors{formatParam: "must be csv or ndjson"}
+	}
+
+	format, ok := importer.DetectFormat(contentType, filename)
+	if !ok {
+		return nil, "", errors.Wrapf(pErrors.ErrUnsupportedMediaType,
+			"%s, upload text/csv or application/x-ndjson or pass the format parameter", contentType)
+	}
+	return file, format, nil
+}
+
+// importPersons godoc
+//
+//	@Summary		Import persons
+//	@Description	Creates persons from a CSV file with a header row (name is required; age, address and work are
+//	@Description	optional; id and version are ignored) or from an NDJSON file. The file is the request body or the
+//	@Description	file field of a form. Each row is validated like a new person, invalid rows are reported with
+//	@Description	their line numbers and the other rows are imported. dry_run=true only validates the file.
+//	@Tags			persons
+//	@Accept			text/csv
+//	@Accept			application/x-ndjson
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			format	query		string			false	"csv or ndjson, detected from the upload by default"
+//	@Param			dry_run	query		bool			false	"Validate without importing"
+//	@Success		200		{object}	importer.Report	"Import report"
+//	@Failure		400		{object}	http.ValidationErrorResponse
+//	@Failure		401		{object}	http.JSONError
+//	@Failure		413		{object}	http.JSONError	"File is larger than 64 MiB"
+//	@Failure		415		{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons/import [post]
+//
+//	@Security		cookieAuth
+func (del *delivery) importPersons(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if value := r.URL.Query().Get(dryRunParam); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			pHTTP.HandleError(w, r, errors.Wrapf(pErrors.ErrBadQueryParam, "%s must be true or false", dryRunParam))
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, format, err := importSource(r)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	report, err := del.importer.Import(r.Context(), file, importer.Options{
+		Format: format,
+		DryRun: dryRun,
+	})
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			err = errors.Wrapf(pErrors.ErrBatchTooLarge, "file is larger than %d bytes", maxBytesErr.Limit)
+		}
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	pHTTP.SendJSON(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+const jsonMediaType = "application/json"
+
+// maxPatchAttempts bounds how many times a patch is reapplied when the person changes between reading and writing it.
+const maxPatchAttempts = 3
+
+// mediaType returns the media type of the request body without parameters, or the raw header if it is malformed.
+func mediaType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return parsed
+}
+
+// patch applies a patch document to the JSON representation of the person and stores the result.
+// The write is conditioned on the version the patch was applied to, so concurrent changes are never lost;
+// without If-Match the patch is reapplied to the fresh person instead of failing.
+func (del *delivery) patch(w http.ResponseWriter, r *http.Request, personID int64, body []byte,
+	apply func(doc, patch []byte) ([]byte, error)) {
+	ifMatch, err := del.expectedVersion(r.Context(), r, personID)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		person, err := del.repo.Get(r.Context(), personID)
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+		if ifMatch != 0 && person.Version != ifMatch {
+			pHTTP.HandleError(w, r, errors.Wrapf(pErrors.ErrVersionMismatch, "current version is %d", person.Version))
+			return
+		}
+
+		doc, err := json.Marshal(newGetResponse(person))
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+		patched, err := apply(doc, body)
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+		request, err := decodePatchedPerson(patched, personID)
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+
+		updated, err := del.repo.PartialUpdate(r.Context(), &pPersons.PartialUpdateParams{
+			ID:              personID,
+			Name:            &request.Name,
+			Age:             &request.Age,
+			Address:         &request.Address,
+			Work:            &request.Work,
+			ExpectedVersion: person.Version,
+		})
+		if errors.Is(err, pErrors.ErrVersionMismatch) && ifMatch == 0 && attempt < maxPatchAttempts {
+			del.logger(r).Debug("Person changed while patching, retrying")
+			continue
+		}
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+
+		w.Header().Set(etagHeader, formatETag(updated.Version))
+		response := newGetResponse(updated)
+		pHTTP.SendJSON(w, r, http.StatusOK, response)
+		return
+	}
+}
+
+// decodePatchedPerson turns a patched person document back into a full person and validates it like a new one.
+func decodePatchedPerson(doc []byte, personID int64) (*createRequest, error) {
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+
+	var patched getResponse
+	err := decoder.Decode(&patched)
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return nil, validation.Errors{typeErr.Field: "has wrong type"}
+		}
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return nil, validation.Errors{strings.Trim(field, `"`): "unknown field"}
+		}
+		return nil, errors.Wrap(pErrors.ErrPatchNotApplicable, "patched document is not a person")
+	}
+
+	if patched.ID != personID {
+		return nil, validation.Errors{"id": "can not be changed"}
+	}
+
+	request := &createRequest{
+		Name:    patched.Name,
+		Age:     patched.Age,
+		Address: patched.Address,
+		Work:    patched.Work,
+	}
+	if err = request.validate(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+func TestDecodePatchedPerson(t *testing.T) {
+	type testCase struct {
+		doc     string
+		request *createRequest
+		err     error
+	}
+
+	tests := map[string]testCase{
+		"valid person": {
+			doc:     `{"id": 3, "name": "Den", "age": 30, "work": "VK"}`,
+			request: &createRequest{Name: "Den", Age: 30, Work: "VK"},
+		},
+		"removed name": {
+			doc: `{"id": 3, "age": 30}`,
+			err: validation.Errors{"name": "is required"},
+		},
+		"changed id": {
+			doc: `{"id": 4, "name": "Den"}`,
+			err: validation.Errors{"id": "can not be changed"},
+		},
+		"unknown field": {
+			doc: `{"id": 3, "name": "Den", "salary": 100}`,
+			err: validation.Errors{"salary": "unknown field"},
+		},
+		"wrong type": {
+			doc: `{"id": 3, "name": "Den", "age": "old"}`,
+			err: validation.Errors{"age": "has wrong type"},
+		},
+		"not an object": {
+			doc: `["Den"]`,
+			err: pErrors.ErrPatchNotApplicable,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			request, err := decodePatchedPerson([]byte(test.doc), 3)
+
+			var expectedErrs validation.Errors
+			if errors.As(test.err, &expectedErrs) {
+				var errs validation.Errors
+				if !errors.As(err, &errs) || !reflect.DeepEqual(errs, expectedErrs) {
+					t.Errorf("\nExpected: %v\nGot: %v", test.err, err)
+				}
+				return
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if !reflect.DeepEqual(request, test.request) {
+				t.Errorf("\nExpected: %+v\nGot: %+v", test.request, request)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/stream"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+)
+
+const lastEventIDHeader = "Last-Event-ID"
+
+const (
+	// heartbeatInterval keeps idle streams from being closed by proxies and detects gone clients.
+	heartbeatInterval = 15 * time.Second
+	// reconnectDelay is the retry time suggested to clients.
+	reconnectDelay = 3 * time.Second
+)
+
+// stream godoc
+//
+//	@Summary		Person change feed
+//	@Description	Server-Sent Events stream of person changes. Every event has the id to resume from,
+//	@Description	the type (create, update, delete or restore) and the person as data. A client that resumes
+//	@Description	with Last-Event-ID gets the changes it missed; if they are no longer kept, a reset event
+//	@Description	tells it to reload persons. Comments are sent as heartbeats while there are no changes.
+//	@Tags			persons
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		int		false	"Id of the last received event"
+//	@Success		200				{string}	string	"Event stream"
+//	@Failure		401				{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/persons/stream [get]
+//
+//	@Security		cookieAuth
+func (del *delivery) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		pHTTP.HandleError(w, r, errors.New("streaming is not supported"))
+		return
+	}
+
+	var lastEventID int64
+	if header := strings.TrimSpace(r.Header.Get(lastEventIDHeader)); header != "" {
+		var err error
+		lastEventID, err = strconv.ParseInt(header, 10, 64)
+		if err != nil || lastEventID <= 0 {
+			// An id that was never issued can not be resumed from.
+			lastEventID = -1
+		}
+	}
+
+	sub, replay, complete := del.hub.Subscribe(lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disables response buffering in nginx.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay.Milliseconds())
+	if !complete && err == nil {
+		err = writeEvent(w, stream.Event{Type: stream.EventReset})
+	}
+	for i := 0; i < len(replay) && err == nil; i++ {
+		err = writeEvent(w, replay[i])
+	}
+	if err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			del.logger(r).Debug("Change feed client disconnected")
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// The client is too slow or the server shuts down, it reconnects and resumes.
+				return
+			}
+			err = writeEvent(w, event)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err != nil {
+			del.logger(r).Debug("Failed to write to change feed", zap.Error(err))
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes an event in the text/event-stream format. Reset events have no id, so that the client
+// keeps resuming from the last change it got.
+func writeEvent(w http.ResponseWriter, event stream.Event) error {
+	var b strings.Builder
+	if event.ID != 0 {
+		fmt.Fprintf(&b, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(&b, "event: %s\n", event.Type)
+	data := event.Data
+	if data == nil {
+		data = []byte("{}")
+	}
+	// Data is compact JSON, which has no line breaks.
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/stream"
+)
+
+func TestStream(t *testing.T) {
+	type testCase struct {
+		lastEventID string
+		// connected is what is sent on connect, before the next change is published.
+		connected []string
+	}
+
+	const deleted = "id: 3\nevent: delete\ndata: {\"id\":1}\n\n"
+
+	tests := map[string]testCase{
+		"new client": {
+			lastEventID: "",
+			connected:   []string{"retry: 3000\n\n"},
+		},
+		"resumed client": {
+			lastEventID: "1",
+			connected:   []string{"retry: 3000\n\n", "id: 2\nevent: update\ndata: {\"id\":1}\n\n"},
+		},
+		"lost events": {
+			lastEventID: "abc",
+			connected:   []string{"retry: 3000\n\n", "event: reset\ndata: {}\n\n"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			hub := stream.NewHub(10)
+			defer hub.Close()
+			hub.Publish(stream.Event{ID: 1, Type: stream.EventCreate, Data: []byte(`{"id":1}`)})
+			hub.Publish(stream.Event{ID: 2, Type: stream.EventUpdate, Data: []byte(`{"id":1}`)})
+
+			del := delivery{hub: hub, log: zap.NewNop()}
+			server := httptest.NewServer(http.HandlerFunc(del.stream))
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatalf("can't create request: %s", err)
+			}
+			if test.lastEventID != "" {
+				req.Header.Set(lastEventIDHeader, test.lastEventID)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("can't connect: %s", err)
+			}
+			defer resp.Body.Close()
+			if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+				t.Errorf("\nExpected: %s\nGot: %s", "text/event-stream", contentType)
+			}
+
+			reader := bufio.NewReader(resp.Body)
+			connected := readMessages(t, reader, len(test.connected))
+			if strings.Join(connected, "") != strings.Join(test.connected, "") {
+				t.Errorf("\nExpected: %q\nGot: %q", test.connected, connected)
+			}
+
+			hub.Publish(stream.Event{ID: 3, Type: stream.EventDelete, Data: []byte(`{"id":1}`)})
+			if published := readMessages(t, reader, 1); published[0] != deleted {
+				t.Errorf("\nExpected: %q\nGot: %q", deleted, published[0])
+			}
+		})
+	}
+}
+
+// readMessages reads count messages of an event stream, each ending with a blank line.
+func readMessages(t *testing.T, reader *bufio.Reader, count int) []string {
+	messages := make([]string, 0, count)
+	var message strings.Builder
+	for len(messages) < count {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("can't read stream: %s", err)
+		}
+		message.WriteString(line)
+		if line == "\n" {
+			messages = append(messages, message.String())
+			message.Reset()
+		}
+	}
+	return messages
+}
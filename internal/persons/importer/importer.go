@@ -0,0 +1,196 @@
+// Package importer loads persons from CSV and NDJSON files.
+package importer
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// ChunkSize is the number of rows that are written with one batch.
+const ChunkSize = 500
+
+// maxReportedErrors bounds the report of a file in which most rows are broken.
+const maxReportedErrors = 1000
+
+// DetectFormat guesses the format of a file by its media type or, failing that, by the extension of its name.
+func DetectFormat(mediaType, filename string) (Format, bool) {
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV, true
+	case "application/x-ndjson", "application/jsonl":
+		return FormatNDJSON, true
+	}
+
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, true
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, true
+	}
+	return "", false
+}
+
+type Options struct {
+	Format Format
+	// DryRun validates every row without writing anything.
+	DryRun bool
+}
+
+// RowError describes why a row was not imported. Line is the line of the file where the row starts.
+type RowError struct {
+	Line   int               `json:"line"`
+	Error  string            `json:"error"`
+	Errors validation.Errors `json:"errors,omitempty"`
+}
+
+type Report struct {
+	DryRun bool `json:"dry_run"`
+	// Rows is the number of rows read, not counting the CSV header.
+	Rows     int `json:"rows"`
+	Imported int `json:"imported"`
+	Failed   int `json:"failed"`
+	// Errors lists failed rows ordered by line, at most 1000 of them.
+	Errors []RowError `json:"errors"`
+}
+
+func (report *Report) fail(line int, err error) {
+	report.Failed++
+	if len(report.Errors) >= maxReportedErrors {
+		return
+	}
+
+	rowError := RowError{
+		Line:  line,
+		Error: errors.Cause(err).Error(),
+	}
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		rowError.Errors = validationErrs
+	}
+	report.Errors = append(report.Errors, rowError)
+}
+
+type Importer struct {
+	repo pPersons.Repository
+	log  *zap.Logger
+}
+
+func New(repo pPersons.Repository, log *zap.Logger) *Importer {
+	return &Importer{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// Import validates every row of r like a new person and creates valid persons in chunks of ChunkSize.
+// Rows are independent: invalid rows and rows that fail to be written are reported, the others are imported.
+// An error is returned only if the file can not be read any further, e.g. its CSV header is broken,
+// and then the chunks written so far stay imported.
+func (imp *Importer) Import(ctx context.Context, r io.Reader, opts Options) (*Report, error) {
+	var reader rowReader
+	switch opts.Format {
+	case FormatCSV:
+		var err error
+		reader, err = newCSVReader(r)
+		if err != nil {
+			return nil, err
+		}
+	case FormatNDJSON:
+		reader = newNDJSONReader(r)
+	default:
+		return nil, errors.Wrapf(pErrors.ErrInvalidData, "unknown import format %q", opts.Format)
+	}
+
+	report := &Report{
+		DryRun: opts.DryRun,
+		Errors: []RowError{},
+	}
+	chunk := make([]pPersons.CreateParams, 0, ChunkSize)
+	lines := make([]int, 0, ChunkSize)
+	for {
+		row, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		report.Rows++
+		if row.err == nil {
+			row.err = row.params.Validate()
+		}
+		if row.err != nil {
+			report.fail(row.line, row.err)
+			continue
+		}
+		if opts.DryRun {
+			continue
+		}
+
+		chunk = append(chunk, row.params)
+		lines = append(lines, row.line)
+		if len(chunk) == ChunkSize {
+			if err = imp.load(ctx, report, chunk, lines); err != nil {
+				return nil, err
+			}
+			chunk, lines = chunk[:0], lines[:0]
+		}
+	}
+	if len(chunk) > 0 {
+		if err := imp.load(ctx, report, chunk, lines); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rows that failed to be written are reported after the invalid rows of their chunk.
+	slices.SortStableFunc(report.Errors, func(a, b RowError) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+
+	requestinfo.Logger(ctx, imp.log).Info("Persons imported", zap.Bool("dry_run", report.DryRun), zap.Int("rows", report.Rows),
+		zap.Int("imported", report.Imported), zap.Int("failed", report.Failed))
+	return report, nil
+}
+
+func (imp *Importer) load(ctx context.Context, report *Report, chunk []pPersons.CreateParams, lines []int) error {
+	// Without this check a cancelled import would report every remaining row as failed.
+	if err := ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errors.Wrap(pErrors.ErrDeadlineExceeded, err.Error())
+		}
+		return errors.Wrap(pErrors.ErrRequestCanceled, err.Error())
+	}
+
+	results, err := imp.repo.CreateBatch(ctx, chunk, pPersons.BatchBestEffort)
+	if err != nil {
+		return err
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			report.fail(lines[i], result.Err)
+		} else {
+			report.Imported++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+// batchRepository records created persons and fails those named "conflict".
+type batchRepository struct {
+	pPersons.Repository
+	created []pPersons.CreateParams
+}
+
+func (repo *batchRepository) CreateBatch(_ context.Context, params []pPersons.CreateParams,
+	_ pPersons.BatchMode) ([]pPersons.BatchResult, error) {
+	results := make([]pPersons.BatchResult, len(params))
+	for i, p := range params {
+		if p.Name == "conflict" {
+			results[i].Err = pErrors.ErrPersonAlreadyExists
+			continue
+		}
+		repo.created = append(repo.created, p)
+		results[i].Person = &models.Person{ID: int64(len(repo.created)), Name: p.Name}
+	}
+	return results, nil
+}
+
+func TestImport(t *testing.T) {
+	type testCase struct {
+		input   string
+		opts    Options
+		created []pPersons.CreateParams
+		report  *Report
+		err     error
+	}
+
+	johnny := pPersons.CreateParams{Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex"}
+	den := pPersons.CreateParams{Name: "Den", Age: 30}
+
+	tests := map[string]testCase{
+		"csv": {
+			input: "id,name,age,address,work\n" +
+				"1,Johnny,22,\"Moscow, Red Square\",Yandex\n" +
+				"\n" +
+				"2,,40,Kazan,VK\n" +
+				"3,Den,30,,\n" +
+				"4,Ken,old,,\n" +
+				"5,conflict,1,,\n" +
+				"6,Ben\n",
+			opts:    Options{Format: FormatCSV},
+			created: []pPersons.CreateParams{johnny, den},
+			report: &Report{
+				Rows:     6,
+				Imported: 2,
+				Failed:   4,
+				Errors: []RowError{
+					{Line: 4, Error: pErrors.ErrInvalidData.Error(), Errors: validation.Errors{"name": "is required"}},
+					{Line: 6, Error: pErrors.ErrInvalidData.Error(), Errors: validation.Errors{"age": "must be an integer"}},
+					{Line: 7, Error: pErrors.ErrPersonAlreadyExists.Error()},
+					{Line: 8, Error: pErrors.ErrInvalidData.Error()},
+				},
+			},
+		},
+		"csv dry run": {
+			input:   "Name,Age\nJohnny,22\nDen,200\n",
+			opts:    Options{Format: FormatCSV, DryRun: true},
+			created: nil,
+			report: &Report{
+				DryRun: true,
+				Rows:   2,
+				Failed: 1,
+				Errors: []RowError{
+					{Line: 3, Error: pErrors.ErrInvalidData.Error(), Errors: validation.Errors{"age": "must be between 0 and 150"}},
+				},
+			},
+		},
+		"csv unknown column": {
+			input: "name,salary\nJohnny,100\n",
+			opts:  Options{Format: FormatCSV},
+			err:   pErrors.ErrInvalidData,
+		},
+		"csv without name": {
+			input: "age,work\n22,VK\n",
+			opts:  Options{Format: FormatCSV},
+			err:   pErrors.ErrInvalidData,
+		},
+		"ndjson": {
+			input: `{"id":1,"name":"Johnny","age":22,"address":"Moscow, Red Square","work":"Yandex"}` + "\n" +
+				`{"name":"Den","age":"30"}` + "\n" +
+				"\n" +
+				`{"name":"Den","age":30,"salary":1}` + "\n" +
+				`{"name":` + "\n" +
+				`{"name":"Den","age":30}` + "\n",
+			opts:    Options{Format: FormatNDJSON},
+			created: []pPersons.CreateParams{johnny, den},
+			report: &Report{
+				Rows:     5,
+				Imported: 2,
+				Failed:   3,
+				Errors: []RowError{
+					{Line: 2, Error: pErrors.ErrInvalidData.Error(), Errors: validation.Errors{"age": "has wrong type"}},
+					{Line: 4, Error: pErrors.ErrInvalidData.Error(), Errors: validation.Errors{"salary": "unknown field"}},
+					{Line: 5, Error: pErrors.ErrInvalidData.Error()},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &batchRepository{}
+			imp := New(repo, zap.NewNop())
+
+			report, err := imp.Import(context.TODO(), strings.NewReader(test.input), test.opts)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if !reflect.DeepEqual(report, test.report) {
+				t.Errorf("\nExpected: %+v\nGot: %+v", test.report, report)
+			}
+			if !reflect.DeepEqual(repo.created, test.created) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.created, repo.created)
+			}
+		})
+	}
+}
+
+func TestImportChunks(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("name\n")
+	for i := 0; i < 2*ChunkSize+1; i++ {
+		input.WriteString("Johnny\n")
+	}
+
+	repo := &batchRepository{}
+	report, err := New(repo, zap.NewNop()).Import(context.TODO(), strings.NewReader(input.String()),
+		Options{Format: FormatCSV})
+	if err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+	}
+	if report.Imported != 2*ChunkSize+1 || len(repo.created) != 2*ChunkSize+1 {
+		t.Errorf("\nExpected: %d imported\nGot: %d imported, %d created", 2*ChunkSize+1, report.Imported,
+			len(repo.created))
+	}
+}
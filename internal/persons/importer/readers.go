@@ -0,0 +1,209 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+// row is a parsed row of a file, err tells why the row can not be imported.
+type row struct {
+	line   int
+	params pPersons.CreateParams
+	err    error
+}
+
+type rowReader interface {
+	// next returns io.EOF after the last row and other errors if the rest of the file can not be read.
+	next() (row, error)
+}
+
+// ignoredColumns are exported by GET /persons/export but are assigned by the database on import.
+var ignoredColumns = map[string]struct{}{
+	"id":      {},
+	"version": {},
+}
+
+// csvReader reads rows of a CSV file whose header names the columns: name is required,
+// age, address and work are optional.
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, validation.Errors{"header": "is missing"}
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, validation.Errors{"header": parseErr.Err.Error()}
+		}
+		return nil, err
+	}
+
+	columns := make([]string, len(header))
+	seen := make(map[string]struct{}, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if _, ok := ignoredColumns[name]; ok {
+			continue
+		}
+		if _, ok := pPersons.Fields[name]; !ok {
+			return nil, validation.Errors{"header": "unknown column " + strconv.Quote(name)}
+		}
+		if _, ok := seen[name]; ok {
+			return nil, validation.Errors{"header": "duplicated column " + strconv.Quote(name)}
+		}
+		seen[name] = struct{}{}
+		columns[i] = name
+	}
+	if _, ok := seen["name"]; !ok {
+		return nil, validation.Errors{"header": `column "name" is missing`}
+	}
+
+	return &csvReader{
+		reader:  reader,
+		columns: columns,
+	}, nil
+}
+
+func (r *csvReader) next() (row, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if !errors.As(err, &parseErr) {
+			return row{}, err
+		}
+		return row{
+			line: parseErr.StartLine,
+			err:  errors.Wrap(pErrors.ErrInvalidData, parseErr.Err.Error()),
+		}, nil
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	result := row{line: line}
+	errs := validation.Errors{}
+	for i, value := range record {
+		switch r.columns[i] {
+		case "name":
+			result.params.Name = value
+		case "age":
+			if value == "" {
+				continue
+			}
+			age, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				errs["age"] = "must be an integer"
+			}
+			result.params.Age = age
+		case "address":
+			result.params.Address = value
+		case "work":
+			result.params.Work = value
+		}
+	}
+	if len(errs) > 0 {
+		result.err = errs
+	}
+	return result, nil
+}
+
+// maxNDJSONLine bounds the memory used for a single line of an NDJSON file.
+const maxNDJSONLine = 1 << 20
+
+type ndjsonRow struct {
+	ID      *int64 `json:"id"`
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Address string `json:"address"`
+	Work    string `json:"work"`
+}
+
+// ndjsonReader reads rows of a file with a JSON object per line. Blank lines are skipped.
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+	return &ndjsonReader{
+		scanner: scanner,
+	}
+}
+
+func (r *ndjsonReader) next() (row, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		return decodeNDJSONRow(r.line, data), nil
+	}
+
+	err := r.scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return row{}, validation.Errors{
+			fmt.Sprintf("line %d", r.line+1): fmt.Sprintf("is longer than %d bytes", maxNDJSONLine),
+		}
+	}
+	if err != nil {
+		return row{}, err
+	}
+	return row{}, io.EOF
+}
+
+// decodeNDJSONRow parses a JSON object, unknown members and members of wrong types make the row invalid.
+func decodeNDJSONRow(line int, data []byte) row {
+	result := row{line: line}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var person ndjsonRow
+	err := decoder.Decode(&person)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the object")
+	}
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			result.err = validation.Errors{typeErr.Field: "has wrong type"}
+		} else if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			result.err = validation.Errors{strings.Trim(field, `"`): "unknown field"}
+		} else {
+			result.err = errors.Wrap(pErrors.ErrInvalidData, err.Error())
+		}
+		return result
+	}
+
+	result.params = pPersons.CreateParams{
+		Name:    person.Name,
+		Age:     person.Age,
+		Address: person.Address,
+		Work:    person.Work,
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+)
+
+// Publisher delivers events to downstream services. An error means the event may not have been delivered,
+// so it is published again later; consumers must therefore tolerate duplicates, which share the event id.
+type Publisher interface {
+	Publish(ctx context.Context, event *models.PersonEvent) error
+}
+
+// Fanout publishes every event to all of its publishers. If one of them fails, the event is published again
+// to all of them later.
+type Fanout []Publisher
+
+func (f Fanout) Publish(ctx context.Context, event *models.PersonEvent) error {
+	for _, publisher := range f {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Memory keeps published events in memory, it is meant for tests.
+type Memory struct {
+	mu     sync.Mutex
+	events []models.PersonEvent
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Publish(_ context.Context, event *models.PersonEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, *event)
+	return nil
+}
+
+// Events returns the events published so far in the order of publishing.
+func (m *Memory) Events() []models.PersonEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := make([]models.PersonEvent, len(m.events))
+	copy(events, m.events)
+	return events
+}
@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/backoff"
+)
+
+// Store is the outbox table written by the repository along with person changes.
+type Store interface {
+	ClaimEvents(ctx context.Context, limit int64, lease time.Duration) ([]models.PersonEvent, error)
+	MarkEventPublished(ctx context.Context, eventID int64) error
+	RetryEvent(ctx context.Context, eventID int64, delay time.Duration, reason string) error
+}
+
+type Config struct {
+	// Interval is how often the outbox is polled for new events.
+	Interval time.Duration
+	// BatchSize is the number of events claimed at once.
+	BatchSize int64
+	// Lease is how long claimed events stay hidden from other relays, it must exceed the time to publish a batch.
+	Lease time.Duration
+	// MaxBackoff caps the delay between attempts to publish an event, which doubles with every failed attempt.
+	MaxBackoff time.Duration
+}
+
+// minBackoff is the delay before the second attempt to publish an event.
+const minBackoff = time.Second
+
+// Relay moves events from the outbox to a publisher. Events are marked as published only after the publisher
+// accepted them, so every event is delivered at least once, even if the relay crashes in between.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	config    Config
+	log       *zap.Logger
+}
+
+func New(store Store, publisher Publisher, config Config, log *zap.Logger) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		config:    config,
+		log:       log,
+	}
+}
+
+// Run publishes pending events right away and then once per interval until ctx is done.
+func (relay *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(relay.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := relay.PublishPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			relay.log.Error("Failed to relay outbox events", zap.Int("published", published), zap.Error(err))
+		} else if published > 0 {
+			relay.log.Debug("Outbox events published", zap.Int("published", published))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishPending publishes the events that are due in batches until the outbox has no more of them
+// and returns the number of published events. Events the publisher fails on are scheduled for a retry.
+func (relay *Relay) PublishPending(ctx context.Context) (int, error) {
+	var total int
+	for {
+		events, err := relay.store.ClaimEvents(ctx, relay.config.BatchSize, relay.config.Lease)
+		if err != nil {
+			return total, err
+		}
+
+		for i := range events {
+			published, err := relay.publish(ctx, &events[i])
+			if err != nil {
+				return total, err
+			}
+			if published {
+				total++
+			}
+		}
+
+		if int64(len(events)) < relay.config.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// publish passes the event to the publisher and records the outcome. Only store errors are returned,
+// a failed attempt to publish is not an error of the relay.
+func (relay *Relay) publish(ctx context.Context, event *models.PersonEvent) (bool, error) {
+	err := relay.publisher.Publish(ctx, event)
+	if ctx.Err() != nil {
+		// The lease runs out and the event is claimed again.
+		return false, ctx.Err()
+	}
+	if err != nil {
+		delay := backoff.Exponential(event.Attempts, minBackoff, relay.config.MaxBackoff)
+		relay.log.Warn("Failed to publish outbox event",
+			zap.Int64("event_id", event.ID),
+			zap.String("event_type", event.Type),
+			zap.Int("attempts", event.Attempts),
+			zap.Duration("retry_in", delay),
+			zap.Error(err))
+		return false, relay.store.RetryEvent(ctx, event.ID, delay, err.Error())
+	}
+
+	return true, relay.store.MarkEventPublished(ctx, event.ID)
+}
@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+// store is an outbox of pending events. Claimed events stay claimed, like within a lease.
+type store struct {
+	pending   []models.PersonEvent
+	published []int64
+	retried   map[int64]time.Duration
+	failClaim bool
+}
+
+func (s *store) ClaimEvents(_ context.Context, limit int64, _ time.Duration) ([]models.PersonEvent, error) {
+	if s.failClaim {
+		return nil, pErrors.ErrDb
+	}
+	claimed := s.pending[:min(int64(len(s.pending)), limit)]
+	s.pending = s.pending[len(claimed):]
+	for i := range claimed {
+		claimed[i].Attempts++
+	}
+	return claimed, nil
+}
+
+func (s *store) MarkEventPublished(_ context.Context, eventID int64) error {
+	s.published = append(s.published, eventID)
+	return nil
+}
+
+func (s *store) RetryEvent(_ context.Context, eventID int64, delay time.Duration, _ string) error {
+	s.retried[eventID] = delay
+	return nil
+}
+
+// failingPublisher fails on the events with the given ids and passes the rest on.
+type failingPublisher struct {
+	Memory
+	fail map[int64]bool
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, event *models.PersonEvent) error {
+	if p.fail[event.ID] {
+		return errors.New("connection refused")
+	}
+	return p.Memory.Publish(ctx, event)
+}
+
+func TestPublishPending(t *testing.T) {
+	type testCase struct {
+		pending   []models.PersonEvent
+		fail      map[int64]bool
+		failClaim bool
+		published []int64
+		retried   map[int64]time.Duration
+		err       error
+	}
+
+	events := func(ids ...int64) []models.PersonEvent {
+		events := make([]models.PersonEvent, len(ids))
+		for i, id := range ids {
+			events[i] = models.PersonEvent{ID: id, Type: models.EventPersonCreated, PersonID: id}
+		}
+		return events
+	}
+
+	tests := map[string]testCase{
+		"no events": {
+			pending:   nil,
+			published: nil,
+			retried:   map[int64]time.Duration{},
+		},
+		"several batches": {
+			pending:   events(1, 2, 3, 4, 5),
+			published: []int64{1, 2, 3, 4, 5},
+			retried:   map[int64]time.Duration{},
+		},
+		"publisher error": {
+			pending:   events(1, 2, 3),
+			fail:      map[int64]bool{2: true},
+			published: []int64{1, 3},
+			retried:   map[int64]time.Duration{2: time.Second},
+		},
+		"claim error": {
+			pending:   events(1, 2),
+			failClaim: true,
+			published: nil,
+			retried:   map[int64]time.Duration{},
+			err:       pErrors.ErrDb,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &store{pending: test.pending, retried: map[int64]time.Duration{}, failClaim: test.failClaim}
+			publisher := &failingPublisher{fail: test.fail}
+			relay := New(s, publisher, Config{
+				Interval:   time.Second,
+				BatchSize:  2,
+				Lease:      time.Minute,
+				MaxBackoff: time.Minute,
+			}, zap.NewNop())
+
+			published, err := relay.PublishPending(context.TODO())
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if published != len(test.published) {
+				t.Errorf("\nExpected: %d\nGot: %d", len(test.published), published)
+			}
+			if !reflect.DeepEqual(s.published, test.published) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.published, s.published)
+			}
+			if !reflect.DeepEqual(s.retried, test.retried) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.retried, s.retried)
+			}
+			if len(publisher.Events()) != len(test.published) {
+				t.Errorf("\nExpected: %d\nGot: %d", len(test.published), len(publisher.Events()))
+			}
+		})
+	}
+}
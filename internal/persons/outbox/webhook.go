@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+)
+
+const (
+	EventIDHeader   = "X-Event-ID"
+	EventTypeHeader = "X-Event-Type"
+)
+
+// Webhook publishes every event as a JSON POST request to a fixed URL. Any 2xx response acknowledges the event.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook sends the requests with transport, http.DefaultTransport if it is nil.
+func NewWebhook(url string, timeout time.Duration, transport http.RoundTripper) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout, Transport: transport},
+	}
+}
+
+func (wh *Webhook) Publish(ctx context.Context, event *models.PersonEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, strconv.FormatInt(event.ID, 10))
+	req.Header.Set(EventTypeHeader, event.Type)
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Draining the body lets the connection be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+)
+
+func TestWebhookPublish(t *testing.T) {
+	type testCase struct {
+		status  int
+		wantErr bool
+	}
+
+	tests := map[string]testCase{
+		"accepted": {status: http.StatusAccepted, wantErr: false},
+		"rejected": {status: http.StatusServiceUnavailable, wantErr: true},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			event := models.PersonEvent{
+				ID:       7,
+				Type:     models.EventPersonUpdated,
+				PersonID: 3,
+				Payload:  json.RawMessage(`{"operation":"update"}`),
+			}
+
+			var received models.PersonEvent
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(EventIDHeader) != "7" || r.Header.Get(EventTypeHeader) != event.Type {
+					t.Errorf("\nUnexpected event headers: %v", r.Header)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					t.Errorf("\nCan't decode event: %s", err)
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			err := NewWebhook(server.URL, time.Second, nil).Publish(context.TODO(), &event)
+			if (err != nil) != test.wantErr {
+				t.Errorf("\nExpected error: %t\nGot: %v", test.wantErr, err)
+			}
+			if received.ID != event.ID || string(received.Payload) != string(event.Payload) {
+				t.Errorf("\nExpected: %+v\nGot: %+v", event, received)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package purge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Purger permanently removes soft deleted persons.
+type Purger interface {
+	Purge(ctx context.Context, deletedBefore time.Time, limit int64) (int64, error)
+}
+
+// Job periodically removes persons that were soft deleted longer than the retention period ago.
+type Job struct {
+	purger    Purger
+	retention time.Duration
+	interval  time.Duration
+	batchSize int64
+	now       func() time.Time
+	log       *zap.Logger
+}
+
+// New returns an error if interval or batchSize is not positive, with which Run could not tick or
+// PurgeExpired would never finish.
+func New(purger Purger, retention, interval time.Duration, batchSize int64, log *zap.Logger) (*Job, error) {
+	switch {
+	case interval <= 0:
+		return nil, fmt.Errorf("purge: interval must be positive, got %s", interval)
+	case batchSize <= 0:
+		return nil, fmt.Errorf("purge: batch size must be positive, got %d", batchSize)
+	}
+	return &Job{
+		purger:    purger,
+		retention: retention,
+		interval:  interval,
+		batchSize: batchSize,
+		now:       time.Now,
+		log:       log,
+	}, nil
+}
+
+// Run purges expired persons right away and then once per interval until ctx is done.
+func (job *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := job.PurgeExpired(ctx)
+		if err != nil && ctx.Err() == nil {
+			job.log.Error("Failed to purge deleted persons", zap.Int64("purged", purged), zap.Error(err))
+		} else if purged > 0 {
+			job.log.Info("Deleted persons purged", zap.Int64("purged", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpired removes persons deleted before the retention period in batches, so that a single statement
+// never locks too many rows, and returns the number of removed persons.
+func (job *Job) PurgeExpired(ctx context.Context) (int64, error) {
+	deletedBefore := job.now().Add(-job.retention)
+
+	var total int64
+	for {
+		purged, err := job.purger.Purge(ctx, deletedBefore, job.batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += purged
+		if purged < job.batchSize {
+			return total, nil
+		}
+	}
+}
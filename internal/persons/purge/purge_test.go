@@ -0,0 +1,121 @@
+package purge
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+// purger removes persons from a fixed number of expired ones and records the calls it gets.
+type purger struct {
+	expired int64
+	failAt  int
+	calls   []time.Time
+}
+
+func (p *purger) Purge(_ context.Context, deletedBefore time.Time, limit int64) (int64, error) {
+	p.calls = append(p.calls, deletedBefore)
+	if len(p.calls) == p.failAt {
+		return 0, pErrors.ErrDb
+	}
+	purged := min(p.expired, limit)
+	p.expired -= purged
+	return purged, nil
+}
+
+func TestPurgeExpired(t *testing.T) {
+	type testCase struct {
+		purger *purger
+		purged int64
+		calls  int
+		err    error
+	}
+
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	deletedBefore := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]testCase{
+		"nothing to purge": {
+			purger: &purger{},
+			purged: 0,
+			calls:  1,
+			err:    nil,
+		},
+		"several batches": {
+			purger: &purger{expired: 250},
+			purged: 250,
+			calls:  3,
+			err:    nil,
+		},
+		"exact batch": {
+			purger: &purger{expired: 200},
+			purged: 200,
+			calls:  3,
+			err:    nil,
+		},
+		"db error": {
+			purger: &purger{expired: 250, failAt: 2},
+			purged: 100,
+			calls:  2,
+			err:    pErrors.ErrDb,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			job, err := New(test.purger, 30*24*time.Hour, time.Hour, 100, zap.NewNop())
+			if err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+			}
+			job.now = func() time.Time { return now }
+
+			purged, err := job.PurgeExpired(context.TODO())
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if purged != test.purged {
+				t.Errorf("\nExpected: %d\nGot: %d", test.purged, purged)
+			}
+
+			calls := make([]time.Time, test.calls)
+			for i := range calls {
+				calls[i] = deletedBefore
+			}
+			if !reflect.DeepEqual(test.purger.calls, calls) {
+				t.Errorf("\nExpected: %v\nGot: %v", calls, test.purger.calls)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := map[string]struct {
+		interval  time.Duration
+		batchSize int64
+	}{
+		"zero interval":       {interval: 0, batchSize: 100},
+		"negative interval":   {interval: -time.Hour, batchSize: 100},
+		"zero batch size":     {interval: time.Hour, batchSize: 0},
+		"negative batch size": {interval: time.Hour, batchSize: -1},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := New(&purger{}, 30*24*time.Hour, test.interval, test.batchSize, zap.NewNop()); err == nil {
+				t.Errorf("\nExpected: error\nGot: %v", err)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package persons
 
 import (
 	"context"
+	"time"
 
 	"github.com/SlavaShagalov/ds-lab1/internal/models"
 )
@@ -19,13 +20,159 @@ type PartialUpdateParams struct {
 	Age     *int
 	Address *string
 	Work    *string
+	// ExpectedVersion makes the update fail with ErrVersionMismatch if the person has another version,
+	// 0 updates any version.
+	ExpectedVersion int64
+}
+
+// ReplaceParams is a full person representation. A person with ID is created if it does not exist.
+type ReplaceParams struct {
+	ID      int64
+	Name    string
+	Age     int
+	Address string
+	Work    string
+	// ExpectedVersion requires the person to exist and have this version, 0 replaces or creates the person.
+	ExpectedVersion int64
+}
+
+type BatchMode int
+
+const (
+	// BatchAtomic applies every item of a batch or none of them.
+	BatchAtomic BatchMode = iota
+	// BatchBestEffort applies each item independently, failed items do not affect the others.
+	BatchBestEffort
+)
+
+// BatchResult is the outcome of one batch item. Err is ErrBatchAborted for items of an atomic batch
+// that were rolled back because of a failure of another item.
+type BatchResult struct {
+	Person *models.Person
+	Err    error
+}
+
+type FieldType int
+
+const (
+	FieldInt FieldType = iota
+	FieldString
+)
+
+// Fields lists person fields that can be used in list criteria, each name is also its column name.
+var Fields = map[string]FieldType{
+	"id":      FieldInt,
+	"name":    FieldString,
+	"age":     FieldInt,
+	"address": FieldString,
+	"work":    FieldString,
+}
+
+type Operator string
+
+const (
+	OpEq Operator = "="
+	OpNe Operator = "!="
+	OpGt Operator = ">"
+	OpGe Operator = ">="
+	OpLt Operator = "<"
+	OpLe Operator = "<="
+	// OpContains is a case-insensitive substring match.
+	OpContains Operator = "~="
+)
+
+type Condition struct {
+	Field string
+	Op    Operator
+	// Value is int64 for FieldInt fields and string for FieldString fields.
+	Value any
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Criteria narrows and orders a persons list. Conditions are combined with AND.
+type Criteria struct {
+	Conditions []Condition
+	Sort       []SortField
+}
+
+// Cursor positions a keyset page relative to the person with ID.
+type Cursor struct {
+	ID int64
+	// Backward selects persons with smaller ids, otherwise persons with greater ids are selected.
+	Backward bool
+}
+
+type ListParams struct {
+	Offset int64
+	// Limit of 0 means no limit.
+	Limit int64
+	// Cursor switches to keyset pagination on id; Offset is ignored then.
+	// Persons are returned in ascending id order unless Criteria.Sort is set, which is not allowed with Cursor.
+	Cursor   *Cursor
+	Criteria Criteria
+	// IncludeDeleted lists soft deleted persons along with the others.
+	IncludeDeleted bool
+}
+
+type SearchParams struct {
+	Query  string
+	Offset int64
+	// Limit of 0 means no limit.
+	Limit int64
+}
+
+type SearchResult struct {
+	models.Person
+	Rank float64 `db:"rank"`
+	// Snippet is name, address and work with matches wrapped into <mark></mark>.
+	Snippet string `db:"snippet"`
+}
+
+type HistoryParams struct {
+	PersonID int64
+	// BeforeID continues the history with changes older than the change with this id, 0 starts from the latest.
+	BeforeID int64
+	// Limit of 0 means no limit.
+	Limit int64
 }
 
 type Repository interface {
-	//HealthCheck(ctx context.Context) error
+	HealthCheck(ctx context.Context) error
 	Create(ctx context.Context, params *CreateParams) (*models.Person, error)
 	Get(ctx context.Context, personID int64) (*models.Person, error)
-	List(ctx context.Context, offset, limit int64) ([]models.Person, error)
+	List(ctx context.Context, params *ListParams) ([]models.Person, error)
+	// Export passes the persons selected by params to fn one by one as they are read from the database,
+	// so memory use does not depend on the number of persons. An error returned by fn stops the export.
+	Export(ctx context.Context, params *ListParams, fn func(person *models.Person) error) error
+	Search(ctx context.Context, params *SearchParams) ([]SearchResult, error)
+	// Replace overwrites every field of the person, created reports that there was no person with params.ID.
+	Replace(ctx context.Context, params *ReplaceParams) (person *models.Person, created bool, err error)
 	PartialUpdate(ctx context.Context, person *PartialUpdateParams) (*models.Person, error)
-	Delete(ctx context.Context, personID int64) error
+	// Delete removes the person if its version equals expectedVersion, 0 removes any version.
+	Delete(ctx context.Context, personID int64, expectedVersion int64) error
+	// Restore undoes the soft delete of a person, a person that is not deleted is returned as is.
+	Restore(ctx context.Context, personID int64) (*models.Person, error)
+	// Purge permanently removes at most limit persons soft deleted before deletedBefore
+	// and returns the number of removed persons.
+	Purge(ctx context.Context, deletedBefore time.Time, limit int64) (int64, error)
+	// History returns the audit trail of a person, newest changes first. Every write method records
+	// its changes in the same transaction, so the trail is complete.
+	History(ctx context.Context, params *HistoryParams) ([]models.PersonChange, error)
+
+	// ClaimEvents leases at most limit outbox events that are due for publishing, oldest first. Claimed events
+	// are hidden from other relays for lease, events neither published nor retried by then are claimed again.
+	ClaimEvents(ctx context.Context, limit int64, lease time.Duration) ([]models.PersonEvent, error)
+	MarkEventPublished(ctx context.Context, eventID int64) error
+	// RetryEvent schedules another attempt to publish the event after delay and records why the last one failed.
+	RetryEvent(ctx context.Context, eventID int64, delay time.Duration, reason string) error
+
+	// Batch methods return one result per item, in the order of the items.
+	// Errors that concern the whole batch, such as a failed commit, are returned separately.
+	CreateBatch(ctx context.Context, params []CreateParams, mode BatchMode) ([]BatchResult, error)
+	PartialUpdateBatch(ctx context.Context, params []PartialUpdateParams, mode BatchMode) ([]BatchResult, error)
+	DeleteBatch(ctx context.Context, personIDs []int64, mode BatchMode) ([]BatchResult, error)
 }
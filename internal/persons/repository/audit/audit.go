@@ -0,0 +1,101 @@
+// Package audit builds the audit trail entries written by the repository backends along with person changes.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+)
+
+// Columns is the number of values inserted for every change.
+const Columns = 6
+
+func Created(ctx context.Context, person *models.Person) models.PersonChange {
+	return newChange(ctx, models.OperationCreate, person.ID, nil, fields(person))
+}
+
+func Deleted(ctx context.Context, person *models.Person) models.PersonChange {
+	return newChange(ctx, models.OperationDelete, person.ID, fields(person), nil)
+}
+
+func Restored(ctx context.Context, person *models.Person) models.PersonChange {
+	return newChange(ctx, models.OperationRestore, person.ID, nil, fields(person))
+}
+
+// Updated returns the change from before to after, or false if none of the person fields changed.
+func Updated(ctx context.Context, before, after *models.Person) (models.PersonChange, bool) {
+	oldFields, newFields := fields(before), fields(after)
+	for name, value := range oldFields {
+		if newFields[name] == value {
+			delete(oldFields, name)
+			delete(newFields, name)
+		}
+	}
+	if len(newFields) == 0 {
+		return models.PersonChange{}, false
+	}
+	return newChange(ctx, models.OperationUpdate, after.ID, oldFields, newFields), true
+}
+
+// Replaced returns the changes made by replacing before, which is nil if there was no such person, with after.
+func Replaced(ctx context.Context, before, after *models.Person) []models.PersonChange {
+	if before == nil {
+		return []models.PersonChange{Created(ctx, after)}
+	}
+	if change, changed := Updated(ctx, before, after); changed {
+		return []models.PersonChange{change}
+	}
+	return nil
+}
+
+// Args returns the values of the change in the order of the person_audit columns
+// person_id, operation, before, after, actor, request_id.
+func Args(change *models.PersonChange) []any {
+	return []any{
+		change.PersonID,
+		change.Operation,
+		jsonArg(change.Before),
+		jsonArg(change.After),
+		change.Actor,
+		change.RequestID,
+	}
+}
+
+// jsonArg passes a document as text, which both drivers accept for jsonb, and a missing one as NULL.
+func jsonArg(doc json.RawMessage) any {
+	if doc == nil {
+		return nil
+	}
+	return string(doc)
+}
+
+func fields(person *models.Person) map[string]any {
+	return map[string]any{
+		"name":    person.Name,
+		"age":     person.Age,
+		"address": person.Address,
+		"work":    person.Work,
+	}
+}
+
+func newChange(ctx context.Context, operation string, personID int64, before, after map[string]any) models.PersonChange {
+	return models.PersonChange{
+		PersonID:  personID,
+		Operation: operation,
+		Before:    marshal(before),
+		After:     marshal(after),
+		Actor:     requestinfo.Actor(ctx),
+		RequestID: requestinfo.RequestID(ctx),
+	}
+}
+
+// marshal encodes person fields, which can not fail. Keys are sorted, so equal changes give equal documents.
+func marshal(fields map[string]any) json.RawMessage {
+	if fields == nil {
+		return nil
+	}
+	doc, _ := json.Marshal(fields)
+	return doc
+}
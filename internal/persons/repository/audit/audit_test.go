@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+)
+
+func TestUpdated(t *testing.T) {
+	type testCase struct {
+		after   models.Person
+		changed bool
+		before  string
+		updated string
+	}
+
+	before := models.Person{ID: 3, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1}
+
+	tests := map[string]testCase{
+		"changed fields": {
+			after:   models.Person{ID: 3, Name: "Johnny", Age: 23, Address: "Kazan", Work: "Yandex", Version: 2},
+			changed: true,
+			before:  `{"address":"Moscow, Red Square","age":22}`,
+			updated: `{"address":"Kazan","age":23}`,
+		},
+		"same fields": {
+			after:   models.Person{ID: 3, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 2},
+			changed: false,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := requestinfo.WithRequestID(requestinfo.WithActor(context.Background(), "admin"), "abc")
+			change, changed := Updated(ctx, &before, &test.after)
+			if changed != test.changed {
+				t.Fatalf("\nExpected: %t\nGot: %t", test.changed, changed)
+			}
+			if !changed {
+				return
+			}
+
+			expected := models.PersonChange{
+				PersonID:  3,
+				Operation: models.OperationUpdate,
+				Before:    []byte(test.before),
+				After:     []byte(test.updated),
+				Actor:     "admin",
+				RequestID: "abc",
+			}
+			if !reflect.DeepEqual(change, expected) {
+				t.Errorf("\nExpected: %+v\nGot: %+v", expected, change)
+			}
+		})
+	}
+}
+
+func TestReplaced(t *testing.T) {
+	type testCase struct {
+		before     *models.Person
+		operations []string
+	}
+
+	after := &models.Person{ID: 3, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 2}
+
+	tests := map[string]testCase{
+		"missing person": {
+			before:     nil,
+			operations: []string{models.OperationCreate},
+		},
+		"changed person": {
+			before:     &models.Person{ID: 3, Name: "Den", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1},
+			operations: []string{models.OperationUpdate},
+		},
+		"same person": {
+			before:     &models.Person{ID: 3, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1},
+			operations: nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var operations []string
+			for _, change := range Replaced(context.Background(), test.before, after) {
+				if change.Actor != requestinfo.AnonymousActor {
+					t.Errorf("\nExpected: %s\nGot: %s", requestinfo.AnonymousActor, change.Actor)
+				}
+				operations = append(operations, change.Operation)
+			}
+			if !reflect.DeepEqual(operations, test.operations) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.operations, operations)
+			}
+		})
+	}
+}
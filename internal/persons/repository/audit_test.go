@@ -0,0 +1,192 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pkgErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+const insertChangesCmd = `
+	WITH changes AS (
+		INSERT INTO person_audit (person_id, operation, before, after, actor, request_id)
+		VALUES %s
+		RETURNING id, person_id, operation, before, after, actor, request_id, created_at
+	)
+	INSERT INTO outbox (event_type, person_id, payload)
+	SELECT CASE operation
+			WHEN 'create' THEN 'PersonCreated'
+			WHEN 'update' THEN 'PersonUpdated'
+			WHEN 'delete' THEN 'PersonDeleted'
+			ELSE 'PersonRestored'
+		END,
+		person_id, to_jsonb(changes)
+	FROM changes;`
+
+// Audit trail documents of the person most tests work with.
+const (
+	johnnyFields = `{"address":"Moscow, Red Square","age":22,"name":"Johnny","work":"Yandex"}`
+	denFields    = `{"address":"Kazan","age":30,"name":"Den","work":"VK"}`
+)
+
+// change is an audit trail entry a test expects to be written, before and after are "" for NULL.
+type change struct {
+	personID  int64
+	operation string
+	before    string
+	after     string
+}
+
+// expectChanges expects changes to be written to the audit trail by a request without actor and request id.
+func expectChanges(mock sqlmock.Sqlmock, changes ...change) *sqlmock.ExpectedExec {
+	values := make([]string, len(changes))
+	args := make([]driver.Value, 0, 6*len(changes))
+	for i, c := range changes {
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)",
+			len(args)+1, len(args)+2, len(args)+3, len(args)+4, len(args)+5, len(args)+6)
+		args = append(args, c.personID, c.operation, jsonArg(c.before), jsonArg(c.after), "anonymous", "")
+	}
+
+	query := fmt.Sprintf(insertChangesCmd, strings.Join(values, ", "))
+	return mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(args...)
+}
+
+func jsonArg(doc string) driver.Value {
+	if doc == "" {
+		return nil
+	}
+	return doc
+}
+
+func TestHistory(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		params  pPersons.HistoryParams
+		changes []models.PersonChange
+		err     error
+	}
+
+	const historyCmd = `
+	SELECT id, person_id, operation, before, after, actor, request_id, created_at
+	FROM person_audit
+	WHERE person_id = $1 AND id < $2
+	ORDER BY id DESC
+	LIMIT $3;`
+
+	columns := []string{"id", "person_id", "operation", "before", "after", "actor", "request_id", "created_at"}
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	changes := []models.PersonChange{
+		{
+			ID:        12,
+			PersonID:  3,
+			Operation: models.OperationUpdate,
+			Before:    json.RawMessage(`{"address":"Kazan"}`),
+			After:     json.RawMessage(`{"address":"Moscow, Red Square"}`),
+			Actor:     "admin",
+			RequestID: "5d6f1c",
+			CreatedAt: createdAt.Add(time.Hour),
+		},
+		{
+			ID:        7,
+			PersonID:  3,
+			Operation: models.OperationCreate,
+			After:     json.RawMessage(johnnyFields),
+			Actor:     "anonymous",
+			RequestID: "0e41ab",
+			CreatedAt: createdAt,
+		},
+	}
+	newRows := func() *sqlmock.Rows {
+		rows := sqlmock.NewRows(columns)
+		rows.AddRow(12, 3, "update", []byte(`{"address":"Kazan"}`), []byte(`{"address":"Moscow, Red Square"}`),
+			"admin", "5d6f1c", createdAt.Add(time.Hour))
+		rows.AddRow(7, 3, "create", nil, []byte(johnnyFields), "anonymous", "0e41ab", createdAt)
+		return rows
+	}
+
+	tests := map[string]testCase{
+		"latest changes": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(historyCmd)).
+					WithArgs(3, int64(1<<63-1), 2).
+					WillReturnRows(newRows())
+			},
+			params:  pPersons.HistoryParams{PersonID: 3, Limit: 2},
+			changes: changes,
+			err:     nil,
+		},
+		"older changes": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(historyCmd)).
+					WithArgs(3, 7, nil).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			params:  pPersons.HistoryParams{PersonID: 3, BeforeID: 7},
+			changes: []models.PersonChange{},
+			err:     nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(historyCmd)).
+					WithArgs(3, int64(1<<63-1), 2).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			params:  pPersons.HistoryParams{PersonID: 3, Limit: 2},
+			changes: nil,
+			err:     pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				changes, err := repo.History(context.TODO(), &test.params)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if !reflect.DeepEqual(changes, test.changes) {
+					t.Errorf("\nExpected: %v\nGot: %v", test.changes, changes)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
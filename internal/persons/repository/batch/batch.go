@@ -0,0 +1,69 @@
+// Package batch holds the bookkeeping of batch operations shared by the repository backends.
+package batch
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+// ChunkSize bounds the rows of one multi-row statement, which keeps the number of bind parameters
+// well below the Postgres limit of 65535.
+const ChunkSize = 1000
+
+// SetPersons stores persons returned for a chunk into its results, results and persons are parallel.
+func SetPersons(results []pPersons.BatchResult, persons []models.Person) {
+	for i := range results {
+		if i < len(persons) {
+			results[i].Person = &persons[i]
+		} else {
+			results[i].Err = errors.Wrap(pErrors.ErrDb, "person was not returned")
+		}
+	}
+}
+
+// SetError stores err into every result.
+func SetError(results []pPersons.BatchResult, err error) {
+	for i := range results {
+		results[i].Err = err
+	}
+}
+
+// SetDeleted reports an error for every id that was not deleted and returns whether all of them were.
+func SetDeleted(results []pPersons.BatchResult, ids []int64, deleted []int64) bool {
+	deletedSet := make(map[int64]struct{}, len(deleted))
+	for _, id := range deleted {
+		deletedSet[id] = struct{}{}
+	}
+
+	all := true
+	for i, id := range ids {
+		if _, ok := deletedSet[id]; !ok {
+			results[i].Err = pErrors.ErrPersonNotFound
+			all = false
+		}
+	}
+	return all
+}
+
+// AtomicResults finishes an atomic batch whose transaction ended with err. ErrBatchAborted means that an item
+// failed and the transaction was rolled back, so every other item is reported as aborted.
+// Any other error concerns the batch as a whole and is returned as is.
+func AtomicResults(results []pPersons.BatchResult, err error) ([]pPersons.BatchResult, error) {
+	if err == nil {
+		return results, nil
+	}
+	if !errors.Is(err, pErrors.ErrBatchAborted) {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Person = nil
+		if results[i].Err == nil {
+			results[i].Err = pErrors.ErrBatchAborted
+		}
+	}
+	return results, nil
+}
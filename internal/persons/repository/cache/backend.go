@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/resp"
+)
+
+// Backend names, see the CACHE_BACKEND setting.
+const (
+	BackendNone   = "none"
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Backend stores encoded persons by key until they expire.
+type Backend interface {
+	// Get reports false if there is no unexpired value for the key.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Memory is an in-process backend that evicts the least recently used values once size values are stored.
+type Memory struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type memoryItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewMemory(size int) *Memory {
+	return &Memory{
+		size:  max(size, 1),
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := elem.Value.(*memoryItem)
+	if !m.now().Before(item.expires) {
+		m.remove(elem)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(elem)
+	return item.value, true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item := &memoryItem{key: key, value: value, expires: m.now().Add(ttl)}
+	if elem, ok := m.items[key]; ok {
+		elem.Value = item
+		m.order.MoveToFront(elem)
+		return nil
+	}
+
+	m.items[key] = m.order.PushFront(item)
+	if m.order.Len() > m.size {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := m.items[key]; ok {
+			m.remove(elem)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of stored values, some of them may have expired.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+func (m *Memory) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.items, elem.Value.(*memoryItem).key)
+}
+
+// Redis is a backend on a server speaking the Redis protocol, shared by all service instances.
+type Redis struct {
+	client *resp.Client
+}
+
+func NewRedis(client *resp.Client) *Redis {
+	return &Redis{client: client}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.client.Do(ctx, "GET", key)
+	if errors.Is(err, resp.ErrNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, ok := reply.(string)
+	if !ok {
+		return nil, false, errors.Errorf("unexpected GET reply %T", reply)
+	}
+	return []byte(value), true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.client.Do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.client.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
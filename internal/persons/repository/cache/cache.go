@@ -0,0 +1,239 @@
+// Package cache is a read-through cache of persons in front of the repository.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pMetrics "github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+)
+
+// keyPrefix namespaces the keys in a shared backend, its version changes with the encoding of entries.
+const keyPrefix = "persons:v1:"
+
+// entry is the cached form of a person. Unlike the API representation, it keeps the version.
+type entry struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Address string `json:"address"`
+	Work    string `json:"work"`
+	Version int64  `json:"version"`
+}
+
+// Stats counts Get calls served from the cache (hits) and from the repository (misses). Loads is the number
+// of repository reads, it is below misses when concurrent misses of a person share one read.
+// Errors counts failed backend calls, the cache is skipped for them.
+type Stats struct {
+	Hits   int64
+	Misses int64
+	Loads  int64
+	Errors int64
+}
+
+// Repository caches the persons returned by Get and drops them once they are changed through it.
+// The remaining methods are passed to the wrapped repository.
+//
+// Changes made elsewhere, such as by another instance with a memory backend, and a read that races with
+// a change of the same person may leave a stale person in the cache; the ttl bounds how long it is served.
+type Repository struct {
+	pPersons.Repository
+	backend Backend
+	ttl     time.Duration
+	group   singleflight.Group
+	log     *zap.Logger
+
+	hits     atomic.Int64
+	misses   atomic.Int64
+	loads    atomic.Int64
+	failures atomic.Int64
+}
+
+func New(repo pPersons.Repository, backend Backend, ttl time.Duration, log *zap.Logger) *Repository {
+	return &Repository{
+		Repository: repo,
+		backend:    backend,
+		ttl:        ttl,
+		log:        log,
+	}
+}
+
+func (repo *Repository) Stats() Stats {
+	return Stats{
+		Hits:   repo.hits.Load(),
+		Misses: repo.misses.Load(),
+		Loads:  repo.loads.Load(),
+		Errors: repo.failures.Load(),
+	}
+}
+
+// Collectors report the Stats as counters.
+func (repo *Repository) Collectors() []pMetrics.Collector {
+	counter := func(name, help string, value *atomic.Int64) pMetrics.Collector {
+		return pMetrics.NewCounterFunc(name, help, func() float64 { return float64(value.Load()) })
+	}
+	return []pMetrics.Collector{
+		counter("persons_cache_hits_total", "Number of persons served from the cache.", &repo.hits),
+		counter("persons_cache_misses_total", "Number of persons not found in the cache.", &repo.misses),
+		counter("persons_cache_loads_total", "Number of persons read from the repository on a miss.", &repo.loads),
+		counter("persons_cache_errors_total", "Number of failed calls of the cache backend.", &repo.failures),
+	}
+}
+
+func (repo *Repository) Get(ctx context.Context, id int64) (*models.Person, error) {
+	key := personKey(id)
+	if person, ok := repo.lookup(ctx, key); ok {
+		repo.hits.Add(1)
+		return person, nil
+	}
+	repo.misses.Add(1)
+
+	// The read is shared with concurrent callers, so it must not fail when the caller that started it goes away.
+	loadCtx := context.WithoutCancel(ctx)
+	result := repo.group.DoChan(key, func() (any, error) {
+		repo.loads.Add(1)
+		person, err := repo.Repository.Get(loadCtx, id)
+		if err != nil {
+			return nil, err
+		}
+		repo.store(loadCtx, key, person)
+		return *person, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errors.Wrap(pErrors.ErrDeadlineExceeded, ctx.Err().Error())
+		}
+		return nil, errors.Wrap(pErrors.ErrRequestCanceled, ctx.Err().Error())
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		// Every caller gets its own copy.
+		person := res.Val.(models.Person)
+		return &person, nil
+	}
+}
+
+func (repo *Repository) Replace(ctx context.Context, params *pPersons.ReplaceParams) (*models.Person, bool,
+	error) {
+	defer repo.invalidate(ctx, params.ID)
+	return repo.Repository.Replace(ctx, params)
+}
+
+func (repo *Repository) PartialUpdate(ctx context.Context, params *pPersons.PartialUpdateParams) (
+	*models.Person, error) {
+	defer repo.invalidate(ctx, params.ID)
+	return repo.Repository.PartialUpdate(ctx, params)
+}
+
+func (repo *Repository) Delete(ctx context.Context, id int64, expectedVersion int64) error {
+	defer repo.invalidate(ctx, id)
+	return repo.Repository.Delete(ctx, id, expectedVersion)
+}
+
+func (repo *Repository) Restore(ctx context.Context, id int64) (*models.Person, error) {
+	defer repo.invalidate(ctx, id)
+	return repo.Repository.Restore(ctx, id)
+}
+
+func (repo *Repository) PartialUpdateBatch(ctx context.Context, params []pPersons.PartialUpdateParams,
+	mode pPersons.BatchMode) ([]pPersons.BatchResult, error) {
+	ids := make([]int64, len(params))
+	for i := range params {
+		ids[i] = params[i].ID
+	}
+	defer repo.invalidate(ctx, ids...)
+	return repo.Repository.PartialUpdateBatch(ctx, params, mode)
+}
+
+func (repo *Repository) DeleteBatch(ctx context.Context, ids []int64, mode pPersons.BatchMode) (
+	[]pPersons.BatchResult, error) {
+	defer repo.invalidate(ctx, ids...)
+	return repo.Repository.DeleteBatch(ctx, ids, mode)
+}
+
+func (repo *Repository) lookup(ctx context.Context, key string) (*models.Person, bool) {
+	value, ok, err := repo.backend.Get(ctx, key)
+	if err != nil {
+		repo.failures.Add(1)
+		repo.logger(ctx).Warn("Failed to read cached person", zap.Error(err), zap.String("key", key))
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var e entry
+	if err = json.Unmarshal(value, &e); err != nil {
+		repo.failures.Add(1)
+		repo.logger(ctx).Warn("Failed to decode cached person", zap.Error(err), zap.String("key", key))
+		return nil, false
+	}
+	return &models.Person{
+		ID:      e.ID,
+		Name:    e.Name,
+		Age:     e.Age,
+		Address: e.Address,
+		Work:    e.Work,
+		Version: e.Version,
+	}, true
+}
+
+func (repo *Repository) store(ctx context.Context, key string, person *models.Person) {
+	value, err := json.Marshal(entry{
+		ID:      person.ID,
+		Name:    person.Name,
+		Age:     person.Age,
+		Address: person.Address,
+		Work:    person.Work,
+		Version: person.Version,
+	})
+	if err == nil {
+		err = repo.backend.Set(ctx, key, value, repo.ttl)
+	}
+	if err != nil {
+		repo.failures.Add(1)
+		repo.logger(ctx).Warn("Failed to cache person", zap.Error(err), zap.String("key", key))
+	}
+}
+
+// invalidate drops the cached persons whether the change succeeded or not: a failed commit may still have
+// been applied.
+func (repo *Repository) invalidate(ctx context.Context, ids ...int64) {
+	if len(ids) == 0 {
+		return
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = personKey(id)
+	}
+
+	// A change the caller no longer waits for is still made, so its persons have to be dropped anyway.
+	err := repo.backend.Delete(context.WithoutCancel(ctx), keys...)
+	if err != nil {
+		repo.failures.Add(1)
+		repo.logger(ctx).Warn("Failed to invalidate cached persons", zap.Error(err), zap.Int64s("ids", ids))
+	}
+}
+
+func personKey(id int64) string {
+	return keyPrefix + strconv.FormatInt(id, 10)
+}
+
+// logger returns the logger of the request that ctx belongs to.
+func (repo *Repository) logger(ctx context.Context) *zap.Logger {
+	return requestinfo.Logger(ctx, repo.log)
+}
@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/resp"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/resp/resptest"
+)
+
+// store is a repository of persons that counts reads. Get waits for release when it is set.
+type store struct {
+	pPersons.Repository
+	mu      sync.Mutex
+	persons map[int64]models.Person
+	gets    int
+	release chan struct{}
+}
+
+func newStore() *store {
+	return &store{persons: map[int64]models.Person{
+		3: {ID: 3, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1},
+	}}
+}
+
+func (s *store) Get(_ context.Context, id int64) (*models.Person, error) {
+	if s.release != nil {
+		<-s.release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gets++
+	person, ok := s.persons[id]
+	if !ok {
+		return nil, pErrors.ErrPersonNotFound
+	}
+	return &person, nil
+}
+
+func (s *store) PartialUpdate(_ context.Context, params *pPersons.PartialUpdateParams) (*models.Person, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	person := s.persons[params.ID]
+	if params.Age != nil {
+		person.Age = *params.Age
+	}
+	person.Version++
+	s.persons[params.ID] = person
+	return &person, nil
+}
+
+func (s *store) Delete(_ context.Context, id int64, _ int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.persons, id)
+	return nil
+}
+
+func (s *store) Gets() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.gets
+}
+
+func newRedis(t *testing.T) Backend {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("can't start server: %s", err)
+	}
+	t.Cleanup(server.Close)
+
+	client := resp.NewClient(resp.Config{Addr: server.Addr(), PoolSize: 2, Timeout: time.Second})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedis(client)
+}
+
+func TestGet(t *testing.T) {
+	type testCase struct {
+		backend func(t *testing.T) Backend
+	}
+
+	tests := map[string]testCase{
+		"memory": {
+			backend: func(*testing.T) Backend { return NewMemory(10) },
+		},
+		"redis": {
+			backend: newRedis,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newStore()
+			repo := New(s, test.backend(t), time.Minute, zap.NewNop())
+			ctx := context.Background()
+
+			expected := s.persons[3]
+			for i := 0; i < 2; i++ {
+				person, err := repo.Get(ctx, 3)
+				if err != nil {
+					t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+				}
+				if !reflect.DeepEqual(*person, expected) {
+					t.Errorf("\nExpected: %+v\nGot: %+v", expected, *person)
+				}
+			}
+			if stats := repo.Stats(); stats != (Stats{Hits: 1, Misses: 1, Loads: 1}) {
+				t.Errorf("\nExpected: 1 hit and 1 miss\nGot: %+v", stats)
+			}
+
+			age := 23
+			updated, err := repo.PartialUpdate(ctx, &pPersons.PartialUpdateParams{ID: 3, Age: &age})
+			if err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+			}
+			person, err := repo.Get(ctx, 3)
+			if err != nil || !reflect.DeepEqual(person, updated) {
+				t.Errorf("\nExpected: %+v\nGot: %+v, %v", updated, person, err)
+			}
+
+			if err = repo.Delete(ctx, 3, 0); err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+			}
+			if _, err = repo.Get(ctx, 3); !errors.Is(err, pErrors.ErrPersonNotFound) {
+				t.Errorf("\nExpected: %s\nGot: %v", pErrors.ErrPersonNotFound, err)
+			}
+			if gets := s.Gets(); gets != 3 {
+				t.Errorf("\nExpected: %d reads\nGot: %d", 3, gets)
+			}
+		})
+	}
+}
+
+func TestGetStampede(t *testing.T) {
+	const callers = 10
+
+	s := newStore()
+	s.release = make(chan struct{})
+	repo := New(s, NewMemory(10), time.Minute, zap.NewNop())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Get(context.Background(), 3)
+			errs <- err
+		}()
+	}
+	for repo.Stats().Misses < callers {
+		time.Sleep(time.Millisecond)
+	}
+	// Lets the last caller get from counting the miss to joining the read.
+	time.Sleep(10 * time.Millisecond)
+	close(s.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("\nExpected: %v\nGot: %s", nil, err)
+		}
+	}
+	if gets := s.Gets(); gets != 1 {
+		t.Errorf("\nExpected: %d read\nGot: %d", 1, gets)
+	}
+}
+
+func TestGetCanceled(t *testing.T) {
+	s := newStore()
+	s.release = make(chan struct{})
+	repo := New(s, NewMemory(10), time.Minute, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := repo.Get(ctx, 3); !errors.Is(err, pErrors.ErrRequestCanceled) {
+		t.Errorf("\nExpected: %s\nGot: %v", pErrors.ErrRequestCanceled, err)
+	}
+
+	// The read goes on for other callers and fills the cache.
+	close(s.release)
+	person, err := repo.Get(context.Background(), 3)
+	if err != nil || person.ID != 3 {
+		t.Errorf("\nExpected: person 3\nGot: %+v, %v", person, err)
+	}
+}
+
+func TestGetBackendDown(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("can't start server: %s", err)
+	}
+	server.Close()
+
+	client := resp.NewClient(resp.Config{Addr: server.Addr(), Timeout: time.Second})
+	repo := New(newStore(), NewRedis(client), time.Minute, zap.NewNop())
+
+	person, err := repo.Get(context.Background(), 3)
+	if err != nil || person.ID != 3 {
+		t.Errorf("\nExpected: person 3\nGot: %+v, %v", person, err)
+	}
+	// Both the lookup and the store failed.
+	if stats := repo.Stats(); stats != (Stats{Misses: 1, Loads: 1, Errors: 2}) {
+		t.Errorf("\nExpected: 2 errors\nGot: %+v", stats)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory(2)
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = m.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = m.Set(ctx, "b", []byte("2"), 2*time.Minute)
+	// Reading a makes b the least recently used value.
+	if _, ok, _ := m.Get(ctx, "a"); !ok {
+		t.Errorf("\nExpected: a\nGot: nothing")
+	}
+	_ = m.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Errorf("\nExpected: b evicted\nGot: b")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Errorf("\nExpected: a expired\nGot: a")
+	}
+	if m.Len() != 1 {
+		t.Errorf("\nExpected: %d\nGot: %d", 1, m.Len())
+	}
+
+	_ = m.Delete(ctx, "c", "d")
+	if m.Len() != 0 {
+		t.Errorf("\nExpected: %d\nGot: %d", 0, m.Len())
+	}
+}
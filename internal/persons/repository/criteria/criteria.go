@@ -0,0 +1,128 @@
+package criteria
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+// Placeholder registers value as a query argument and returns its placeholder, e.g. $1 or @p1.
+type Placeholder func(value any) string
+
+// Where renders conditions as SQL boolean expressions. Field names are checked against pPersons.Fields,
+// so only whitelisted columns reach the query text; values are always passed as arguments.
+func Where(conditions []pPersons.Condition, placeholder Placeholder) ([]string, error) {
+	clauses := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		if _, ok := pPersons.Fields[condition.Field]; !ok {
+			return nil, errors.Wrapf(pErrors.ErrBadQueryParam, "unknown field %q", condition.Field)
+		}
+
+		switch condition.Op {
+		case pPersons.OpEq, pPersons.OpNe, pPersons.OpGt, pPersons.OpGe, pPersons.OpLt, pPersons.OpLe:
+			op := string(condition.Op)
+			if condition.Op == pPersons.OpNe {
+				op = "<>"
+			}
+			clauses = append(clauses, fmt.Sprintf("%s %s %s", condition.Field, op, placeholder(condition.Value)))
+		case pPersons.OpContains:
+			pattern := "%" + escapeLike(fmt.Sprint(condition.Value)) + "%"
+			clauses = append(clauses, fmt.Sprintf("%s ILIKE %s", condition.Field, placeholder(pattern)))
+		default:
+			return nil, errors.Wrapf(pErrors.ErrBadQueryParam, "unknown operator %q", condition.Op)
+		}
+	}
+	return clauses, nil
+}
+
+// OrderBy renders sort fields as an ORDER BY list with id appended as a tie-breaker.
+func OrderBy(sort []pPersons.SortField) (string, error) {
+	items := make([]string, 0, len(sort)+1)
+	hasID := false
+	for _, field := range sort {
+		if _, ok := pPersons.Fields[field.Field]; !ok {
+			return "", errors.Wrapf(pErrors.ErrBadQueryParam, "unknown field %q", field.Field)
+		}
+
+		item := field.Field
+		if field.Desc {
+			item += " DESC"
+		}
+		items = append(items, item)
+		hasID = hasID || field.Field == "id"
+	}
+	if !hasID {
+		items = append(items, "id")
+	}
+	return strings.Join(items, ", "), nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// ListClauses renders everything that follows FROM in a persons list query: the soft delete filter,
+// keyset or criteria conditions, ordering, offset and limit.
+func ListClauses(params *pPersons.ListParams, placeholder Placeholder) (string, error) {
+	where := make([]string, 0, len(params.Criteria.Conditions)+2)
+	if !params.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+	orderBy := "id"
+	if params.Cursor != nil {
+		if len(params.Criteria.Sort) > 0 {
+			return "", errors.Wrap(pErrors.ErrBadQueryParam, "sort is not supported with cursor pagination")
+		}
+
+		if params.Cursor.Backward {
+			where = append(where, "id < "+placeholder(params.Cursor.ID))
+			orderBy = "id DESC"
+		} else {
+			where = append(where, "id > "+placeholder(params.Cursor.ID))
+		}
+	} else {
+		var err error
+		orderBy, err = OrderBy(params.Criteria.Sort)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	conditions, err := Where(params.Criteria.Conditions, placeholder)
+	if err != nil {
+		return "", err
+	}
+	where = append(where, conditions...)
+
+	var clauses strings.Builder
+	if len(where) > 0 {
+		clauses.WriteString("\n\tWHERE " + strings.Join(where, " AND "))
+	}
+	clauses.WriteString("\n\tORDER BY " + orderBy)
+	if params.Cursor == nil {
+		clauses.WriteString("\n\tOFFSET " + placeholder(params.Offset))
+	}
+	if params.Limit != 0 {
+		clauses.WriteString("\n\tLIMIT " + placeholder(params.Limit))
+	}
+	return clauses.String(), nil
+}
+
+// TSQuery turns free text into a to_tsquery expression that matches persons containing every word as a prefix,
+// so that fragments such as "yand" find "Yandex". Characters other than letters and digits separate words.
+func TSQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = strings.ToLower(word) + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,146 @@
+// Package metrics measures the latency of the methods of a repository of persons.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pMetrics "github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics"
+)
+
+// Outcomes of an operation.
+const (
+	outcomeOK    = "ok"
+	outcomeError = "error"
+)
+
+type repository struct {
+	repo    pPersons.Repository
+	latency *pMetrics.HistogramVec
+}
+
+// New returns a repository that observes the time every method of repo takes, labeled with the method name
+// and whether it failed.
+func New(repo pPersons.Repository, registry *pMetrics.Registry) pPersons.Repository {
+	latency := pMetrics.NewHistogramVec("persons_repository_operation_duration_seconds",
+		"Time it took the repository of persons to run an operation, by operation and outcome.",
+		pMetrics.DefaultBuckets, "operation", "outcome")
+	registry.MustRegister(latency)
+
+	return &repository{
+		repo:    repo,
+		latency: latency,
+	}
+}
+
+// observe is deferred with the start time of an operation and a pointer to its error.
+func (repo *repository) observe(operation string, start time.Time, err *error) {
+	outcome := outcomeOK
+	if *err != nil {
+		outcome = outcomeError
+	}
+	repo.latency.Observe(time.Since(start).Seconds(), operation, outcome)
+}
+
+func (repo *repository) HealthCheck(ctx context.Context) (err error) {
+	defer repo.observe("HealthCheck", time.Now(), &err)
+	return repo.repo.HealthCheck(ctx)
+}
+
+func (repo *repository) Create(ctx context.Context, params *pPersons.CreateParams) (_ *models.Person, err error) {
+	defer repo.observe("Create", time.Now(), &err)
+	return repo.repo.Create(ctx, params)
+}
+
+func (repo *repository) Get(ctx context.Context, personID int64) (_ *models.Person, err error) {
+	defer repo.observe("Get", time.Now(), &err)
+	return repo.repo.Get(ctx, personID)
+}
+
+func (repo *repository) List(ctx context.Context, params *pPersons.ListParams) (_ []models.Person, err error) {
+	defer repo.observe("List", time.Now(), &err)
+	return repo.repo.List(ctx, params)
+}
+
+// Export includes the time fn takes, as the rows are read while the persons are passed to it.
+func (repo *repository) Export(ctx context.Context, params *pPersons.ListParams,
+	fn func(person *models.Person) error) (err error) {
+	defer repo.observe("Export", time.Now(), &err)
+	return repo.repo.Export(ctx, params, fn)
+}
+
+func (repo *repository) Search(ctx context.Context, params *pPersons.SearchParams) (
+	_ []pPersons.SearchResult, err error) {
+	defer repo.observe("Search", time.Now(), &err)
+	return repo.repo.Search(ctx, params)
+}
+
+func (repo *repository) Replace(ctx context.Context, params *pPersons.ReplaceParams) (
+	_ *models.Person, _ bool, err error) {
+	defer repo.observe("Replace", time.Now(), &err)
+	return repo.repo.Replace(ctx, params)
+}
+
+func (repo *repository) PartialUpdate(ctx context.Context, params *pPersons.PartialUpdateParams) (
+	_ *models.Person, err error) {
+	defer repo.observe("PartialUpdate", time.Now(), &err)
+	return repo.repo.PartialUpdate(ctx, params)
+}
+
+func (repo *repository) Delete(ctx context.Context, personID int64, expectedVersion int64) (err error) {
+	defer repo.observe("Delete", time.Now(), &err)
+	return repo.repo.Delete(ctx, personID, expectedVersion)
+}
+
+func (repo *repository) Restore(ctx context.Context, personID int64) (_ *models.Person, err error) {
+	defer repo.observe("Restore", time.Now(), &err)
+	return repo.repo.Restore(ctx, personID)
+}
+
+func (repo *repository) Purge(ctx context.Context, deletedBefore time.Time, limit int64) (_ int64, err error) {
+	defer repo.observe("Purge", time.Now(), &err)
+	return repo.repo.Purge(ctx, deletedBefore, limit)
+}
+
+func (repo *repository) History(ctx context.Context, params *pPersons.HistoryParams) (
+	_ []models.PersonChange, err error) {
+	defer repo.observe("History", time.Now(), &err)
+	return repo.repo.History(ctx, params)
+}
+
+func (repo *repository) ClaimEvents(ctx context.Context, limit int64, lease time.Duration) (
+	_ []models.PersonEvent, err error) {
+	defer repo.observe("ClaimEvents", time.Now(), &err)
+	return repo.repo.ClaimEvents(ctx, limit, lease)
+}
+
+func (repo *repository) MarkEventPublished(ctx context.Context, eventID int64) (err error) {
+	defer repo.observe("MarkEventPublished", time.Now(), &err)
+	return repo.repo.MarkEventPublished(ctx, eventID)
+}
+
+func (repo *repository) RetryEvent(ctx context.Context, eventID int64, delay time.Duration, reason string) (
+	err error) {
+	defer repo.observe("RetryEvent", time.Now(), &err)
+	return repo.repo.RetryEvent(ctx, eventID, delay, reason)
+}
+
+func (repo *repository) CreateBatch(ctx context.Context, params []pPersons.CreateParams, mode pPersons.BatchMode) (
+	_ []pPersons.BatchResult, err error) {
+	defer repo.observe("CreateBatch", time.Now(), &err)
+	return repo.repo.CreateBatch(ctx, params, mode)
+}
+
+func (repo *repository) PartialUpdateBatch(ctx context.Context, params []pPersons.PartialUpdateParams,
+	mode pPersons.BatchMode) (_ []pPersons.BatchResult, err error) {
+	defer repo.observe("PartialUpdateBatch", time.Now(), &err)
+	return repo.repo.PartialUpdateBatch(ctx, params, mode)
+}
+
+func (repo *repository) DeleteBatch(ctx context.Context, personIDs []int64, mode pPersons.BatchMode) (
+	_ []pPersons.BatchResult, err error) {
+	defer repo.observe("DeleteBatch", time.Now(), &err)
+	return repo.repo.DeleteBatch(ctx, personIDs, mode)
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pMetrics "github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics/metricstest"
+)
+
+// store finds the person with id 1 only.
+type store struct {
+	pPersons.Repository
+}
+
+func (store) Get(_ context.Context, id int64) (*models.Person, error) {
+	if id != 1 {
+		return nil, pErrors.ErrPersonNotFound
+	}
+	return &models.Person{ID: 1, Name: "Johnny"}, nil
+}
+
+func (store) Delete(context.Context, int64, int64) error {
+	return nil
+}
+
+func TestRepository(t *testing.T) {
+	registry := pMetrics.NewRegistry()
+	repo := New(store{}, registry)
+
+	for _, id := range []int64{1, 1, 2} {
+		person, err := repo.Get(context.Background(), id)
+		if id == 1 && (err != nil || person.Name != "Johnny") {
+			t.Errorf("\nExpected: Johnny\nGot: %v, %v", person, err)
+		}
+		if id == 2 && err != pErrors.ErrPersonNotFound {
+			t.Errorf("\nExpected: %s\nGot: %v", pErrors.ErrPersonNotFound, err)
+		}
+	}
+	if err := repo.Delete(context.Background(), 1, 0); err != nil {
+		t.Errorf("\nExpected: %v\nGot: %v", nil, err)
+	}
+
+	var buf bytes.Buffer
+	if err := registry.WriteText(&buf); err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+	}
+	samples, _, err := metricstest.Parse(&buf)
+	if err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+	}
+
+	const name = "persons_repository_operation_duration_seconds_count"
+	expected := map[string]float64{
+		metricstest.Key(name, "operation", "Get", "outcome", "ok"):    2,
+		metricstest.Key(name, "operation", "Get", "outcome", "error"): 1,
+		metricstest.Key(name, "operation", "Delete", "outcome", "ok"): 1,
+	}
+	for key, value := range expected {
+		if samples[key] != value {
+			t.Errorf("%s\nExpected: %v\nGot: %v", key, value, samples[key])
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pkgErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+func TestClaimEvents(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		events  []models.PersonEvent
+		err     error
+	}
+
+	const claimEventsCmd = `
+	UPDATE outbox
+	SET attempts = attempts + 1, next_attempt_at = now() + $1 * interval '1 millisecond'
+	WHERE id IN (
+		SELECT id
+		FROM outbox
+		WHERE published_at IS NULL AND next_attempt_at <= now()
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, event_type, person_id, payload, attempts, created_at;`
+
+	columns := []string{"id", "event_type", "person_id", "payload", "attempts", "created_at"}
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []models.PersonEvent{
+		{
+			ID:        4,
+			Type:      models.EventPersonCreated,
+			PersonID:  3,
+			Payload:   json.RawMessage(`{"operation":"create"}`),
+			Attempts:  1,
+			CreatedAt: createdAt,
+		},
+		{
+			ID:        5,
+			Type:      models.EventPersonDeleted,
+			PersonID:  3,
+			Payload:   json.RawMessage(`{"operation":"delete"}`),
+			Attempts:  3,
+			CreatedAt: createdAt.Add(time.Hour),
+		},
+	}
+
+	tests := map[string]testCase{
+		"pending events": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(claimEventsCmd)).
+					WithArgs(60000, 10).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(5, "PersonDeleted", 3, []byte(`{"operation":"delete"}`), 3, createdAt.Add(time.Hour)).
+						AddRow(4, "PersonCreated", 3, []byte(`{"operation":"create"}`), 1, createdAt))
+			},
+			events: events,
+			err:    nil,
+		},
+		"no events": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(claimEventsCmd)).
+					WithArgs(60000, 10).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			events: []models.PersonEvent{},
+			err:    nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(claimEventsCmd)).
+					WithArgs(60000, 10).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			events: nil,
+			err:    pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				events, err := repo.ClaimEvents(context.TODO(), 10, time.Minute)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if !reflect.DeepEqual(events, test.events) {
+					t.Errorf("\nExpected: %v\nGot: %v", test.events, events)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestRetryEvent(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		err     error
+	}
+
+	const retryEventCmd = `
+	UPDATE outbox
+	SET next_attempt_at = now() + $1 * interval '1 millisecond', last_error = $2
+	WHERE id = $3 AND published_at IS NULL;`
+
+	tests := map[string]testCase{
+		"retry scheduled": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(retryEventCmd)).
+					WithArgs(2000, "connection refused", 5).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			err: nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(retryEventCmd)).
+					WithArgs(2000, "connection refused", 5).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			err: pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				err = repo.RetryEvent(context.TODO(), 5, 2*time.Second, "connection refused")
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package pgx
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/repository/audit"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+const insertChangesCmd = `
+	WITH changes AS (
+		INSERT INTO person_audit (person_id, operation, before, after, actor, request_id)
+		VALUES %s
+		RETURNING id, person_id, operation, before, after, actor, request_id, created_at
+	)
+	INSERT INTO outbox (event_type, person_id, payload)
+	SELECT CASE operation
+			WHEN 'create' THEN 'PersonCreated'
+			WHEN 'update' THEN 'PersonUpdated'
+			WHEN 'delete' THEN 'PersonDeleted'
+			ELSE 'PersonRestored'
+		END,
+		person_id, to_jsonb(changes)
+	FROM changes;`
+
+// changeColumns are the person_audit columns in the order of audit.Args.
+var changeColumns = [audit.Columns]string{"person_id", "operation", "before", "after", "actor", "request_id"}
+
+// audit appends changes to the audit trail and queues an event about each of them in the outbox.
+// It must run in the transaction that made the changes.
+func (repo *repository) audit(ctx context.Context, q querier, changes ...models.PersonChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(changes))
+	args := make(pgx.NamedArgs, audit.Columns*len(changes))
+	for i := range changes {
+		placeholders := make([]string, audit.Columns)
+		for j, value := range audit.Args(&changes[i]) {
+			name := fmt.Sprintf("%s%d", changeColumns[j], i)
+			placeholders[j] = "@" + name
+			args[name] = value
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+	query := fmt.Sprintf(insertChangesCmd, strings.Join(values, ", "))
+
+	_, err := q.Exec(ctx, query, args)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", insertChangesCmd))
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+const historyCmd = `
+	SELECT id, person_id, operation, before, after, actor, request_id, created_at
+	FROM person_audit
+	WHERE person_id = @person_id AND id < @before_id
+	ORDER BY id DESC
+	LIMIT @limit;`
+
+func (repo *repository) History(ctx context.Context, params *pPersons.HistoryParams) ([]models.PersonChange, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.list)
+	defer cancel()
+
+	beforeID := params.BeforeID
+	if beforeID == 0 {
+		beforeID = math.MaxInt64
+	}
+	var limit any
+	if params.Limit != 0 {
+		limit = params.Limit
+	}
+
+	rows, err := repo.db.Query(ctx, historyCmd, pgx.NamedArgs{
+		"person_id": params.PersonID,
+		"before_id": beforeID,
+		"limit":     limit,
+	})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", historyCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	changes, err := pgx.CollectRows(rows, scanChange)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", historyCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	return changes, nil
+}
+
+// scanChange reads the documents into byte slices, so that a NULL document is left nil.
+func scanChange(row pgx.CollectableRow) (models.PersonChange, error) {
+	var change models.PersonChange
+	var before, after []byte
+	err := row.Scan(
+		&change.ID,
+		&change.PersonID,
+		&change.Operation,
+		&before,
+		&after,
+		&change.Actor,
+		&change.RequestID,
+		&change.CreatedAt,
+	)
+	change.Before, change.After = before, after
+	return change, err
+}
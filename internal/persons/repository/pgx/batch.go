@@ -0,0 +1,223 @@
+package pgx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/repository/audit"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/repository/batch"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+const createBatchCmd = `
+	INSERT INTO persons (name, age, address, work)
+	VALUES %s
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+func (repo *repository) CreateBatch(ctx context.Context, params []pPersons.CreateParams, mode pPersons.BatchMode) (
+	[]pPersons.BatchResult, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.batch)
+	defer cancel()
+
+	results := make([]pPersons.BatchResult, len(params))
+	if mode == pPersons.BatchBestEffort {
+		for start := 0; start < len(params); start += batch.ChunkSize {
+			end := min(start+batch.ChunkSize, len(params))
+			var persons []models.Person
+			err := repo.inTx(ctx, func(tx pgx.Tx) error {
+				var err error
+				persons, err = repo.createChunk(ctx, tx, params[start:end])
+				return err
+			})
+			if err == nil {
+				batch.SetPersons(results[start:end], persons)
+				continue
+			}
+
+			// The statement fails as a whole, so rows are retried one by one to find out which of them are bad.
+			for i := start; i < end; i++ {
+				results[i].Person, results[i].Err = repo.inTxPerson(ctx, func(tx pgx.Tx) (*models.Person, error) {
+					return repo.create(ctx, tx, &params[i])
+				})
+			}
+		}
+		return results, nil
+	}
+
+	err := repo.inTx(ctx, func(tx pgx.Tx) error {
+		for start := 0; start < len(params); start += batch.ChunkSize {
+			end := min(start+batch.ChunkSize, len(params))
+			persons, err := repo.createChunk(ctx, tx, params[start:end])
+			if err != nil {
+				batch.SetError(results[start:end], err)
+				return pErrors.ErrBatchAborted
+			}
+			batch.SetPersons(results[start:end], persons)
+		}
+		return nil
+	})
+	return batch.AtomicResults(results, err)
+}
+
+func (repo *repository) createChunk(ctx context.Context, q querier, params []pPersons.CreateParams) (
+	[]models.Person, error) {
+	values := make([]string, len(params))
+	args := make(pgx.NamedArgs, 4*len(params))
+	for i, p := range params {
+		values[i] = fmt.Sprintf("(@name%[1]d, @age%[1]d, @address%[1]d, @work%[1]d)", i)
+		args[fmt.Sprintf("name%d", i)] = p.Name
+		args[fmt.Sprintf("age%d", i)] = p.Age
+		args[fmt.Sprintf("address%d", i)] = p.Address
+		args[fmt.Sprintf("work%d", i)] = p.Work
+	}
+	query := fmt.Sprintf(createBatchCmd, strings.Join(values, ", "))
+
+	rows, err := q.Query(ctx, query, args)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", createBatchCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	persons, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Person])
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", createBatchCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	// Ids are drawn from the sequence in VALUES order, while the order of RETURNING rows is not guaranteed.
+	slices.SortFunc(persons, func(a, b models.Person) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	changes := make([]models.PersonChange, len(persons))
+	for i := range persons {
+		changes[i] = audit.Created(ctx, &persons[i])
+	}
+	err = repo.audit(ctx, q, changes...)
+	if err != nil {
+		return nil, err
+	}
+	repo.logger(ctx).Debug("New persons created", zap.Int("count", len(persons)))
+	return persons, nil
+}
+
+func (repo *repository) PartialUpdateBatch(ctx context.Context, params []pPersons.PartialUpdateParams,
+	mode pPersons.BatchMode) ([]pPersons.BatchResult, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.batch)
+	defer cancel()
+
+	results := make([]pPersons.BatchResult, len(params))
+	if mode == pPersons.BatchBestEffort {
+		for i := range params {
+			results[i].Person, results[i].Err = repo.inTxPerson(ctx, func(tx pgx.Tx) (*models.Person, error) {
+				return repo.partialUpdate(ctx, tx, &params[i])
+			})
+		}
+		return results, nil
+	}
+
+	err := repo.inTx(ctx, func(tx pgx.Tx) error {
+		for i := range params {
+			results[i].Person, results[i].Err = repo.partialUpdate(ctx, tx, &params[i])
+			if results[i].Err != nil {
+				return pErrors.ErrBatchAborted
+			}
+		}
+		return nil
+	})
+	return batch.AtomicResults(results, err)
+}
+
+const deleteBatchCmd = `
+	UPDATE persons
+	SET deleted_at = now(), version = version + 1
+	WHERE id IN (%s) AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+func (repo *repository) DeleteBatch(ctx context.Context, ids []int64, mode pPersons.BatchMode) (
+	[]pPersons.BatchResult, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.batch)
+	defer cancel()
+
+	results := make([]pPersons.BatchResult, len(ids))
+	if mode == pPersons.BatchBestEffort {
+		for start := 0; start < len(ids); start += batch.ChunkSize {
+			end := min(start+batch.ChunkSize, len(ids))
+			var deleted []int64
+			err := repo.inTx(ctx, func(tx pgx.Tx) error {
+				var err error
+				deleted, err = repo.deleteChunk(ctx, tx, ids[start:end])
+				return err
+			})
+			if err != nil {
+				batch.SetError(results[start:end], err)
+				continue
+			}
+			batch.SetDeleted(results[start:end], ids[start:end], deleted)
+		}
+		return results, nil
+	}
+
+	err := repo.inTx(ctx, func(tx pgx.Tx) error {
+		for start := 0; start < len(ids); start += batch.ChunkSize {
+			end := min(start+batch.ChunkSize, len(ids))
+			deleted, err := repo.deleteChunk(ctx, tx, ids[start:end])
+			if err != nil {
+				batch.SetError(results[start:end], err)
+				return pErrors.ErrBatchAborted
+			}
+			if !batch.SetDeleted(results[start:end], ids[start:end], deleted) {
+				return pErrors.ErrBatchAborted
+			}
+		}
+		return nil
+	})
+	return batch.AtomicResults(results, err)
+}
+
+func (repo *repository) deleteChunk(ctx context.Context, q querier, ids []int64) ([]int64, error) {
+	placeholders := make([]string, len(ids))
+	args := make(pgx.NamedArgs, len(ids))
+	for i, id := range ids {
+		name := fmt.Sprintf("id%d", i)
+		placeholders[i] = "@" + name
+		args[name] = id
+	}
+	query := fmt.Sprintf(deleteBatchCmd, strings.Join(placeholders, ", "))
+
+	rows, err := q.Query(ctx, query, args)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", deleteBatchCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	persons, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Person])
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", deleteBatchCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	deleted := make([]int64, len(persons))
+	changes := make([]models.PersonChange, len(persons))
+	for i := range persons {
+		deleted[i] = persons[i].ID
+		changes[i] = audit.Deleted(ctx, &persons[i])
+	}
+	err = repo.audit(ctx, q, changes...)
+	if err != nil {
+		return nil, err
+	}
+
+	repo.logger(ctx).Debug("Persons deleted", zap.Int("count", len(deleted)))
+	return deleted, nil
+}
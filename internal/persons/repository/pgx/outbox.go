@@ -0,0 +1,109 @@
+package pgx
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+const claimEventsCmd = `
+	UPDATE outbox
+	SET attempts = attempts + 1, next_attempt_at = now() + @lease_ms * interval '1 millisecond'
+	WHERE id IN (
+		SELECT id
+		FROM outbox
+		WHERE published_at IS NULL AND next_attempt_at <= now()
+		ORDER BY id
+		LIMIT @limit
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, event_type, person_id, payload, attempts, created_at;`
+
+func (repo *repository) ClaimEvents(ctx context.Context, limit int64, lease time.Duration) (
+	[]models.PersonEvent, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	rows, err := repo.db.Query(ctx, claimEventsCmd, pgx.NamedArgs{
+		"limit":    limit,
+		"lease_ms": lease.Milliseconds(),
+	})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", claimEventsCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	events, err := pgx.CollectRows(rows, scanEvent)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", claimEventsCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	// The order of RETURNING rows is not guaranteed, while events are published in the order they happened.
+	slices.SortFunc(events, func(a, b models.PersonEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return events, nil
+}
+
+// scanEvent reads the payload into a byte slice like scanChange does.
+func scanEvent(row pgx.CollectableRow) (models.PersonEvent, error) {
+	var event models.PersonEvent
+	var payload []byte
+	err := row.Scan(
+		&event.ID,
+		&event.Type,
+		&event.PersonID,
+		&payload,
+		&event.Attempts,
+		&event.CreatedAt,
+	)
+	event.Payload = payload
+	return event, err
+}
+
+const markEventPublishedCmd = `
+	UPDATE outbox
+	SET published_at = now(), last_error = NULL
+	WHERE id = @id;`
+
+func (repo *repository) MarkEventPublished(ctx context.Context, eventID int64) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	_, err := repo.db.Exec(ctx, markEventPublishedCmd, pgx.NamedArgs{"id": eventID})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", markEventPublishedCmd))
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+const retryEventCmd = `
+	UPDATE outbox
+	SET next_attempt_at = now() + @delay_ms * interval '1 millisecond', last_error = @reason
+	WHERE id = @id AND published_at IS NULL;`
+
+func (repo *repository) RetryEvent(ctx context.Context, eventID int64, delay time.Duration, reason string) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	_, err := repo.db.Exec(ctx, retryEventCmd, pgx.NamedArgs{
+		"id":       eventID,
+		"delay_ms": delay.Milliseconds(),
+		"reason":   reason,
+	})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", retryEventCmd))
+		return dbError(ctx, err)
+	}
+	return nil
+}
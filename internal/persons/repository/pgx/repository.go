@@ -2,195 +2,672 @@ package pgx
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
 
 	"github.com/SlavaShagalov/ds-lab1/internal/models"
 	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/repository/audit"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/repository/criteria"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
 	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
 	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
-	"github.com/jackc/pgx/v5"
-	"github.com/pkg/errors"
-	"go.uber.org/zap"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation = "23505"
+	queryCanceled   = "57014"
 )
 
+// querier runs queries either directly on the pool or inside a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// DB is the subset of *pgxpool.Pool used by the repository.
+type DB interface {
+	querier
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+}
+
+type timeouts struct {
+	healthCheck time.Duration
+	create      time.Duration
+	get         time.Duration
+	list        time.Duration
+	search      time.Duration
+	update      time.Duration
+	delete      time.Duration
+	batch       time.Duration
+	export      time.Duration
+	purge       time.Duration
+	outbox      time.Duration
+}
+
 type repository struct {
- db  *sql.DB
-	log  *zap.Logger
+	db       DB
+	log      *zap.Logger
+	timeouts timeouts
 }
 
-func New(db  *sql.DB, log *zap.Logger) pPersons.Repository {
+func New(db DB, log *zap.Logger) pPersons.Repository {
 	return &repository{
-		db: db,
-		log:  log,
+		db:  db,
+		log: log,
+		timeouts: timeouts{
+			healthCheck: viper.GetDuration(config.PostgresHealthCheckTimeout),
+			create:      viper.GetDuration(config.PostgresCreateTimeout),
+			get:         viper.GetDuration(config.PostgresGetTimeout),
+			list:        viper.GetDuration(config.PostgresListTimeout),
+			search:      viper.GetDuration(config.PostgresSearchTimeout),
+			update:      viper.GetDuration(config.PostgresUpdateTimeout),
+			delete:      viper.GetDuration(config.PostgresDeleteTimeout),
+			batch:       viper.GetDuration(config.PostgresBatchTimeout),
+			export:      viper.GetDuration(config.PostgresExportTimeout),
+			purge:       viper.GetDuration(config.PostgresPurgeTimeout),
+			outbox:      viper.GetDuration(config.PostgresOutboxTimeout),
+		},
+	}
+}
+
+func (repo *repository) HealthCheck(ctx context.Context) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.healthCheck)
+	defer cancel()
+
+	err := repo.db.Ping(ctx)
+	if err != nil {
+		repo.logger(ctx).Error("Postgres health check failed", zap.Error(err))
+		return dbError(ctx, err)
 	}
+	return nil
 }
 
 const createCmd = `
-	INSERT INTO persons (name, age, address, work) 
-	VALUES ($1, $2, $3, $4)
-	RETURNING id, name, age, address, work;`
+	INSERT INTO persons (name, age, address, work)
+	VALUES (@name, @age, @address, @work)
+	RETURNING id, name, age, address, work, version, deleted_at;`
 
 func (repo *repository) Create(ctx context.Context, params *pPersons.CreateParams) (*models.Person, error) {
-	row := repo.db.QueryRow(createCmd,
-		params.Name,
-		params.Age,
-		params.Address,
-		params.Work,
-	)
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.create)
+	defer cancel()
+
+	return repo.inTxPerson(ctx, func(tx pgx.Tx) (*models.Person, error) {
+		return repo.create(ctx, tx, params)
+	})
+}
+
+// create inserts the person and records the change, q must be a transaction for the two to be atomic.
+func (repo *repository) create(ctx context.Context, q querier, params *pPersons.CreateParams) (*models.Person, error) {
+	rows, err := q.Query(ctx, createCmd, pgx.NamedArgs{
+		"name":    params.Name,
+		"age":     params.Age,
+		"address": params.Address,
+		"work":    params.Work,
+	})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", createCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	person, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[models.Person])
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", createCmd))
+		return nil, dbError(ctx, err)
+	}
 
-	person := new(models.Person)
-	err := scanPerson(row, person)
+	err = repo.audit(ctx, q, audit.Created(ctx, person))
 	if err != nil {
-		repo.log.Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", createCmd))
-		return nil, errors.Wrap(pErrors.ErrDb, err.Error())
+		return nil, err
 	}
 
-	repo.log.Debug("New person created", zap.Any("person", person))
+	repo.logger(ctx).Debug("New person created", zap.Any("person", person))
 	return person, nil
 }
 
 const getCmd = `
-	SELECT id, name, age, address, work
+	SELECT id, name, age, address, work, version, deleted_at
 	FROM persons
-	WHERE id = $1;`
+	WHERE id = @id AND deleted_at IS NULL;`
 
 func (repo *repository) Get(ctx context.Context, id int64) (*models.Person, error) {
-	row := repo.db.QueryRow(getCmd, id)
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.get)
+	defer cancel()
+
+	return repo.get(ctx, repo.db, id)
+}
+
+func (repo *repository) get(ctx context.Context, q querier, id int64) (*models.Person, error) {
+	return repo.queryPerson(ctx, q, getCmd, id)
+}
+
+const (
+	lockCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = @id AND deleted_at IS NULL
+	FOR UPDATE;`
+
+	lockDeletedCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = @id
+	FOR UPDATE;`
+)
+
+// lock reads the person and keeps other transactions from changing it until the end of the transaction,
+// so that the audit trail gets what the person was right before the change. Deleted persons are locked too
+// if withDeleted is set.
+func (repo *repository) lock(ctx context.Context, tx pgx.Tx, id int64, withDeleted bool) (*models.Person, error) {
+	if withDeleted {
+		return repo.queryPerson(ctx, tx, lockDeletedCmd, id)
+	}
+	return repo.queryPerson(ctx, tx, lockCmd, id)
+}
+
+func (repo *repository) queryPerson(ctx context.Context, q querier, cmd string, id int64) (*models.Person, error) {
+	rows, err := q.Query(ctx, cmd, pgx.NamedArgs{"id": id})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", cmd), zap.Int64("id", id))
+		return nil, dbError(ctx, err)
+	}
 
-	person := new(models.Person)
-	err := scanPerson(row, person)
+	person, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[models.Person])
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, errors.Wrap(pErrors.ErrPersonNotFound, err.Error())
 		}
 
-		repo.log.Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", getCmd),
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", cmd),
 			zap.Int64("id", id))
-		return nil, errors.Wrap(pErrors.ErrDb, err.Error())
+		return nil, dbError(ctx, err)
 	}
 
 	return person, nil
 }
 
 const listCmd = `
-	SELECT id, name, age, address, work
-	FROM persons
-	offset $1`
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons`
+
+func (repo *repository) List(ctx context.Context, params *pPersons.ListParams) ([]models.Person, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.list)
+	defer cancel()
+
+	query, args, err := listQuery(params)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.db.Query(ctx, query, args)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", query))
+		return nil, dbError(ctx, err)
+	}
+
+	persons, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Person])
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", query))
+		return nil, dbError(ctx, err)
+	}
+
+	if params.Cursor != nil && params.Cursor.Backward {
+		slices.Reverse(persons)
+	}
+	return persons, nil
+}
+
+func (repo *repository) Export(ctx context.Context, params *pPersons.ListParams,
+	fn func(person *models.Person) error) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.export)
+	defer cancel()
 
-func (repo *repository) List(ctx context.Context, offset, limit int64) ([]models.Person, error) {
-	var err error
-	var rows *sql.Rows
-	query := listCmd
-	if limit != 0 {
-		query += " limit $2"
-		rows, err = repo.db.Query(listCmd, offset, limit)
-	} else {
-		rows, err = repo.db.Query(listCmd, offset)
+	query, args, err := listQuery(params)
+	if err != nil {
+		return err
 	}
+
+	rows, err := repo.db.Query(ctx, query, args)
 	if err != nil {
-		repo.log.Error(constants.DBError, zap.Error(err), zap.String("sql_query", listCmd))
-		return nil, errors.Wrap(pErrors.ErrDb, err.Error())
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", query))
+		return dbError(ctx, err)
 	}
 	defer rows.Close()
 
-	persons := []models.Person{}
-	var person models.Person
 	for rows.Next() {
-		err = rows.Scan(
-			&person.ID,
-			&person.Name,
-			&person.Age,
-			&person.Address,
-			&person.Work,
-		)
+		person, err := pgx.RowToStructByName[models.Person](rows)
 		if err != nil {
-			repo.log.Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", listCmd))
-			return nil, errors.Wrap(pErrors.ErrDb, err.Error())
+			repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", query))
+			return dbError(ctx, err)
+		}
+
+		if err = fn(&person); err != nil {
+			return err
 		}
+	}
+	if err = rows.Err(); err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", query))
+		return dbError(ctx, err)
+	}
+	return nil
+}
 
-		persons = append(persons, person)
+func listQuery(params *pPersons.ListParams) (string, pgx.NamedArgs, error) {
+	args := pgx.NamedArgs{}
+	clauses, err := criteria.ListClauses(params, func(value any) string {
+		name := fmt.Sprintf("p%d", len(args)+1)
+		args[name] = value
+		return "@" + name
+	})
+	if err != nil {
+		return "", nil, err
 	}
+	return listCmd + clauses, args, nil
+}
 
-	return persons, nil
+const searchCmd = `
+	SELECT id, name, age, address, work, version, deleted_at,
+	       ts_rank(search, query) AS rank,
+	       ts_headline('simple', concat_ws(' | ', name, address, work), query,
+	                   'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS snippet
+	FROM persons, to_tsquery('simple', @query) AS query
+	WHERE search @@ query AND deleted_at IS NULL
+	ORDER BY rank DESC, id
+	OFFSET @offset
+	LIMIT @limit;`
+
+func (repo *repository) Search(ctx context.Context, params *pPersons.SearchParams) ([]pPersons.SearchResult, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.search)
+	defer cancel()
+
+	tsQuery := criteria.TSQuery(params.Query)
+	if tsQuery == "" {
+		return []pPersons.SearchResult{}, nil
+	}
+
+	args := pgx.NamedArgs{
+		"query":  tsQuery,
+		"offset": params.Offset,
+		"limit":  nil,
+	}
+	if params.Limit != 0 {
+		args["limit"] = params.Limit
+	}
+
+	rows, err := repo.db.Query(ctx, searchCmd, args)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", searchCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	results, err := pgx.CollectRows(rows, pgx.RowToStructByName[pPersons.SearchResult])
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", searchCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	return results, nil
 }
 
-const fullUpdateCmd = `
+const partialUpdateCmd = `
 	UPDATE persons
-	SET %s
-	WHERE id = $%d
-	RETURNING id, name, age, address, work;`
+	SET %s, version = version + 1
+	WHERE %s AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
 
 func (repo *repository) PartialUpdate(ctx context.Context, params *pPersons.PartialUpdateParams) (*models.Person, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.update)
+	defer cancel()
+
+	return repo.inTxPerson(ctx, func(tx pgx.Tx) (*models.Person, error) {
+		return repo.partialUpdate(ctx, tx, params)
+	})
+}
+
+func (repo *repository) partialUpdate(ctx context.Context, tx pgx.Tx, params *pPersons.PartialUpdateParams) (
+	*models.Person, error) {
+	before, err := repo.lock(ctx, tx, params.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	if params.ExpectedVersion != 0 && before.Version != params.ExpectedVersion {
+		return nil, versionMismatch(params.ExpectedVersion, before.Version)
+	}
+
 	setValues := make([]string, 0, 4)
-	args := make([]any, 0, 5)
+	args := pgx.NamedArgs{"id": params.ID}
 	if params.Name != nil {
-		setValue := fmt.Sprintf("name = $%d", len(args)+1)
-		args = append(args, params.Name)
-		setValues = append(setValues, setValue)
+		setValues = append(setValues, "name = @name")
+		args["name"] = *params.Name
 	}
 	if params.Age != nil {
-		setValue := fmt.Sprintf("age = $%d", len(args)+1)
-		args = append(args, *params.Age)
-		setValues = append(setValues, setValue)
+		setValues = append(setValues, "age = @age")
+		args["age"] = *params.Age
 	}
 	if params.Address != nil {
-		setValue := fmt.Sprintf("address = $%d", len(args)+1)
-		args = append(args, *params.Address)
-		setValues = append(setValues, setValue)
+		setValues = append(setValues, "address = @address")
+		args["address"] = *params.Address
 	}
 	if params.Work != nil {
-		setValue := fmt.Sprintf("work = $%d", len(args)+1)
-		args = append(args, *params.Work)
-		setValues = append(setValues, setValue)
-	}
-	if len(setValues) > 0 {
-		setValuesPart := strings.Join(setValues, ", ")
-		cmd := fmt.Sprintf(fullUpdateCmd, setValuesPart, len(args)+1)
-		args = append(args, params.ID)
-
-		row := repo.db.QueryRow(cmd, args...)
-		person := new(models.Person)
-		err := scanPerson(row, person)
+		setValues = append(setValues, "work = @work")
+		args["work"] = *params.Work
+	}
+	if len(setValues) == 0 {
+		return before, nil
+	}
+
+	where := "id = @id"
+	if params.ExpectedVersion != 0 {
+		where += " AND version = @version"
+		args["version"] = params.ExpectedVersion
+	}
+	cmd := fmt.Sprintf(partialUpdateCmd, strings.Join(setValues, ", "), where)
+
+	rows, err := tx.Query(ctx, cmd, args)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", cmd))
+		return nil, dbError(ctx, err)
+	}
+
+	person, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[models.Person])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repo.noRowsError(ctx, tx, params.ID, params.ExpectedVersion, err)
+		}
+
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", cmd))
+		return nil, dbError(ctx, err)
+	}
+
+	if change, changed := audit.Updated(ctx, before, person); changed {
+		err = repo.audit(ctx, tx, change)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repo.logger(ctx).Debug("Person partial updated", zap.Any("person", person))
+	return person, nil
+}
+
+const (
+	replaceCmd = `
+	INSERT INTO persons (id, name, age, address, work)
+	VALUES (@id, @name, @age, @address, @work)
+	ON CONFLICT (id) DO UPDATE
+	SET name = excluded.name, age = excluded.age, address = excluded.address, work = excluded.work,
+	    version = persons.version + 1
+	WHERE persons.deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at, xmax = 0 AS created;`
+
+	// lastIssuedIDCmd returns the greatest id the id sequence handed out.
+	lastIssuedIDCmd = `
+	SELECT CASE WHEN is_called THEN last_value ELSE last_value - 1 END
+	FROM persons_id_seq;`
+)
+
+type replaced struct {
+	models.Person
+	Created bool `db:"created"`
+}
+
+func (repo *repository) Replace(ctx context.Context, params *pPersons.ReplaceParams) (*models.Person, bool, error) {
+	if params.ID <= 0 {
+		return nil, false, errors.Wrapf(pErrors.ErrPersonNotFound, "id %d is not positive", params.ID)
+	}
+	if params.ExpectedVersion != 0 {
+		person, err := repo.PartialUpdate(ctx, &pPersons.PartialUpdateParams{
+			ID:              params.ID,
+			Name:            &params.Name,
+			Age:             &params.Age,
+			Address:         &params.Address,
+			Work:            &params.Work,
+			ExpectedVersion: params.ExpectedVersion,
+		})
+		return person, false, err
+	}
+
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.update)
+	defer cancel()
+
+	var created bool
+	person, err := repo.inTxPerson(ctx, func(tx pgx.Tx) (*models.Person, error) {
+		var err error
+		var person *models.Person
+		person, created, err = repo.replace(ctx, tx, params)
+		return person, err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	repo.logger(ctx).Debug("Person replaced", zap.Any("person", person), zap.Bool("created", created))
+	return person, created, nil
+}
+
+func (repo *repository) replace(ctx context.Context, tx pgx.Tx, params *pPersons.ReplaceParams) (
+	*models.Person, bool, error) {
+	before, err := repo.lock(ctx, tx, params.ID, true)
+	if err != nil && !errors.Is(err, pErrors.ErrPersonNotFound) {
+		return nil, false, err
+	}
+	if before == nil {
+		var lastIssuedID int64
+		err = tx.QueryRow(ctx, lastIssuedIDCmd).Scan(&lastIssuedID)
 		if err != nil {
-			repo.log.Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", fullUpdateCmd))
-			return nil, errors.Wrap(pErrors.ErrDb, err.Error())
+			repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", lastIssuedIDCmd))
+			return nil, false, dbError(ctx, err)
+		}
+		// Only ids handed out by the sequence are created, so that a client can not use up the sequence
+		// with a huge id.
+		if params.ID > lastIssuedID {
+			return nil, false, errors.Wrapf(pErrors.ErrPersonNotFound, "id %d was never issued", params.ID)
+		}
+	}
+
+	rows, err := tx.Query(ctx, replaceCmd, pgx.NamedArgs{
+		"id":      params.ID,
+		"name":    params.Name,
+		"age":     params.Age,
+		"address": params.Address,
+		"work":    params.Work,
+	})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", replaceCmd))
+		return nil, false, dbError(ctx, err)
+	}
+
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[replaced])
+	if err != nil {
+		// A deleted person is only brought back by Restore.
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, repo.noRowsError(ctx, tx, params.ID, 0, err)
 		}
 
-		repo.log.Debug("Person partial updated", zap.Any("person", person))
-		return person, nil
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", replaceCmd))
+		return nil, false, dbError(ctx, err)
 	}
 
-	return nil, nil
+	err = repo.audit(ctx, tx, audit.Replaced(ctx, before, &result.Person)...)
+	if err != nil {
+		return nil, false, err
+	}
+	return &result.Person, result.Created, nil
 }
 
-const deleteCmd = `
-	DELETE FROM persons 
-	WHERE id = $1;`
+const (
+	deleteCmd = `
+	UPDATE persons
+	SET deleted_at = now(), version = version + 1
+	WHERE id = @id AND deleted_at IS NULL;`
+
+	deleteVersionCmd = `
+	UPDATE persons
+	SET deleted_at = now(), version = version + 1
+	WHERE id = @id AND version = @version AND deleted_at IS NULL;`
+)
+
+func (repo *repository) Delete(ctx context.Context, id int64, expectedVersion int64) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.delete)
+	defer cancel()
 
-func (repo *repository) Delete(ctx context.Context, id int64) error {
-	result, err := repo.db.Exec(deleteCmd, id)
+	return repo.inTx(ctx, func(tx pgx.Tx) error {
+		return repo.delete(ctx, tx, id, expectedVersion)
+	})
+}
+
+func (repo *repository) delete(ctx context.Context, tx pgx.Tx, id int64, expectedVersion int64) error {
+	before, err := repo.lock(ctx, tx, id, false)
 	if err != nil {
-		repo.log.Error(constants.DBError, zap.Error(err), zap.Int64("id", id))
-		return errors.Wrap(pErrors.ErrDb, err.Error())
+		return err
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return versionMismatch(expectedVersion, before.Version)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return pErrors.ErrPersonNotFound
+	cmd, args := deleteCmd, pgx.NamedArgs{"id": id}
+	if expectedVersion != 0 {
+		cmd, args["version"] = deleteVersionCmd, expectedVersion
 	}
 
-	repo.log.Debug("Person deleted", zap.Int64("id", id))
+	tag, err := tx.Exec(ctx, cmd, args)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.Int64("id", id))
+		return dbError(ctx, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repo.noRowsError(ctx, tx, id, expectedVersion, pgx.ErrNoRows)
+	}
+
+	err = repo.audit(ctx, tx, audit.Deleted(ctx, before))
+	if err != nil {
+		return err
+	}
+
+	repo.logger(ctx).Debug("Person deleted", zap.Int64("id", id))
 	return nil
 }
 
-func scanPerson(row pgx.Row, person *models.Person) error {
-	return row.Scan(
-		&person.ID,
-		&person.Name,
-		&person.Age,
-		&person.Address,
-		&person.Work,
-	)
+const restoreCmd = `
+	UPDATE persons
+	SET deleted_at = NULL, version = version + 1
+	WHERE id = @id AND deleted_at IS NOT NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+func (repo *repository) Restore(ctx context.Context, id int64) (*models.Person, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.update)
+	defer cancel()
+
+	return repo.inTxPerson(ctx, func(tx pgx.Tx) (*models.Person, error) {
+		return repo.restore(ctx, tx, id)
+	})
+}
+
+func (repo *repository) restore(ctx context.Context, tx pgx.Tx, id int64) (*models.Person, error) {
+	rows, err := tx.Query(ctx, restoreCmd, pgx.NamedArgs{"id": id})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", restoreCmd), zap.Int64("id", id))
+		return nil, dbError(ctx, err)
+	}
+
+	person, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[models.Person])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The person is either not deleted or does not exist at all.
+			return repo.get(ctx, tx, id)
+		}
+
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", restoreCmd),
+			zap.Int64("id", id))
+		return nil, dbError(ctx, err)
+	}
+
+	err = repo.audit(ctx, tx, audit.Restored(ctx, person))
+	if err != nil {
+		return nil, err
+	}
+
+	repo.logger(ctx).Debug("Person restored", zap.Int64("id", id))
+	return person, nil
+}
+
+// purgeCmd removes the oldest deletions first, so that repeated calls make progress however many rows expired.
+const purgeCmd = `
+	DELETE FROM persons
+	WHERE id IN (
+	    SELECT id
+	    FROM persons
+	    WHERE deleted_at < @deleted_before
+	    ORDER BY deleted_at
+	    LIMIT @limit
+	);`
+
+func (repo *repository) Purge(ctx context.Context, deletedBefore time.Time, limit int64) (int64, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.purge)
+	defer cancel()
+
+	tag, err := repo.db.Exec(ctx, purgeCmd, pgx.NamedArgs{
+		"deleted_before": deletedBefore,
+		"limit":          limit,
+	})
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", purgeCmd))
+		return 0, dbError(ctx, err)
+	}
+
+	repo.logger(ctx).Debug("Deleted persons purged", zap.Int64("count", tag.RowsAffected()))
+	return tag.RowsAffected(), nil
+}
+
+// noRowsError explains why a write conditioned on expectedVersion matched no rows:
+// either the person does not exist or it has another version.
+func (repo *repository) noRowsError(ctx context.Context, q querier, id int64, expectedVersion int64,
+	err error) error {
+	if expectedVersion == 0 {
+		return errors.Wrap(pErrors.ErrPersonNotFound, err.Error())
+	}
+
+	person, err := repo.get(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	return versionMismatch(expectedVersion, person.Version)
+}
+
+func versionMismatch(expected, actual int64) error {
+	return errors.Wrapf(pErrors.ErrVersionMismatch, "expected version %d, got %d", expected, actual)
+}
+
+// logger returns the logger of the request that ctx belongs to.
+func (repo *repository) logger(ctx context.Context) *zap.Logger {
+	return requestinfo.Logger(ctx, repo.log)
+}
+
+// dbError translates Postgres errors into repository errors, leaving the others to postgres.QueryError.
+// A query canceled by the server rather than by ctx is reported as canceled too.
+func dbError(ctx context.Context, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == uniqueViolation:
+			return errors.Wrap(pErrors.ErrPersonAlreadyExists, err.Error())
+		case pgErr.Code == queryCanceled && ctx.Err() == nil:
+			return errors.Wrap(pErrors.ErrRequestCanceled, err.Error())
+		}
+	}
+	return postgres.QueryError(ctx, err)
 }
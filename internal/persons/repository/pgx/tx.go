@@ -0,0 +1,53 @@
+package pgx
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+)
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise.
+func (repo *repository) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err))
+		return dbError(ctx, err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			repo.logger(ctx).Error("Failed to roll back transaction", zap.Error(err))
+		}
+	}()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err))
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+// inTxPerson runs fn in a transaction and returns the person it produced once the transaction is committed.
+func (repo *repository) inTxPerson(ctx context.Context, fn func(tx pgx.Tx) (*models.Person, error)) (
+	*models.Person, error) {
+	var person *models.Person
+	err := repo.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		person, err = fn(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return person, nil
+}
@@ -0,0 +1,249 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// sqlPool runs pgx repository queries through database/sql so that they can be checked by sqlmock.
+// Named arguments are rewritten by pgx itself, exactly as the pool would do it.
+type sqlPool struct {
+	db *sql.DB
+}
+
+func rewrite(ctx context.Context, query string, args []any) (string, []any, error) {
+	if len(args) > 0 {
+		if rewriter, ok := args[0].(pgx.QueryRewriter); ok {
+			return rewriter.RewriteQuery(ctx, nil, query, args[1:])
+		}
+	}
+	return query, args, nil
+}
+
+// sqlExecutor is implemented by both *sql.DB and *sql.Tx.
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// pgError turns the server errors that tests return for lib/pq into the ones pgx returns for them.
+func pgError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return &pgconn.PgError{Severity: pqErr.Severity, Code: string(pqErr.Code), Message: pqErr.Message}
+	}
+	return err
+}
+
+func exec(ctx context.Context, db sqlExecutor, query string, args []any) (pgconn.CommandTag, error) {
+	query, args, err := rewrite(ctx, query, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return pgconn.CommandTag{}, pgError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	verb := strings.ToUpper(strings.Fields(query)[0])
+	return pgconn.NewCommandTag(fmt.Sprintf("%s %d", verb, rowsAffected)), nil
+}
+
+func query(ctx context.Context, db sqlExecutor, query string, args []any) (pgx.Rows, error) {
+	query, args, err := rewrite(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return &sqlRows{rows: rows}, nil
+}
+
+func (p sqlPool) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	return exec(ctx, p.db, query, args)
+}
+
+func (p sqlPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return query(ctx, p.db, sql, args)
+}
+
+func (p sqlPool) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	rows, err := p.Query(ctx, query, args...)
+	return &sqlRow{rows: rows, err: err}
+}
+
+func (p sqlPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return sqlTx{tx: tx}, nil
+}
+
+func (p sqlPool) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+// sqlTx is a pgx.Tx on top of *sql.Tx. Only the methods used by the repository are supported.
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+func (t sqlTx) Begin(context.Context) (pgx.Tx, error) {
+	return nil, errors.New("nested transactions are not supported")
+}
+
+func (t sqlTx) Commit(context.Context) error {
+	return txError(t.tx.Commit())
+}
+
+func (t sqlTx) Rollback(context.Context) error {
+	return txError(t.tx.Rollback())
+}
+
+// txError translates database/sql errors of finished transactions into the error pgx reports for them.
+func txError(err error) error {
+	if errors.Is(err, sql.ErrTxDone) {
+		return pgx.ErrTxClosed
+	}
+	return err
+}
+
+func (t sqlTx) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, errors.New("copy is not supported")
+}
+
+func (t sqlTx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return nil
+}
+
+func (t sqlTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (t sqlTx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (t sqlTx) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	return exec(ctx, t.tx, query, args)
+}
+
+func (t sqlTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return query(ctx, t.tx, sql, args)
+}
+
+func (t sqlTx) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	rows, err := t.Query(ctx, query, args...)
+	return &sqlRow{rows: rows, err: err}
+}
+
+func (t sqlTx) Conn() *pgx.Conn {
+	return nil
+}
+
+type sqlRows struct {
+	rows *sql.Rows
+	err  error
+}
+
+func (r *sqlRows) Close() {
+	_ = r.rows.Close()
+}
+
+func (r *sqlRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.rows.Err()
+}
+
+func (r *sqlRows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag{}
+}
+
+func (r *sqlRows) FieldDescriptions() []pgconn.FieldDescription {
+	columns, err := r.rows.Columns()
+	if err != nil {
+		return nil
+	}
+
+	descriptions := make([]pgconn.FieldDescription, len(columns))
+	for i, column := range columns {
+		descriptions[i] = pgconn.FieldDescription{Name: column}
+	}
+	return descriptions
+}
+
+func (r *sqlRows) Next() bool {
+	return r.rows.Next()
+}
+
+func (r *sqlRows) Scan(dest ...any) error {
+	err := r.rows.Scan(dest...)
+	if err != nil {
+		r.err = err
+	}
+	return err
+}
+
+func (r *sqlRows) Values() ([]any, error) {
+	columns, err := r.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	return values, r.rows.Scan(dest...)
+}
+
+func (r *sqlRows) RawValues() [][]byte {
+	return nil
+}
+
+func (r *sqlRows) Conn() *pgx.Conn {
+	return nil
+}
+
+type sqlRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *sqlRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
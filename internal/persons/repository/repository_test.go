@@ -0,0 +1,2219 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pgxRepository "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/pgx"
+	stdRepository "github.com/SlavaShagalov/ds-lab1/internal/persons/repository/std"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	pkgErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+var err error
+var logger *zap.Logger
+
+func init() {
+	logger, err = zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// backends lists every persons.Repository implementation; each test case runs against all of them.
+var backends = map[string]func(db *sql.DB) pPersons.Repository{
+	"std": func(db *sql.DB) pPersons.Repository {
+		return stdRepository.New(db, logger)
+	},
+	"pgx": func(db *sql.DB) pPersons.Repository {
+		return pgxRepository.New(sqlPool{db: db}, logger)
+	},
+}
+
+func TestCreate(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		params  pPersons.CreateParams
+		Person  models.Person
+		err     error
+	}
+
+	const createCmd = `
+	INSERT INTO persons (name, age, address, work) 
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	tests := map[string]testCase{
+		"good query": {
+			prepare: func(f *fields) {
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(1, "Johnny", 22, "Moscow, Red Square", "Yandex", 1, nil)
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex").
+					WillReturnRows(rows)
+				expectChanges(f.mock, change{personID: 1, operation: "create", after: johnnyFields}).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			params: pPersons.CreateParams{
+				Name:    "Johnny",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "Yandex",
+			},
+			Person: models.Person{
+				ID:      1,
+				Name:    "Johnny",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "Yandex",
+				Version: 1,
+			},
+			err: nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex").
+					WillReturnError(pkgErrors.ErrDb)
+				f.mock.ExpectRollback()
+			},
+			params: pPersons.CreateParams{
+				Name:    "Johnny",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "Yandex",
+			},
+			Person: models.Person{},
+			err:    pkgErrors.ErrDb,
+		},
+		"unique violation": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex").
+					WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+				f.mock.ExpectRollback()
+			},
+			params: pPersons.CreateParams{
+				Name:    "Johnny",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "Yandex",
+			},
+			Person: models.Person{},
+			err:    pkgErrors.ErrPersonAlreadyExists,
+		},
+		"audit error": {
+			prepare: func(f *fields) {
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(1, "Johnny", 22, "Moscow, Red Square", "Yandex", 1, nil)
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex").
+					WillReturnRows(rows)
+				expectChanges(f.mock, change{personID: 1, operation: "create", after: johnnyFields}).
+					WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			params: pPersons.CreateParams{
+				Name:    "Johnny",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "Yandex",
+			},
+			Person: models.Person{},
+			err:    pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				Person, err := repo.Create(context.TODO(), &test.params)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if err == nil && *Person != test.Person {
+					t.Errorf("\nExpected: %v\nGot: %v", test.Person, Person)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		params  pPersons.ListParams
+		Persons []models.Person
+		err     error
+	}
+
+	const listCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE deleted_at IS NULL
+	ORDER BY id
+	OFFSET $1`
+
+	const listAfterCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE deleted_at IS NULL AND id > $1
+	ORDER BY id
+	LIMIT $2`
+
+	const listBeforeCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE deleted_at IS NULL AND id < $1
+	ORDER BY id DESC
+	LIMIT $2`
+
+	const listAllCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	ORDER BY id
+	OFFSET $1`
+
+	const listCriteriaCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE deleted_at IS NULL AND name ILIKE $1 AND age >= $2 AND work <> $3
+	ORDER BY age DESC, name, id
+	OFFSET $4
+	LIMIT $5`
+
+	expect := []models.Person{
+		{ID: 1, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1},
+		{ID: 2, Name: "Den", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1},
+		{ID: 3, Name: "Ken", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1},
+	}
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	newRows := func(persons []models.Person) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		for _, Person := range persons {
+			rows = rows.AddRow(Person.ID, Person.Name, Person.Age, Person.Address, Person.Work, Person.Version, nil)
+		}
+		return rows
+	}
+
+	tests := map[string]testCase{
+		"good query": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(listCmd)).
+					WithArgs(0).
+					WillReturnRows(newRows(expect))
+			},
+			Persons: expect,
+			err:     nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(listCmd)).
+					WithArgs(0).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			Persons: nil,
+			err:     pkgErrors.ErrDb,
+		},
+		"keyset forward": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(listAfterCmd)).
+					WithArgs(1, 2).
+					WillReturnRows(newRows(expect[1:]))
+			},
+			params:  pPersons.ListParams{Limit: 2, Cursor: &pPersons.Cursor{ID: 1}},
+			Persons: expect[1:],
+			err:     nil,
+		},
+		"keyset backward": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(listBeforeCmd)).
+					WithArgs(3, 2).
+					WillReturnRows(newRows([]models.Person{expect[1], expect[0]}))
+			},
+			params:  pPersons.ListParams{Limit: 2, Cursor: &pPersons.Cursor{ID: 3, Backward: true}},
+			Persons: expect[:2],
+			err:     nil,
+		},
+		"criteria": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(listCriteriaCmd)).
+					WithArgs(`%10\%\_o%`, 18, "VK", 5, 10).
+					WillReturnRows(newRows(expect))
+			},
+			params: pPersons.ListParams{
+				Offset: 5,
+				Limit:  10,
+				Criteria: pPersons.Criteria{
+					Conditions: []pPersons.Condition{
+						{Field: "name", Op: pPersons.OpContains, Value: "10%_o"},
+						{Field: "age", Op: pPersons.OpGe, Value: int64(18)},
+						{Field: "work", Op: pPersons.OpNe, Value: "VK"},
+					},
+					Sort: []pPersons.SortField{{Field: "age", Desc: true}, {Field: "name"}},
+				},
+			},
+			Persons: expect,
+			err:     nil,
+		},
+		"include deleted": {
+			prepare: func(f *fields) {
+				rows := newRows(expect[:1])
+				rows = rows.AddRow(4, "Ben", 40, "Kazan", "VK", 2, deletedAt)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(listAllCmd)).
+					WithArgs(0).
+					WillReturnRows(rows)
+			},
+			params: pPersons.ListParams{IncludeDeleted: true},
+			Persons: append(expect[:1:1], models.Person{
+				ID: 4, Name: "Ben", Age: 40, Address: "Kazan", Work: "VK", Version: 2, DeletedAt: &deletedAt,
+			}),
+			err: nil,
+		},
+		"unknown field": {
+			params: pPersons.ListParams{
+				Criteria: pPersons.Criteria{
+					Conditions: []pPersons.Condition{{Field: "1=1; --", Op: pPersons.OpEq, Value: "x"}},
+				},
+			},
+			Persons: nil,
+			err:     pkgErrors.ErrBadQueryParam,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				Persons, err := repo.List(context.TODO(), &test.params)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if !reflect.DeepEqual(Persons, test.Persons) {
+					t.Errorf("\nExpected: %v\nGot: %v", test.Persons, Persons)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		params  pPersons.ListParams
+		// stopAt makes the callback fail on the person with this index.
+		stopAt  int
+		Persons []models.Person
+		err     error
+	}
+
+	const exportCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE deleted_at IS NULL AND age >= $1
+	ORDER BY name, id
+	OFFSET $2`
+
+	expect := []models.Person{
+		{ID: 2, Name: "Den", Age: 30, Address: "Kazan", Work: "VK", Version: 1},
+		{ID: 1, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 2},
+	}
+	newRows := func() *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		for _, Person := range expect {
+			rows = rows.AddRow(Person.ID, Person.Name, Person.Age, Person.Address, Person.Work, Person.Version, nil)
+		}
+		return rows
+	}
+	params := pPersons.ListParams{
+		Criteria: pPersons.Criteria{
+			Conditions: []pPersons.Condition{{Field: "age", Op: pPersons.OpGe, Value: int64(18)}},
+			Sort:       []pPersons.SortField{{Field: "name"}},
+		},
+	}
+	errStop := errors.New("client went away")
+
+	tests := map[string]testCase{
+		"good query": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(exportCmd)).
+					WithArgs(18, 0).
+					WillReturnRows(newRows())
+			},
+			params:  params,
+			stopAt:  -1,
+			Persons: expect,
+			err:     nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(exportCmd)).
+					WithArgs(18, 0).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			params:  params,
+			stopAt:  -1,
+			Persons: nil,
+			err:     pkgErrors.ErrDb,
+		},
+		"callback error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(exportCmd)).
+					WithArgs(18, 0).
+					WillReturnRows(newRows())
+			},
+			params:  params,
+			stopAt:  1,
+			Persons: expect[:1],
+			err:     errStop,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				var Persons []models.Person
+				err = repo.Export(context.TODO(), &test.params, func(person *models.Person) error {
+					if len(Persons) == test.stopAt {
+						return errStop
+					}
+					Persons = append(Persons, *person)
+					return nil
+				})
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if !reflect.DeepEqual(Persons, test.Persons) {
+					t.Errorf("\nExpected: %v\nGot: %v", test.Persons, Persons)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		params  pPersons.SearchParams
+		Results []pPersons.SearchResult
+		err     error
+	}
+
+	const searchCmd = `
+	SELECT id, name, age, address, work, version, deleted_at,
+	       ts_rank(search, query) AS rank,
+	       ts_headline('simple', concat_ws(' | ', name, address, work), query,
+	                   'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS snippet
+	FROM persons, to_tsquery('simple', $1) AS query
+	WHERE search @@ query AND deleted_at IS NULL
+	ORDER BY rank DESC, id
+	OFFSET $2
+	LIMIT $3;`
+
+	expect := []pPersons.SearchResult{
+		{
+			Person:  models.Person{ID: 1, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1},
+			Rank:    0.6,
+			Snippet: "Johnny | <mark>Moscow</mark>, Red Square | <mark>Yandex</mark>",
+		},
+		{
+			Person:  models.Person{ID: 3, Name: "Ken", Age: 22, Address: "Moscow, Arbat", Work: "Yandex Go", Version: 1},
+			Rank:    0.4,
+			Snippet: "Ken | <mark>Moscow</mark>, Arbat | <mark>Yandex</mark> Go",
+		},
+	}
+	newRows := func(results []pPersons.SearchResult) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at", "rank", "snippet"})
+		for _, result := range results {
+			rows = rows.AddRow(result.ID, result.Name, result.Age, result.Address, result.Work,
+				result.Version, nil, result.Rank, result.Snippet)
+		}
+		return rows
+	}
+
+	tests := map[string]testCase{
+		"good query": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(searchCmd)).
+					WithArgs("yand:* & moscow:*", 0, 20).
+					WillReturnRows(newRows(expect))
+			},
+			params:  pPersons.SearchParams{Query: "Yand, Moscow!", Limit: 20},
+			Results: expect,
+			err:     nil,
+		},
+		"no matches": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(searchCmd)).
+					WithArgs("ozon:*", 5, 10).
+					WillReturnRows(newRows(nil))
+			},
+			params:  pPersons.SearchParams{Query: "ozon", Offset: 5, Limit: 10},
+			Results: []pPersons.SearchResult{},
+			err:     nil,
+		},
+		"no words": {
+			params:  pPersons.SearchParams{Query: " & | ! "},
+			Results: []pPersons.SearchResult{},
+			err:     nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(searchCmd)).
+					WithArgs("yandex:*", 0, 20).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			params:  pPersons.SearchParams{Query: "yandex", Limit: 20},
+			Results: nil,
+			err:     pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				Results, err := repo.Search(context.TODO(), &test.params)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if !reflect.DeepEqual(Results, test.Results) {
+					t.Errorf("\nExpected: %v\nGot: %v", test.Results, Results)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		id      int64
+		Person  models.Person
+		err     error
+	}
+
+	const getCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL;`
+
+	tests := map[string]testCase{
+		"good query": {
+			prepare: func(f *fields) {
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(1, "Johnny", 22, "Moscow, Red Square", "Yandex", 1, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(getCmd)).
+					WithArgs(3).
+					WillReturnRows(rows)
+			},
+			id: 3,
+			Person: models.Person{
+				ID:      1,
+				Name:    "Johnny",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "Yandex",
+				Version: 1,
+			},
+			err: nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(getCmd)).
+					WithArgs(3).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			id:     3,
+			Person: models.Person{},
+			err:    pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				Person, err := repo.Get(context.TODO(), test.id)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if err == nil && *Person != test.Person {
+					t.Errorf("\nExpected: %v\nGot: %v", test.Person, Person)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestPartialUpdate(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		params  pPersons.PartialUpdateParams
+		Person  models.Person
+		err     error
+	}
+
+	const lockCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE;`
+
+	const partialUpdateCmd = `
+	UPDATE persons
+	SET name = $1, work = $2, version = version + 1
+	WHERE id = $3 AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	const partialUpdateVersionCmd = `
+	UPDATE persons
+	SET name = $1, work = $2, version = version + 1
+	WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	name, oldName := "Den", "Johnny"
+	work, oldWork := "VK", "Yandex"
+
+	expectLock := func(f *fields, version int64) {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		rows = rows.AddRow(3, "Johnny", 22, "Moscow, Red Square", "Yandex", version, nil)
+		f.mock.
+			ExpectQuery(regexp.QuoteMeta(lockCmd)).
+			WithArgs(3).
+			WillReturnRows(rows)
+	}
+	update := change{
+		personID:  3,
+		operation: "update",
+		before:    `{"name":"Johnny","work":"Yandex"}`,
+		after:     `{"name":"Den","work":"VK"}`,
+	}
+
+	tests := map[string]testCase{
+		"good query": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 1)
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(3, "Den", 22, "Moscow, Red Square", "VK", 2, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(partialUpdateCmd)).
+					WithArgs("Den", "VK", 3).
+					WillReturnRows(rows)
+				expectChanges(f.mock, update).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			params: pPersons.PartialUpdateParams{ID: 3, Name: &name, Work: &work},
+			Person: models.Person{
+				ID:      3,
+				Name:    "Den",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "VK",
+				Version: 2,
+			},
+			err: nil,
+		},
+		"same values": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 1)
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(3, "Johnny", 22, "Moscow, Red Square", "Yandex", 2, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(partialUpdateCmd)).
+					WithArgs("Johnny", "Yandex", 3).
+					WillReturnRows(rows)
+				f.mock.ExpectCommit()
+			},
+			params: pPersons.PartialUpdateParams{ID: 3, Name: &oldName, Work: &oldWork},
+			Person: models.Person{
+				ID:      3,
+				Name:    "Johnny",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "Yandex",
+				Version: 2,
+			},
+			err: nil,
+		},
+		"nothing to update": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 1)
+				f.mock.ExpectCommit()
+			},
+			params: pPersons.PartialUpdateParams{ID: 3},
+			Person: models.Person{
+				ID:      3,
+				Name:    "Johnny",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "Yandex",
+				Version: 1,
+			},
+			err: nil,
+		},
+		"person not found": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lockCmd)).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"}))
+				f.mock.ExpectRollback()
+			},
+			params: pPersons.PartialUpdateParams{ID: 3, Name: &name, Work: &work},
+			err:    pkgErrors.ErrPersonNotFound,
+		},
+		"expected version": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 4)
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(3, "Den", 22, "Moscow, Red Square", "VK", 5, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(partialUpdateVersionCmd)).
+					WithArgs("Den", "VK", 3, 4).
+					WillReturnRows(rows)
+				expectChanges(f.mock, update).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			params: pPersons.PartialUpdateParams{ID: 3, Name: &name, Work: &work, ExpectedVersion: 4},
+			Person: models.Person{
+				ID:      3,
+				Name:    "Den",
+				Age:     22,
+				Address: "Moscow, Red Square",
+				Work:    "VK",
+				Version: 5,
+			},
+			err: nil,
+		},
+		"version mismatch": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 6)
+				f.mock.ExpectRollback()
+			},
+			params: pPersons.PartialUpdateParams{ID: 3, Name: &name, Work: &work, ExpectedVersion: 4},
+			err:    pkgErrors.ErrVersionMismatch,
+		},
+		"version mismatch without changes": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 6)
+				f.mock.ExpectRollback()
+			},
+			params: pPersons.PartialUpdateParams{ID: 3, ExpectedVersion: 4},
+			err:    pkgErrors.ErrVersionMismatch,
+		},
+		"audit error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 1)
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(3, "Den", 22, "Moscow, Red Square", "VK", 2, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(partialUpdateCmd)).
+					WithArgs("Den", "VK", 3).
+					WillReturnRows(rows)
+				expectChanges(f.mock, update).WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			params: pPersons.PartialUpdateParams{ID: 3, Name: &name, Work: &work},
+			err:    pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				Person, err := repo.PartialUpdate(context.TODO(), &test.params)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if err == nil && *Person != test.Person {
+					t.Errorf("\nExpected: %v\nGot: %v", test.Person, Person)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		params  pPersons.ReplaceParams
+		Person  models.Person
+		created bool
+		err     error
+	}
+
+	const replaceCmd = `
+	INSERT INTO persons (id, name, age, address, work)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (id) DO UPDATE
+	SET name = excluded.name, age = excluded.age, address = excluded.address, work = excluded.work,
+	    version = persons.version + 1
+	WHERE persons.deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at, xmax = 0 AS created;`
+
+	const lastIssuedIDCmd = `
+	SELECT CASE WHEN is_called THEN last_value ELSE last_value - 1 END
+	FROM persons_id_seq;`
+
+	const updateVersionCmd = `
+	UPDATE persons
+	SET name = $1, age = $2, address = $3, work = $4, version = version + 1
+	WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	const lockCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE;`
+
+	const lockDeletedCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1
+	FOR UPDATE;`
+
+	params := pPersons.ReplaceParams{
+		ID:      7,
+		Name:    "Den",
+		Age:     30,
+		Address: "Kazan",
+		Work:    "VK",
+	}
+	newRows := func(version int64, created bool) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at", "created"})
+		return rows.AddRow(7, "Den", 30, "Kazan", "VK", version, nil, created)
+	}
+	replaced := func(version int64) models.Person {
+		return models.Person{ID: 7, Name: "Den", Age: 30, Address: "Kazan", Work: "VK", Version: version}
+	}
+	oldRows := func(version int64, deletedAt *time.Time) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		return rows.AddRow(7, "Johnny", 22, "Moscow, Red Square", "Yandex", version, deletedAt)
+	}
+	update := change{personID: 7, operation: "update", before: johnnyFields, after: denFields}
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]testCase{
+		"replace existing": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lockDeletedCmd)).
+					WithArgs(7).
+					WillReturnRows(oldRows(2, nil))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(replaceCmd)).
+					WithArgs(7, "Den", 30, "Kazan", "VK").
+					WillReturnRows(newRows(3, false))
+				expectChanges(f.mock, update).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			params:  params,
+			Person:  replaced(3),
+			created: false,
+			err:     nil,
+		},
+		"replace deleted": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lockDeletedCmd)).
+					WithArgs(7).
+					WillReturnRows(oldRows(2, &deletedAt))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(replaceCmd)).
+					WithArgs(7, "Den", 30, "Kazan", "VK").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at", "created"}))
+				f.mock.ExpectRollback()
+			},
+			params: params,
+			err:    pkgErrors.ErrPersonNotFound,
+		},
+		"create missing": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lockDeletedCmd)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"}))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lastIssuedIDCmd)).
+					WillReturnRows(sqlmock.NewRows([]string{"last_value"}).AddRow(10))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(replaceCmd)).
+					WithArgs(7, "Den", 30, "Kazan", "VK").
+					WillReturnRows(newRows(1, true))
+				expectChanges(f.mock, change{personID: 7, operation: "create", after: denFields}).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			params:  params,
+			Person:  replaced(1),
+			created: true,
+			err:     nil,
+		},
+		"expected version": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lockCmd)).
+					WithArgs(7).
+					WillReturnRows(oldRows(4, nil))
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(7, "Den", 30, "Kazan", "VK", 5, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(updateVersionCmd)).
+					WithArgs("Den", 30, "Kazan", "VK", 7, 4).
+					WillReturnRows(rows)
+				expectChanges(f.mock, update).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			params: pPersons.ReplaceParams{
+				ID:              7,
+				Name:            "Den",
+				Age:             30,
+				Address:         "Kazan",
+				Work:            "VK",
+				ExpectedVersion: 4,
+			},
+			Person:  replaced(5),
+			created: false,
+			err:     nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lockDeletedCmd)).
+					WithArgs(7).
+					WillReturnRows(oldRows(2, nil))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(replaceCmd)).
+					WithArgs(7, "Den", 30, "Kazan", "VK").
+					WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			params: params,
+			err:    pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				Person, created, err := repo.Replace(context.TODO(), &test.params)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if err == nil && (*Person != test.Person || created != test.created) {
+					t.Errorf("\nExpected: %v, created %t\nGot: %v, created %t", test.Person, test.created, Person, created)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+// TestReplaceIssuedIDs puts persons around the greatest id handed out by the id sequence, only ids up to it
+// are created, so that a client can not use up the sequence.
+func TestReplaceIssuedIDs(t *testing.T) {
+	const replaceCmd = `
+	INSERT INTO persons (id, name, age, address, work)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (id) DO UPDATE
+	SET name = excluded.name, age = excluded.age, address = excluded.address, work = excluded.work,
+	    version = persons.version + 1
+	WHERE persons.deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at, xmax = 0 AS created;`
+
+	const lastIssuedIDCmd = `
+	SELECT CASE WHEN is_called THEN last_value ELSE last_value - 1 END
+	FROM persons_id_seq;`
+
+	const lockDeletedCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1
+	FOR UPDATE;`
+
+	// The sequence handed out ids up to 10.
+	expectMissing := func(mock sqlmock.Sqlmock, id int64) {
+		mock.ExpectBegin()
+		mock.
+			ExpectQuery(regexp.QuoteMeta(lockDeletedCmd)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"}))
+		mock.
+			ExpectQuery(regexp.QuoteMeta(lastIssuedIDCmd)).
+			WillReturnRows(sqlmock.NewRows([]string{"last_value"}).AddRow(10))
+	}
+
+	tests := map[string]struct {
+		id      int64
+		prepare func(mock sqlmock.Sqlmock)
+		err     error
+	}{
+		"last issued": {
+			id: 10,
+			prepare: func(mock sqlmock.Sqlmock) {
+				expectMissing(mock, 10)
+				mock.
+					ExpectQuery(regexp.QuoteMeta(replaceCmd)).
+					WithArgs(10, "Den", 30, "Kazan", "VK").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at", "created"}).
+						AddRow(10, "Den", 30, "Kazan", "VK", 1, nil, true))
+				expectChanges(mock, change{personID: 10, operation: "create", after: denFields}).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			err: nil,
+		},
+		"never issued": {
+			id: 11,
+			prepare: func(mock sqlmock.Sqlmock) {
+				expectMissing(mock, 11)
+				mock.ExpectRollback()
+			},
+			err: pkgErrors.ErrPersonNotFound,
+		},
+		"max id": {
+			id: math.MaxInt64,
+			prepare: func(mock sqlmock.Sqlmock) {
+				expectMissing(mock, math.MaxInt64)
+				mock.ExpectRollback()
+			},
+			err: pkgErrors.ErrPersonNotFound,
+		},
+		"zero id": {
+			id:  0,
+			err: pkgErrors.ErrPersonNotFound,
+		},
+		"negative id": {
+			id:  -1,
+			err: pkgErrors.ErrPersonNotFound,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+				if test.prepare != nil {
+					test.prepare(mock)
+				}
+
+				_, created, err := repo.Replace(context.TODO(), &pPersons.ReplaceParams{
+					ID:      test.id,
+					Name:    "Den",
+					Age:     30,
+					Address: "Kazan",
+					Work:    "VK",
+				})
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if err == nil && !created {
+					t.Errorf("\nExpected: created\nGot: replaced")
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		id      int64
+		version int64
+		err     error
+	}
+
+	const deleteCmd = `
+	UPDATE persons
+	SET deleted_at = now(), version = version + 1
+	WHERE id = $1 AND deleted_at IS NULL;`
+
+	const deleteVersionCmd = `
+	UPDATE persons
+	SET deleted_at = now(), version = version + 1
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL;`
+
+	const lockCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE;`
+
+	expectLock := func(f *fields, version int64) {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		rows = rows.AddRow(3, "Johnny", 22, "Moscow, Red Square", "Yandex", version, nil)
+		f.mock.
+			ExpectQuery(regexp.QuoteMeta(lockCmd)).
+			WithArgs(3).
+			WillReturnRows(rows)
+	}
+	deleted := change{personID: 3, operation: "delete", before: johnnyFields}
+
+	tests := map[string]testCase{
+		"good query": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 4)
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(deleteCmd)).
+					WithArgs(3).
+					WillReturnResult(sqlmock.NewResult(3, 1))
+				expectChanges(f.mock, deleted).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			id:  3,
+			err: nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 4)
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(deleteCmd)).
+					WithArgs(3).
+					WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			id:  3,
+			err: pkgErrors.ErrDb,
+		},
+		"person not found": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lockCmd)).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"}))
+				f.mock.ExpectRollback()
+			},
+			id:  3,
+			err: pkgErrors.ErrPersonNotFound,
+		},
+		"expected version": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 4)
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(deleteVersionCmd)).
+					WithArgs(3, 4).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectChanges(f.mock, deleted).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			id:      3,
+			version: 4,
+			err:     nil,
+		},
+		"version mismatch": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 6)
+				f.mock.ExpectRollback()
+			},
+			id:      3,
+			version: 4,
+			err:     pkgErrors.ErrVersionMismatch,
+		},
+		"audit error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 4)
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(deleteCmd)).
+					WithArgs(3).
+					WillReturnResult(sqlmock.NewResult(3, 1))
+				expectChanges(f.mock, deleted).WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			id:  3,
+			err: pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				err = repo.Delete(context.TODO(), test.id, test.version)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func checkBatchResults(t *testing.T, expected, got []pPersons.BatchResult) {
+	t.Helper()
+
+	if len(got) != len(expected) {
+		t.Fatalf("\nExpected: %d results\nGot: %d results", len(expected), len(got))
+	}
+	for i := range expected {
+		if !errors.Is(got[i].Err, expected[i].Err) {
+			t.Errorf("\nItem %d\nExpected: %s\nGot: %s", i, expected[i].Err, got[i].Err)
+		}
+		if !reflect.DeepEqual(got[i].Person, expected[i].Person) {
+			t.Errorf("\nItem %d\nExpected: %v\nGot: %v", i, expected[i].Person, got[i].Person)
+		}
+	}
+}
+
+func TestCreateBatch(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		mode    pPersons.BatchMode
+		results []pPersons.BatchResult
+		err     error
+	}
+
+	const createBatchCmd = `
+	INSERT INTO persons (name, age, address, work)
+	VALUES ($1, $2, $3, $4), ($5, $6, $7, $8)
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	const createCmd = `
+	INSERT INTO persons (name, age, address, work) 
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	params := []pPersons.CreateParams{
+		{Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex"},
+		{Name: "Den", Age: 30, Address: "Kazan", Work: "VK"},
+	}
+	johnny := models.Person{ID: 1, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 1}
+	den := models.Person{ID: 2, Name: "Den", Age: 30, Address: "Kazan", Work: "VK", Version: 1}
+	createdJohnny := change{personID: 1, operation: "create", after: johnnyFields}
+	createdDen := change{personID: 2, operation: "create", after: denFields}
+
+	tests := map[string]testCase{
+		"atomic": {
+			prepare: func(f *fields) {
+				// RETURNING order is not guaranteed, results are matched to items by id.
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(2, "Den", 30, "Kazan", "VK", 1, nil)
+				rows = rows.AddRow(1, "Johnny", 22, "Moscow, Red Square", "Yandex", 1, nil)
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createBatchCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex", "Den", 30, "Kazan", "VK").
+					WillReturnRows(rows)
+				expectChanges(f.mock, createdJohnny, createdDen).WillReturnResult(sqlmock.NewResult(0, 2))
+				f.mock.ExpectCommit()
+			},
+			mode:    pPersons.BatchAtomic,
+			results: []pPersons.BatchResult{{Person: &johnny}, {Person: &den}},
+			err:     nil,
+		},
+		"atomic query error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createBatchCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex", "Den", 30, "Kazan", "VK").
+					WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			mode:    pPersons.BatchAtomic,
+			results: []pPersons.BatchResult{{Err: pkgErrors.ErrDb}, {Err: pkgErrors.ErrDb}},
+			err:     nil,
+		},
+		"atomic begin error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin().WillReturnError(fmt.Errorf("db error"))
+			},
+			mode: pPersons.BatchAtomic,
+			err:  pkgErrors.ErrDb,
+		},
+		"best effort": {
+			prepare: func(f *fields) {
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(1, "Johnny", 22, "Moscow, Red Square", "Yandex", 1, nil)
+				rows = rows.AddRow(2, "Den", 30, "Kazan", "VK", 1, nil)
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createBatchCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex", "Den", 30, "Kazan", "VK").
+					WillReturnRows(rows)
+				expectChanges(f.mock, createdJohnny, createdDen).WillReturnResult(sqlmock.NewResult(0, 2))
+				f.mock.ExpectCommit()
+			},
+			mode:    pPersons.BatchBestEffort,
+			results: []pPersons.BatchResult{{Person: &johnny}, {Person: &den}},
+			err:     nil,
+		},
+		"best effort falls back to single rows": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createBatchCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex", "Den", 30, "Kazan", "VK").
+					WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(1, "Johnny", 22, "Moscow, Red Square", "Yandex", 1, nil)
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("Johnny", 22, "Moscow, Red Square", "Yandex").
+					WillReturnRows(rows)
+				expectChanges(f.mock, createdJohnny).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("Den", 30, "Kazan", "VK").
+					WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			mode:    pPersons.BatchBestEffort,
+			results: []pPersons.BatchResult{{Person: &johnny}, {Err: pkgErrors.ErrDb}},
+			err:     nil,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				results, err := repo.CreateBatch(context.TODO(), params, test.mode)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				checkBatchResults(t, test.results, results)
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestPartialUpdateBatch(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		mode    pPersons.BatchMode
+		results []pPersons.BatchResult
+		err     error
+	}
+
+	const partialUpdateCmd = `
+	UPDATE persons
+	SET name = $1, version = version + 1
+	WHERE id = $2 AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	const partialUpdateVersionCmd = `
+	UPDATE persons
+	SET work = $1, version = version + 1
+	WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	const lockCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE;`
+
+	name := "Den"
+	work := "VK"
+	params := []pPersons.PartialUpdateParams{
+		{ID: 3, Name: &name},
+		{ID: 4, Work: &work, ExpectedVersion: 2},
+	}
+	den := models.Person{ID: 3, Name: "Den", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 2}
+	johnny := models.Person{ID: 4, Name: "Johnny", Age: 30, Address: "Kazan", Work: "VK", Version: 3}
+
+	// expectLock expects the person to be read before the update, with the name and work the batch changes.
+	expectLock := func(f *fields, id int64, version int64) {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		if id == 3 {
+			rows = rows.AddRow(3, "Johnny", 22, "Moscow, Red Square", "Yandex", version, nil)
+		} else {
+			rows = rows.AddRow(4, "Johnny", 30, "Kazan", "Yandex", version, nil)
+		}
+		f.mock.
+			ExpectQuery(regexp.QuoteMeta(lockCmd)).
+			WithArgs(id).
+			WillReturnRows(rows)
+	}
+	renamed := change{personID: 3, operation: "update", before: `{"name":"Johnny"}`, after: `{"name":"Den"}`}
+	moved := change{personID: 4, operation: "update", before: `{"work":"Yandex"}`, after: `{"work":"VK"}`}
+
+	tests := map[string]testCase{
+		"atomic": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 3, 1)
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(3, "Den", 22, "Moscow, Red Square", "Yandex", 2, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(partialUpdateCmd)).
+					WithArgs("Den", 3).
+					WillReturnRows(rows)
+				expectChanges(f.mock, renamed).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectLock(f, 4, 2)
+				rows = sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(4, "Johnny", 30, "Kazan", "VK", 3, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(partialUpdateVersionCmd)).
+					WithArgs("VK", 4, 2).
+					WillReturnRows(rows)
+				expectChanges(f.mock, moved).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			mode:    pPersons.BatchAtomic,
+			results: []pPersons.BatchResult{{Person: &den}, {Person: &johnny}},
+			err:     nil,
+		},
+		"atomic version mismatch": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				expectLock(f, 3, 1)
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(3, "Den", 22, "Moscow, Red Square", "Yandex", 2, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(partialUpdateCmd)).
+					WithArgs("Den", 3).
+					WillReturnRows(rows)
+				expectChanges(f.mock, renamed).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectLock(f, 4, 5)
+				f.mock.ExpectRollback()
+			},
+			mode:    pPersons.BatchAtomic,
+			results: []pPersons.BatchResult{{Err: pkgErrors.ErrBatchAborted}, {Err: pkgErrors.ErrVersionMismatch}},
+			err:     nil,
+		},
+		"best effort": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(lockCmd)).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"}))
+				f.mock.ExpectRollback()
+				f.mock.ExpectBegin()
+				expectLock(f, 4, 2)
+				rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+				rows = rows.AddRow(4, "Johnny", 30, "Kazan", "VK", 3, nil)
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(partialUpdateVersionCmd)).
+					WithArgs("VK", 4, 2).
+					WillReturnRows(rows)
+				expectChanges(f.mock, moved).WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			mode:    pPersons.BatchBestEffort,
+			results: []pPersons.BatchResult{{Err: pkgErrors.ErrPersonNotFound}, {Person: &johnny}},
+			err:     nil,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				results, err := repo.PartialUpdateBatch(context.TODO(), params, test.mode)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				checkBatchResults(t, test.results, results)
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteBatch(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		mode    pPersons.BatchMode
+		results []pPersons.BatchResult
+		err     error
+	}
+
+	const deleteBatchCmd = `
+	UPDATE persons
+	SET deleted_at = now(), version = version + 1
+	WHERE id IN ($1, $2, $3) AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	personIDs := []int64{3, 4, 5}
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	deletedRows := func(ids ...int64) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		for _, id := range ids {
+			rows = rows.AddRow(id, "Johnny", 22, "Moscow, Red Square", "Yandex", 2, deletedAt)
+		}
+		return rows
+	}
+	deleted := func(id int64) change {
+		return change{personID: id, operation: "delete", before: johnnyFields}
+	}
+
+	tests := map[string]testCase{
+		"atomic": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(deleteBatchCmd)).
+					WithArgs(3, 4, 5).
+					WillReturnRows(deletedRows(5, 3, 4))
+				expectChanges(f.mock, deleted(5), deleted(3), deleted(4)).WillReturnResult(sqlmock.NewResult(0, 3))
+				f.mock.ExpectCommit()
+			},
+			mode:    pPersons.BatchAtomic,
+			results: []pPersons.BatchResult{{}, {}, {}},
+			err:     nil,
+		},
+		"atomic person not found": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(deleteBatchCmd)).
+					WithArgs(3, 4, 5).
+					WillReturnRows(deletedRows(3, 5))
+				expectChanges(f.mock, deleted(3), deleted(5)).WillReturnResult(sqlmock.NewResult(0, 2))
+				f.mock.ExpectRollback()
+			},
+			mode: pPersons.BatchAtomic,
+			results: []pPersons.BatchResult{
+				{Err: pkgErrors.ErrBatchAborted},
+				{Err: pkgErrors.ErrPersonNotFound},
+				{Err: pkgErrors.ErrBatchAborted},
+			},
+			err: nil,
+		},
+		"atomic commit error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(deleteBatchCmd)).
+					WithArgs(3, 4, 5).
+					WillReturnRows(deletedRows(3, 4, 5))
+				expectChanges(f.mock, deleted(3), deleted(4), deleted(5)).WillReturnResult(sqlmock.NewResult(0, 3))
+				f.mock.ExpectCommit().WillReturnError(fmt.Errorf("db error"))
+			},
+			mode: pPersons.BatchAtomic,
+			err:  pkgErrors.ErrDb,
+		},
+		"best effort person not found": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(deleteBatchCmd)).
+					WithArgs(3, 4, 5).
+					WillReturnRows(deletedRows(3, 5))
+				expectChanges(f.mock, deleted(3), deleted(5)).WillReturnResult(sqlmock.NewResult(0, 2))
+				f.mock.ExpectCommit()
+			},
+			mode:    pPersons.BatchBestEffort,
+			results: []pPersons.BatchResult{{}, {Err: pkgErrors.ErrPersonNotFound}, {}},
+			err:     nil,
+		},
+		"best effort query error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(deleteBatchCmd)).
+					WithArgs(3, 4, 5).
+					WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			mode:    pPersons.BatchBestEffort,
+			results: []pPersons.BatchResult{{Err: pkgErrors.ErrDb}, {Err: pkgErrors.ErrDb}, {Err: pkgErrors.ErrDb}},
+			err:     nil,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				results, err := repo.DeleteBatch(context.TODO(), personIDs, test.mode)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				checkBatchResults(t, test.results, results)
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		id      int64
+		Person  *models.Person
+		err     error
+	}
+
+	const restoreCmd = `
+	UPDATE persons
+	SET deleted_at = NULL, version = version + 1
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+	const getCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL;`
+
+	person := &models.Person{ID: 3, Name: "Johnny", Age: 22, Address: "Moscow, Red Square", Work: "Yandex", Version: 5}
+	newRows := func() *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		return rows.AddRow(3, "Johnny", 22, "Moscow, Red Square", "Yandex", 5, nil)
+	}
+
+	tests := map[string]testCase{
+		"deleted person": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(restoreCmd)).
+					WithArgs(3).
+					WillReturnRows(newRows())
+				expectChanges(f.mock, change{personID: 3, operation: "restore", after: johnnyFields}).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+			},
+			id:     3,
+			Person: person,
+			err:    nil,
+		},
+		"person is not deleted": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(restoreCmd)).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"}))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(getCmd)).
+					WithArgs(3).
+					WillReturnRows(newRows())
+				f.mock.ExpectCommit()
+			},
+			id:     3,
+			Person: person,
+			err:    nil,
+		},
+		"person not found": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(restoreCmd)).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"}))
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(getCmd)).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"}))
+				f.mock.ExpectRollback()
+			},
+			id:     3,
+			Person: nil,
+			err:    pkgErrors.ErrPersonNotFound,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.ExpectBegin()
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(restoreCmd)).
+					WithArgs(3).
+					WillReturnError(fmt.Errorf("db error"))
+				f.mock.ExpectRollback()
+			},
+			id:     3,
+			Person: nil,
+			err:    pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				Person, err := repo.Restore(context.TODO(), test.id)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if !reflect.DeepEqual(Person, test.Person) {
+					t.Errorf("\nExpected: %v\nGot: %v", test.Person, Person)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestPurge(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		purged  int64
+		err     error
+	}
+
+	const purgeCmd = `
+	DELETE FROM persons
+	WHERE id IN (
+	    SELECT id
+	    FROM persons
+	    WHERE deleted_at < $1
+	    ORDER BY deleted_at
+	    LIMIT $2
+	);`
+
+	deletedBefore := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]testCase{
+		"good query": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(purgeCmd)).
+					WithArgs(deletedBefore, 100).
+					WillReturnResult(sqlmock.NewResult(0, 42))
+			},
+			purged: 42,
+			err:    nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(purgeCmd)).
+					WithArgs(deletedBefore, 100).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			purged: 0,
+			err:    pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				purged, err := repo.Purge(context.TODO(), deletedBefore, 100)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if purged != test.purged {
+					t.Errorf("\nExpected: %d\nGot: %d", test.purged, purged)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		err     error
+	}
+
+	tests := map[string]testCase{
+		"postgres reachable": {
+			prepare: func(f *fields) {
+				f.mock.ExpectPing()
+			},
+			err: nil,
+		},
+		"postgres unreachable": {
+			prepare: func(f *fields) {
+				f.mock.ExpectPing().WillReturnError(fmt.Errorf("connection refused"))
+			},
+			err: pkgErrors.ErrDb,
+		},
+	}
+
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			test := test
+			newRepo := newRepo
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				err = repo.HealthCheck(context.TODO())
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare    func(f *fields)
+		ctx        func() (context.Context, context.CancelFunc)
+		getTimeout time.Duration
+		err        error
+	}
+
+	const getCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL;`
+
+	slowQuery := func(f *fields) {
+		rows := sqlmock.NewRows([]string{"id", "name", "age", "address", "work", "version", "deleted_at"})
+		rows = rows.AddRow(1, "Johnny", 22, "Moscow, Red Square", "Yandex", 1, nil)
+		f.mock.
+			ExpectQuery(regexp.QuoteMeta(getCmd)).
+			WithArgs(3).
+			WillDelayFor(time.Second).
+			WillReturnRows(rows)
+	}
+
+	tests := map[string]testCase{
+		"request deadline exceeded": {
+			prepare: slowQuery,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			err: pkgErrors.ErrDeadlineExceeded,
+		},
+		"operation timeout exceeded": {
+			prepare: slowQuery,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			getTimeout: 20 * time.Millisecond,
+			err:        pkgErrors.ErrDeadlineExceeded,
+		},
+		"client disconnected": {
+			prepare: slowQuery,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			err: pkgErrors.ErrRequestCanceled,
+		},
+	}
+
+	// Subtests are not parallel: repositories read the operation timeout from the global config.
+	for backend, newRepo := range backends {
+		for name, test := range tests {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("can't create mock: %s", err)
+				}
+				defer db.Close()
+
+				viper.Set(config.PostgresGetTimeout, test.getTimeout)
+				defer viper.Set(config.PostgresGetTimeout, time.Duration(0))
+				repo := newRepo(db)
+
+				f := fields{mock: mock}
+				if test.prepare != nil {
+					test.prepare(&f)
+				}
+
+				ctx, cancel := test.ctx()
+				defer cancel()
+
+				start := time.Now()
+				_, err = repo.Get(ctx, 3)
+				if !errors.Is(err, test.err) {
+					t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+				}
+				if elapsed := time.Since(start); elapsed >= time.Second {
+					t.Errorf("\nQuery was not canceled, took %s", elapsed)
+				}
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("\nThere were unfulfilled expectations: %s", err)
+				}
+			})
+		}
+	}
+}
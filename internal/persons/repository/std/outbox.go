@@ -0,0 +1,105 @@
+package std
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+const claimEventsCmd = `
+	UPDATE outbox
+	SET attempts = attempts + 1, next_attempt_at = now() + $1 * interval '1 millisecond'
+	WHERE id IN (
+		SELECT id
+		FROM outbox
+		WHERE published_at IS NULL AND next_attempt_at <= now()
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, event_type, person_id, payload, attempts, created_at;`
+
+func (repo *repository) ClaimEvents(ctx context.Context, limit int64, lease time.Duration) (
+	[]models.PersonEvent, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, claimEventsCmd, lease.Milliseconds(), limit)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", claimEventsCmd))
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	events := []models.PersonEvent{}
+	for rows.Next() {
+		var event models.PersonEvent
+		var payload []byte
+		err = rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.PersonID,
+			&payload,
+			&event.Attempts,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", claimEventsCmd))
+			return nil, dbError(ctx, err)
+		}
+
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", claimEventsCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	// The order of RETURNING rows is not guaranteed, while events are published in the order they happened.
+	slices.SortFunc(events, func(a, b models.PersonEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return events, nil
+}
+
+const markEventPublishedCmd = `
+	UPDATE outbox
+	SET published_at = now(), last_error = NULL
+	WHERE id = $1;`
+
+func (repo *repository) MarkEventPublished(ctx context.Context, eventID int64) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, markEventPublishedCmd, eventID)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", markEventPublishedCmd))
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+const retryEventCmd = `
+	UPDATE outbox
+	SET next_attempt_at = now() + $1 * interval '1 millisecond', last_error = $2
+	WHERE id = $3 AND published_at IS NULL;`
+
+func (repo *repository) RetryEvent(ctx context.Context, eventID int64, delay time.Duration, reason string) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, retryEventCmd, delay.Milliseconds(), reason, eventID)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", retryEventCmd))
+		return dbError(ctx, err)
+	}
+	return nil
+}
@@ -0,0 +1,717 @@
+package std
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/repository/audit"
+	"github.com/SlavaShagalov/ds-lab1/internal/persons/repository/criteria"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation = "23505"
+	queryCanceled   = "57014"
+)
+
+type timeouts struct {
+	healthCheck time.Duration
+	create      time.Duration
+	get         time.Duration
+	list        time.Duration
+	search      time.Duration
+	update      time.Duration
+	delete      time.Duration
+	batch       time.Duration
+	export      time.Duration
+	purge       time.Duration
+	outbox      time.Duration
+}
+
+// querier runs queries either directly on the pool or inside a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type repository struct {
+	db       *sql.DB
+	log      *zap.Logger
+	timeouts timeouts
+}
+
+func New(db *sql.DB, log *zap.Logger) pPersons.Repository {
+	return &repository{
+		db:  db,
+		log: log,
+		timeouts: timeouts{
+			healthCheck: viper.GetDuration(config.PostgresHealthCheckTimeout),
+			create:      viper.GetDuration(config.PostgresCreateTimeout),
+			get:         viper.GetDuration(config.PostgresGetTimeout),
+			list:        viper.GetDuration(config.PostgresListTimeout),
+			search:      viper.GetDuration(config.PostgresSearchTimeout),
+			update:      viper.GetDuration(config.PostgresUpdateTimeout),
+			delete:      viper.GetDuration(config.PostgresDeleteTimeout),
+			batch:       viper.GetDuration(config.PostgresBatchTimeout),
+			export:      viper.GetDuration(config.PostgresExportTimeout),
+			purge:       viper.GetDuration(config.PostgresPurgeTimeout),
+			outbox:      viper.GetDuration(config.PostgresOutboxTimeout),
+		},
+	}
+}
+
+func (repo *repository) HealthCheck(ctx context.Context) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.healthCheck)
+	defer cancel()
+
+	err := repo.db.PingContext(ctx)
+	if err != nil {
+		repo.logger(ctx).Error("Postgres health check failed", zap.Error(err))
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+const createCmd = `
+	INSERT INTO persons (name, age, address, work) 
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+func (repo *repository) Create(ctx context.Context, params *pPersons.CreateParams) (*models.Person, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.create)
+	defer cancel()
+
+	return repo.inTxPerson(ctx, func(tx *sql.Tx) (*models.Person, error) {
+		return repo.create(ctx, tx, params)
+	})
+}
+
+// create inserts the person and records the change, q must be a transaction for the two to be atomic.
+func (repo *repository) create(ctx context.Context, q querier, params *pPersons.CreateParams) (*models.Person, error) {
+	row := q.QueryRowContext(ctx, createCmd,
+		params.Name,
+		params.Age,
+		params.Address,
+		params.Work,
+	)
+
+	person := new(models.Person)
+	err := scanPerson(row, person)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", createCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	err = repo.audit(ctx, q, audit.Created(ctx, person))
+	if err != nil {
+		return nil, err
+	}
+
+	repo.logger(ctx).Debug("New person created", zap.Any("person", person))
+	return person, nil
+}
+
+const getCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL;`
+
+func (repo *repository) Get(ctx context.Context, id int64) (*models.Person, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.get)
+	defer cancel()
+
+	return repo.get(ctx, repo.db, id)
+}
+
+func (repo *repository) get(ctx context.Context, q querier, id int64) (*models.Person, error) {
+	return repo.queryPerson(ctx, q, getCmd, id)
+}
+
+const (
+	lockCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE;`
+
+	lockDeletedCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons
+	WHERE id = $1
+	FOR UPDATE;`
+)
+
+// lock reads the person and keeps other transactions from changing it until the end of the transaction,
+// so that the audit trail gets what the person was right before the change. Deleted persons are locked too
+// if withDeleted is set.
+func (repo *repository) lock(ctx context.Context, tx *sql.Tx, id int64, withDeleted bool) (*models.Person, error) {
+	if withDeleted {
+		return repo.queryPerson(ctx, tx, lockDeletedCmd, id)
+	}
+	return repo.queryPerson(ctx, tx, lockCmd, id)
+}
+
+func (repo *repository) queryPerson(ctx context.Context, q querier, cmd string, id int64) (*models.Person, error) {
+	row := q.QueryRowContext(ctx, cmd, id)
+
+	person := new(models.Person)
+	err := scanPerson(row, person)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.Wrap(pErrors.ErrPersonNotFound, err.Error())
+		}
+
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", cmd),
+			zap.Int64("id", id))
+		return nil, dbError(ctx, err)
+	}
+
+	return person, nil
+}
+
+const listCmd = `
+	SELECT id, name, age, address, work, version, deleted_at
+	FROM persons`
+
+func (repo *repository) List(ctx context.Context, params *pPersons.ListParams) ([]models.Person, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.list)
+	defer cancel()
+
+	query, args, err := listQuery(params)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", query))
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	persons, err := scanPersons(rows)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", query))
+		return nil, dbError(ctx, err)
+	}
+
+	if params.Cursor != nil && params.Cursor.Backward {
+		slices.Reverse(persons)
+	}
+	return persons, nil
+}
+
+func (repo *repository) Export(ctx context.Context, params *pPersons.ListParams,
+	fn func(person *models.Person) error) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.export)
+	defer cancel()
+
+	query, args, err := listQuery(params)
+	if err != nil {
+		return err
+	}
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", query))
+		return dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	var person models.Person
+	for rows.Next() {
+		err = rows.Scan(
+			&person.ID,
+			&person.Name,
+			&person.Age,
+			&person.Address,
+			&person.Work,
+			&person.Version,
+			&person.DeletedAt,
+		)
+		if err != nil {
+			repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", query))
+			return dbError(ctx, err)
+		}
+
+		if err = fn(&person); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", query))
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+func listQuery(params *pPersons.ListParams) (string, []any, error) {
+	args := make([]any, 0, len(params.Criteria.Conditions)+3)
+	clauses, err := criteria.ListClauses(params, func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return listCmd + clauses, args, nil
+}
+
+const searchCmd = `
+	SELECT id, name, age, address, work, version, deleted_at,
+	       ts_rank(search, query) AS rank,
+	       ts_headline('simple', concat_ws(' | ', name, address, work), query,
+	                   'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS snippet
+	FROM persons, to_tsquery('simple', $1) AS query
+	WHERE search @@ query AND deleted_at IS NULL
+	ORDER BY rank DESC, id
+	OFFSET $2
+	LIMIT $3;`
+
+func (repo *repository) Search(ctx context.Context, params *pPersons.SearchParams) ([]pPersons.SearchResult, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.search)
+	defer cancel()
+
+	tsQuery := criteria.TSQuery(params.Query)
+	if tsQuery == "" {
+		return []pPersons.SearchResult{}, nil
+	}
+
+	var limit any
+	if params.Limit != 0 {
+		limit = params.Limit
+	}
+
+	rows, err := repo.db.QueryContext(ctx, searchCmd, tsQuery, params.Offset, limit)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", searchCmd))
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	results := []pPersons.SearchResult{}
+	var result pPersons.SearchResult
+	for rows.Next() {
+		err = rows.Scan(
+			&result.ID,
+			&result.Name,
+			&result.Age,
+			&result.Address,
+			&result.Work,
+			&result.Version,
+			&result.DeletedAt,
+			&result.Rank,
+			&result.Snippet,
+		)
+		if err != nil {
+			repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", searchCmd))
+			return nil, dbError(ctx, err)
+		}
+
+		results = append(results, result)
+	}
+	if err = rows.Err(); err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", searchCmd))
+		return nil, dbError(ctx, err)
+	}
+
+	return results, nil
+}
+
+const fullUpdateCmd = `
+	UPDATE persons
+	SET %s, version = version + 1
+	WHERE %s AND deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+func (repo *repository) PartialUpdate(ctx context.Context, params *pPersons.PartialUpdateParams) (*models.Person, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.update)
+	defer cancel()
+
+	return repo.inTxPerson(ctx, func(tx *sql.Tx) (*models.Person, error) {
+		return repo.partialUpdate(ctx, tx, params)
+	})
+}
+
+func (repo *repository) partialUpdate(ctx context.Context, tx *sql.Tx, params *pPersons.PartialUpdateParams) (
+	*models.Person, error) {
+	before, err := repo.lock(ctx, tx, params.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	if params.ExpectedVersion != 0 && before.Version != params.ExpectedVersion {
+		return nil, versionMismatch(params.ExpectedVersion, before.Version)
+	}
+
+	setValues := make([]string, 0, 4)
+	args := make([]any, 0, 6)
+	if params.Name != nil {
+		setValue := fmt.Sprintf("name = $%d", len(args)+1)
+		args = append(args, *params.Name)
+		setValues = append(setValues, setValue)
+	}
+	if params.Age != nil {
+		setValue := fmt.Sprintf("age = $%d", len(args)+1)
+		args = append(args, *params.Age)
+		setValues = append(setValues, setValue)
+	}
+	if params.Address != nil {
+		setValue := fmt.Sprintf("address = $%d", len(args)+1)
+		args = append(args, *params.Address)
+		setValues = append(setValues, setValue)
+	}
+	if params.Work != nil {
+		setValue := fmt.Sprintf("work = $%d", len(args)+1)
+		args = append(args, *params.Work)
+		setValues = append(setValues, setValue)
+	}
+	if len(setValues) == 0 {
+		return before, nil
+	}
+
+	where := fmt.Sprintf("id = $%d", len(args)+1)
+	args = append(args, params.ID)
+	if params.ExpectedVersion != 0 {
+		where += fmt.Sprintf(" AND version = $%d", len(args)+1)
+		args = append(args, params.ExpectedVersion)
+	}
+	cmd := fmt.Sprintf(fullUpdateCmd, strings.Join(setValues, ", "), where)
+
+	row := tx.QueryRowContext(ctx, cmd, args...)
+	person := new(models.Person)
+	err = scanPerson(row, person)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repo.noRowsError(ctx, tx, params.ID, params.ExpectedVersion, err)
+		}
+
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", cmd))
+		return nil, dbError(ctx, err)
+	}
+
+	if change, changed := audit.Updated(ctx, before, person); changed {
+		err = repo.audit(ctx, tx, change)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repo.logger(ctx).Debug("Person partial updated", zap.Any("person", person))
+	return person, nil
+}
+
+const (
+	replaceCmd = `
+	INSERT INTO persons (id, name, age, address, work)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (id) DO UPDATE
+	SET name = excluded.name, age = excluded.age, address = excluded.address, work = excluded.work,
+	    version = persons.version + 1
+	WHERE persons.deleted_at IS NULL
+	RETURNING id, name, age, address, work, version, deleted_at, xmax = 0 AS created;`
+
+	// lastIssuedIDCmd returns the greatest id the id sequence handed out.
+	lastIssuedIDCmd = `
+	SELECT CASE WHEN is_called THEN last_value ELSE last_value - 1 END
+	FROM persons_id_seq;`
+)
+
+func (repo *repository) Replace(ctx context.Context, params *pPersons.ReplaceParams) (*models.Person, bool, error) {
+	if params.ID <= 0 {
+		return nil, false, errors.Wrapf(pErrors.ErrPersonNotFound, "id %d is not positive", params.ID)
+	}
+	if params.ExpectedVersion != 0 {
+		person, err := repo.PartialUpdate(ctx, &pPersons.PartialUpdateParams{
+			ID:              params.ID,
+			Name:            &params.Name,
+			Age:             &params.Age,
+			Address:         &params.Address,
+			Work:            &params.Work,
+			ExpectedVersion: params.ExpectedVersion,
+		})
+		return person, false, err
+	}
+
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.update)
+	defer cancel()
+
+	var created bool
+	person, err := repo.inTxPerson(ctx, func(tx *sql.Tx) (*models.Person, error) {
+		var err error
+		var person *models.Person
+		person, created, err = repo.replace(ctx, tx, params)
+		return person, err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	repo.logger(ctx).Debug("Person replaced", zap.Any("person", person), zap.Bool("created", created))
+	return person, created, nil
+}
+
+func (repo *repository) replace(ctx context.Context, tx *sql.Tx, params *pPersons.ReplaceParams) (
+	*models.Person, bool, error) {
+	before, err := repo.lock(ctx, tx, params.ID, true)
+	if err != nil && !errors.Is(err, pErrors.ErrPersonNotFound) {
+		return nil, false, err
+	}
+	if before == nil {
+		var lastIssuedID int64
+		err = tx.QueryRowContext(ctx, lastIssuedIDCmd).Scan(&lastIssuedID)
+		if err != nil {
+			repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", lastIssuedIDCmd))
+			return nil, false, dbError(ctx, err)
+		}
+		// Only ids handed out by the sequence are created, so that a client can not use up the sequence
+		// with a huge id.
+		if params.ID > lastIssuedID {
+			return nil, false, errors.Wrapf(pErrors.ErrPersonNotFound, "id %d was never issued", params.ID)
+		}
+	}
+
+	row := tx.QueryRowContext(ctx, replaceCmd,
+		params.ID,
+		params.Name,
+		params.Age,
+		params.Address,
+		params.Work,
+	)
+
+	person := new(models.Person)
+	var created bool
+	err = row.Scan(
+		&person.ID,
+		&person.Name,
+		&person.Age,
+		&person.Address,
+		&person.Work,
+		&person.Version,
+		&person.DeletedAt,
+		&created,
+	)
+	if err != nil {
+		// A deleted person is only brought back by Restore.
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, repo.noRowsError(ctx, tx, params.ID, 0, err)
+		}
+
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", replaceCmd))
+		return nil, false, dbError(ctx, err)
+	}
+
+	err = repo.audit(ctx, tx, audit.Replaced(ctx, before, person)...)
+	if err != nil {
+		return nil, false, err
+	}
+	return person, created, nil
+}
+
+const (
+	deleteCmd = `
+	UPDATE persons
+	SET deleted_at = now(), version = version + 1
+	WHERE id = $1 AND deleted_at IS NULL;`
+
+	deleteVersionCmd = `
+	UPDATE persons
+	SET deleted_at = now(), version = version + 1
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL;`
+)
+
+func (repo *repository) Delete(ctx context.Context, id int64, expectedVersion int64) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.delete)
+	defer cancel()
+
+	return repo.inTx(ctx, func(tx *sql.Tx) error {
+		return repo.delete(ctx, tx, id, expectedVersion)
+	})
+}
+
+func (repo *repository) delete(ctx context.Context, tx *sql.Tx, id int64, expectedVersion int64) error {
+	before, err := repo.lock(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return versionMismatch(expectedVersion, before.Version)
+	}
+
+	cmd, args := deleteCmd, []any{id}
+	if expectedVersion != 0 {
+		cmd, args = deleteVersionCmd, append(args, expectedVersion)
+	}
+
+	result, err := tx.ExecContext(ctx, cmd, args...)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.Int64("id", id))
+		return dbError(ctx, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return repo.noRowsError(ctx, tx, id, expectedVersion, sql.ErrNoRows)
+	}
+
+	err = repo.audit(ctx, tx, audit.Deleted(ctx, before))
+	if err != nil {
+		return err
+	}
+
+	repo.logger(ctx).Debug("Person deleted", zap.Int64("id", id))
+	return nil
+}
+
+const restoreCmd = `
+	UPDATE persons
+	SET deleted_at = NULL, version = version + 1
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	RETURNING id, name, age, address, work, version, deleted_at;`
+
+func (repo *repository) Restore(ctx context.Context, id int64) (*models.Person, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.update)
+	defer cancel()
+
+	return repo.inTxPerson(ctx, func(tx *sql.Tx) (*models.Person, error) {
+		return repo.restore(ctx, tx, id)
+	})
+}
+
+func (repo *repository) restore(ctx context.Context, tx *sql.Tx, id int64) (*models.Person, error) {
+	row := tx.QueryRowContext(ctx, restoreCmd, id)
+	person := new(models.Person)
+	err := scanPerson(row, person)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The person is either not deleted or does not exist at all.
+			return repo.get(ctx, tx, id)
+		}
+
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", restoreCmd),
+			zap.Int64("id", id))
+		return nil, dbError(ctx, err)
+	}
+
+	err = repo.audit(ctx, tx, audit.Restored(ctx, person))
+	if err != nil {
+		return nil, err
+	}
+
+	repo.logger(ctx).Debug("Person restored", zap.Int64("id", id))
+	return person, nil
+}
+
+// purgeCmd removes the oldest deletions first, so that repeated calls make progress however many rows expired.
+const purgeCmd = `
+	DELETE FROM persons
+	WHERE id IN (
+	    SELECT id
+	    FROM persons
+	    WHERE deleted_at < $1
+	    ORDER BY deleted_at
+	    LIMIT $2
+	);`
+
+func (repo *repository) Purge(ctx context.Context, deletedBefore time.Time, limit int64) (int64, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.purge)
+	defer cancel()
+
+	result, err := repo.db.ExecContext(ctx, purgeCmd, deletedBefore, limit)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", purgeCmd))
+		return 0, dbError(ctx, err)
+	}
+
+	purged, _ := result.RowsAffected()
+	repo.logger(ctx).Debug("Deleted persons purged", zap.Int64("count", purged))
+	return purged, nil
+}
+
+// noRowsError explains why a write conditioned on expectedVersion matched no rows:
+// either the person does not exist or it has another version.
+func (repo *repository) noRowsError(ctx context.Context, q querier, id int64, expectedVersion int64,
+	err error) error {
+	if expectedVersion == 0 {
+		return errors.Wrap(pErrors.ErrPersonNotFound, err.Error())
+	}
+
+	person, err := repo.get(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	return versionMismatch(expectedVersion, person.Version)
+}
+
+func versionMismatch(expected, actual int64) error {
+	return errors.Wrapf(pErrors.ErrVersionMismatch, "expected version %d, got %d", expected, actual)
+}
+
+func scanPerson(row *sql.Row, person *models.Person) error {
+	return row.Scan(
+		&person.ID,
+		&person.Name,
+		&person.Age,
+		&person.Address,
+		&person.Work,
+		&person.Version,
+		&person.DeletedAt,
+	)
+}
+
+func scanPersons(rows *sql.Rows) ([]models.Person, error) {
+	persons := []models.Person{}
+	var person models.Person
+	for rows.Next() {
+		err := rows.Scan(
+			&person.ID,
+			&person.Name,
+			&person.Age,
+			&person.Address,
+			&person.Work,
+			&person.Version,
+			&person.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		persons = append(persons, person)
+	}
+	return persons, rows.Err()
+}
+
+// logger returns the logger of the request that ctx belongs to.
+func (repo *repository) logger(ctx context.Context) *zap.Logger {
+	return requestinfo.Logger(ctx, repo.log)
+}
+
+// dbError translates Postgres errors into repository errors, leaving the others to postgres.QueryError.
+// A query canceled by the server rather than by ctx is reported as canceled too.
+func dbError(ctx context.Context, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == uniqueViolation:
+			return errors.Wrap(pErrors.ErrPersonAlreadyExists, err.Error())
+		case pqErr.Code == queryCanceled && ctx.Err() == nil:
+			return errors.Wrap(pErrors.ErrRequestCanceled, err.Error())
+		}
+	}
+	return postgres.QueryError(ctx, err)
+}
@@ -0,0 +1,50 @@
+package std
+
+import (
+	"context"
+	"database/sql"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+)
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise.
+func (repo *repository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err))
+		return dbError(ctx, err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			repo.logger(ctx).Error("Failed to roll back transaction", zap.Error(rollbackErr))
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err))
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+// inTxPerson runs fn in a transaction and returns the person it produced once the transaction is committed.
+func (repo *repository) inTxPerson(ctx context.Context, fn func(tx *sql.Tx) (*models.Person, error)) (
+	*models.Person, error) {
+	var person *models.Person
+	err := repo.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		person, err = fn(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return person, nil
+}
@@ -0,0 +1,160 @@
+// Package tracing traces the calls of a repository of persons.
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pTracing "github.com/SlavaShagalov/ds-lab1/internal/pkg/tracing"
+)
+
+type repository struct {
+	repo   pPersons.Repository
+	tracer *pTracing.Tracer
+}
+
+// New returns a repository that starts a span for every call of repo within a trace, named after the method.
+// The queries it makes are traced below it by the query tracer of the pgx driver, the std driver has none.
+func New(repo pPersons.Repository, tracer *pTracing.Tracer) pPersons.Repository {
+	return &repository{
+		repo:   repo,
+		tracer: tracer,
+	}
+}
+
+// start starts the span of a call. Calls outside of a trace, such as those of background jobs, are not traced,
+// so that polling does not start a trace every time; their span is nil.
+func (repo *repository) start(ctx context.Context, method string) (context.Context, *pTracing.Span) {
+	if pTracing.SpanFromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return repo.tracer.Start(ctx, "persons.Repository/"+method, pTracing.SpanKindInternal,
+		pTracing.String("code.function", method))
+}
+
+// end is deferred with the span of a call and a pointer to its error.
+func (repo *repository) end(span *pTracing.Span, err *error) {
+	span.RecordError(*err)
+	span.End()
+}
+
+func (repo *repository) HealthCheck(ctx context.Context) (err error) {
+	ctx, span := repo.start(ctx, "HealthCheck")
+	defer repo.end(span, &err)
+	return repo.repo.HealthCheck(ctx)
+}
+
+func (repo *repository) Create(ctx context.Context, params *pPersons.CreateParams) (_ *models.Person, err error) {
+	ctx, span := repo.start(ctx, "Create")
+	defer repo.end(span, &err)
+	return repo.repo.Create(ctx, params)
+}
+
+func (repo *repository) Get(ctx context.Context, personID int64) (_ *models.Person, err error) {
+	ctx, span := repo.start(ctx, "Get")
+	defer repo.end(span, &err)
+	return repo.repo.Get(ctx, personID)
+}
+
+func (repo *repository) List(ctx context.Context, params *pPersons.ListParams) (_ []models.Person, err error) {
+	ctx, span := repo.start(ctx, "List")
+	defer repo.end(span, &err)
+	return repo.repo.List(ctx, params)
+}
+
+// Export spans the whole export, the persons are passed to fn while they are read.
+func (repo *repository) Export(ctx context.Context, params *pPersons.ListParams,
+	fn func(person *models.Person) error) (err error) {
+	ctx, span := repo.start(ctx, "Export")
+	defer repo.end(span, &err)
+	return repo.repo.Export(ctx, params, fn)
+}
+
+func (repo *repository) Search(ctx context.Context, params *pPersons.SearchParams) (
+	_ []pPersons.SearchResult, err error) {
+	ctx, span := repo.start(ctx, "Search")
+	defer repo.end(span, &err)
+	return repo.repo.Search(ctx, params)
+}
+
+func (repo *repository) Replace(ctx context.Context, params *pPersons.ReplaceParams) (
+	_ *models.Person, _ bool, err error) {
+	ctx, span := repo.start(ctx, "Replace")
+	defer repo.end(span, &err)
+	return repo.repo.Replace(ctx, params)
+}
+
+func (repo *repository) PartialUpdate(ctx context.Context, params *pPersons.PartialUpdateParams) (
+	_ *models.Person, err error) {
+	ctx, span := repo.start(ctx, "PartialUpdate")
+	defer repo.end(span, &err)
+	return repo.repo.PartialUpdate(ctx, params)
+}
+
+func (repo *repository) Delete(ctx context.Context, personID int64, expectedVersion int64) (err error) {
+	ctx, span := repo.start(ctx, "Delete")
+	defer repo.end(span, &err)
+	return repo.repo.Delete(ctx, personID, expectedVersion)
+}
+
+func (repo *repository) Restore(ctx context.Context, personID int64) (_ *models.Person, err error) {
+	ctx, span := repo.start(ctx, "Restore")
+	defer repo.end(span, &err)
+	return repo.repo.Restore(ctx, personID)
+}
+
+func (repo *repository) Purge(ctx context.Context, deletedBefore time.Time, limit int64) (_ int64, err error) {
+	ctx, span := repo.start(ctx, "Purge")
+	defer repo.end(span, &err)
+	return repo.repo.Purge(ctx, deletedBefore, limit)
+}
+
+func (repo *repository) History(ctx context.Context, params *pPersons.HistoryParams) (
+	_ []models.PersonChange, err error) {
+	ctx, span := repo.start(ctx, "History")
+	defer repo.end(span, &err)
+	return repo.repo.History(ctx, params)
+}
+
+func (repo *repository) ClaimEvents(ctx context.Context, limit int64, lease time.Duration) (
+	_ []models.PersonEvent, err error) {
+	ctx, span := repo.start(ctx, "ClaimEvents")
+	defer repo.end(span, &err)
+	return repo.repo.ClaimEvents(ctx, limit, lease)
+}
+
+func (repo *repository) MarkEventPublished(ctx context.Context, eventID int64) (err error) {
+	ctx, span := repo.start(ctx, "MarkEventPublished")
+	defer repo.end(span, &err)
+	return repo.repo.MarkEventPublished(ctx, eventID)
+}
+
+func (repo *repository) RetryEvent(ctx context.Context, eventID int64, delay time.Duration, reason string) (
+	err error) {
+	ctx, span := repo.start(ctx, "RetryEvent")
+	defer repo.end(span, &err)
+	return repo.repo.RetryEvent(ctx, eventID, delay, reason)
+}
+
+func (repo *repository) CreateBatch(ctx context.Context, params []pPersons.CreateParams, mode pPersons.BatchMode) (
+	_ []pPersons.BatchResult, err error) {
+	ctx, span := repo.start(ctx, "CreateBatch")
+	defer repo.end(span, &err)
+	return repo.repo.CreateBatch(ctx, params, mode)
+}
+
+func (repo *repository) PartialUpdateBatch(ctx context.Context, params []pPersons.PartialUpdateParams,
+	mode pPersons.BatchMode) (_ []pPersons.BatchResult, err error) {
+	ctx, span := repo.start(ctx, "PartialUpdateBatch")
+	defer repo.end(span, &err)
+	return repo.repo.PartialUpdateBatch(ctx, params, mode)
+}
+
+func (repo *repository) DeleteBatch(ctx context.Context, personIDs []int64, mode pPersons.BatchMode) (
+	_ []pPersons.BatchResult, err error) {
+	ctx, span := repo.start(ctx, "DeleteBatch")
+	defer repo.end(span, &err)
+	return repo.repo.DeleteBatch(ctx, personIDs, mode)
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pPersons "github.com/SlavaShagalov/ds-lab1/internal/persons"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pTracing "github.com/SlavaShagalov/ds-lab1/internal/pkg/tracing"
+)
+
+// store finds the person with id 1 only.
+type store struct {
+	pPersons.Repository
+}
+
+func (store) Get(_ context.Context, id int64) (*models.Person, error) {
+	if id != 1 {
+		return nil, pErrors.ErrPersonNotFound
+	}
+	return &models.Person{ID: 1, Name: "Johnny"}, nil
+}
+
+type exporter struct {
+	mu    sync.Mutex
+	spans []pTracing.SpanData
+}
+
+func (e *exporter) Export(_ context.Context, spans []pTracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *exporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestRepository(t *testing.T) {
+	exp := &exporter{}
+	tracer := pTracing.NewTracer(exp, 1, zap.NewNop())
+	repo := New(store{}, tracer)
+
+	// Calls outside of a trace are not traced.
+	if _, err := repo.Get(context.Background(), 1); err != nil {
+		t.Errorf("\nExpected: %v\nGot: %v", nil, err)
+	}
+
+	ctx, parent := tracer.Start(context.Background(), "GET /api/v1/persons/{id}", pTracing.SpanKindServer)
+	if person, err := repo.Get(ctx, 1); err != nil || person.Name != "Johnny" {
+		t.Errorf("\nExpected: Johnny\nGot: %v, %v", person, err)
+	}
+	if _, err := repo.Get(ctx, 2); err != pErrors.ErrPersonNotFound {
+		t.Errorf("\nExpected: %s\nGot: %v", pErrors.ErrPersonNotFound, err)
+	}
+	parent.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+	}
+
+	if len(exp.spans) != 3 {
+		t.Fatalf("\nExpected: 3 spans\nGot: %+v", exp.spans)
+	}
+	for i, failed := range []bool{false, true} {
+		span := exp.spans[i]
+		if span.Name != "persons.Repository/Get" || span.Parent != parent.SpanContext().SpanID {
+			t.Errorf("\nExpected: persons.Repository/Get under the request\nGot: %+v", span)
+		}
+		if (span.Status == pTracing.StatusError) != failed {
+			t.Errorf("\nExpected: failed %t\nGot: %+v", failed, span)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package stream fans person changes announced by Postgres out to the clients of the change feed.
+package stream
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Event types. Reset tells a client that it may have missed changes and must reload what it shows.
+const (
+	EventCreate  = "create"
+	EventUpdate  = "update"
+	EventDelete  = "delete"
+	EventRestore = "restore"
+	EventReset   = "reset"
+)
+
+// subscriptionBuffer is the number of events a subscriber may lag behind before it is dropped.
+const subscriptionBuffer = 64
+
+type Event struct {
+	ID   int64
+	Type string
+	// Data is the JSON document of the changed person.
+	Data json.RawMessage
+}
+
+// Hub passes every published event to all subscribers and keeps the latest ones for clients that resume.
+// Events are kept in the order they were published, which is the commit order of the changes, while their ids
+// are drawn before commit, so resuming looks up the last seen id instead of comparing ids.
+type Hub struct {
+	mu          sync.Mutex
+	replaySize  int
+	replay      []Event
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewHub(replaySize int) *Hub {
+	return &Hub{
+		replaySize:  replaySize,
+		replay:      make([]Event, 0, replaySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription delivers events published after it was made. Its channel is closed when the subscriber falls
+// too far behind or the hub is closed; the client is expected to reconnect and resume then.
+type Subscription struct {
+	hub    *Hub
+	events chan Event
+}
+
+func (sub *Subscription) Events() <-chan Event {
+	return sub.events
+}
+
+// Close stops the delivery of events, it may be called more than once.
+func (sub *Subscription) Close() {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+
+	sub.hub.drop(sub)
+}
+
+// Subscribe starts a subscription. Unless lastEventID is 0, the events that followed it are returned to be sent
+// first; complete is false if the event is no longer kept, so some events following it were lost.
+func (h *Hub) Subscribe(lastEventID int64) (sub *Subscription, replay []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = &Subscription{hub: h, events: make(chan Event, subscriptionBuffer)}
+	if h.closed {
+		close(sub.events)
+		return sub, nil, true
+	}
+	h.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil, true
+	}
+	for i := len(h.replay) - 1; i >= 0; i-- {
+		if h.replay[i].ID == lastEventID {
+			return sub, append([]Event(nil), h.replay[i+1:]...), true
+		}
+	}
+	return sub, nil, false
+}
+
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	if h.replaySize > 0 {
+		if len(h.replay) == h.replaySize {
+			copy(h.replay, h.replay[1:])
+			h.replay = h.replay[:len(h.replay)-1]
+		}
+		h.replay = append(h.replay, event)
+	}
+	h.broadcast(event)
+}
+
+// Reset forgets the kept events and tells subscribers to reload. It is called when events may have been lost,
+// such as while the connection to Postgres was down.
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.replay = h.replay[:0]
+	h.broadcast(Event{Type: EventReset})
+}
+
+// Close ends every subscription, so that the streams finish and the server can shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		h.drop(sub)
+	}
+}
+
+func (h *Hub) broadcast(event Event) {
+	for sub := range h.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			h.drop(sub)
+		}
+	}
+}
+
+func (h *Hub) drop(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,131 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+func event(id int64) Event {
+	return Event{ID: id, Type: EventUpdate, Data: []byte(`{"id":3}`)}
+}
+
+func TestSubscribe(t *testing.T) {
+	type testCase struct {
+		lastEventID int64
+		replay      []Event
+		complete    bool
+	}
+
+	// Ids are out of order, as they are drawn before changes commit.
+	published := []Event{event(1), event(2), event(4), event(3), event(5)}
+
+	tests := map[string]testCase{
+		"new client": {
+			lastEventID: 0,
+			replay:      nil,
+			complete:    true,
+		},
+		"kept event": {
+			lastEventID: 4,
+			replay:      []Event{event(3), event(5)},
+			complete:    true,
+		},
+		"latest event": {
+			lastEventID: 5,
+			replay:      nil,
+			complete:    true,
+		},
+		"forgotten event": {
+			lastEventID: 1,
+			replay:      nil,
+			complete:    false,
+		},
+		"unknown event": {
+			lastEventID: 9,
+			replay:      nil,
+			complete:    false,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			hub := NewHub(4)
+			for _, e := range published {
+				hub.Publish(e)
+			}
+
+			sub, replay, complete := hub.Subscribe(test.lastEventID)
+			defer sub.Close()
+			if complete != test.complete {
+				t.Errorf("\nExpected: %t\nGot: %t", test.complete, complete)
+			}
+			if !reflect.DeepEqual(replay, test.replay) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.replay, replay)
+			}
+
+			hub.Publish(event(6))
+			if got := <-sub.Events(); got.ID != 6 {
+				t.Errorf("\nExpected: %d\nGot: %d", 6, got.ID)
+			}
+		})
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	hub := NewHub(0)
+	slow, _, _ := hub.Subscribe(0)
+	fast, _, _ := hub.Subscribe(0)
+	defer fast.Close()
+
+	for id := int64(1); id <= subscriptionBuffer+1; id++ {
+		hub.Publish(event(id))
+		<-fast.Events()
+	}
+
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if received != subscriptionBuffer {
+		t.Errorf("\nExpected: %d\nGot: %d", subscriptionBuffer, received)
+	}
+	// Closing a dropped subscription is a no-op.
+	slow.Close()
+}
+
+func TestReset(t *testing.T) {
+	hub := NewHub(10)
+	hub.Publish(event(1))
+	sub, _, _ := hub.Subscribe(0)
+	defer sub.Close()
+
+	hub.Reset()
+	if got := <-sub.Events(); got.Type != EventReset {
+		t.Errorf("\nExpected: %s\nGot: %s", EventReset, got.Type)
+	}
+
+	resumed, _, complete := hub.Subscribe(1)
+	defer resumed.Close()
+	if complete {
+		t.Errorf("\nExpected: %t\nGot: %t", false, complete)
+	}
+}
+
+func TestClose(t *testing.T) {
+	hub := NewHub(10)
+	sub, _, _ := hub.Subscribe(0)
+
+	hub.Close()
+	if _, ok := <-sub.Events(); ok {
+		t.Errorf("\nExpected: closed subscription\nGot: an event")
+	}
+
+	late, _, _ := hub.Subscribe(0)
+	if _, ok := <-late.Events(); ok {
+		t.Errorf("\nExpected: closed subscription\nGot: an event")
+	}
+	hub.Publish(event(1))
+}
@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/backoff"
+)
+
+// Channel is the notification channel of the persons_notify_change trigger.
+const Channel = "persons_changes"
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Listener feeds the hub with the notifications of the persons_notify_change trigger.
+type Listener struct {
+	connect func(ctx context.Context) (*pgx.Conn, error)
+	hub     *Hub
+	log     *zap.Logger
+}
+
+// NewListener creates a listener that opens its connections with connect, LISTEN needs a dedicated one.
+func NewListener(connect func(ctx context.Context) (*pgx.Conn, error), hub *Hub, log *zap.Logger) *Listener {
+	return &Listener{
+		connect: connect,
+		hub:     hub,
+		log:     log,
+	}
+}
+
+// Run listens until ctx is done, reconnecting whenever the connection fails. Notifications sent while there
+// was no connection are lost, so the hub is reset after reconnecting.
+func (l *Listener) Run(ctx context.Context) {
+	for attempts := 1; ; attempts++ {
+		listening, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if listening {
+			attempts = 1
+		}
+
+		delay := backoff.Exponential(attempts, minReconnectDelay, maxReconnectDelay)
+		l.log.Error("Persons change feed disconnected", zap.Duration("retry_in", delay), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// listen returns when the connection fails, listening reports whether LISTEN succeeded before that.
+func (l *Listener) listen(ctx context.Context) (listening bool, err error) {
+	conn, err := l.connect(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	_, err = conn.Exec(ctx, "LISTEN "+Channel)
+	if err != nil {
+		return false, err
+	}
+	l.hub.Reset()
+	l.log.Info("Persons change feed connected")
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		event, err := parseNotification(notification.Payload)
+		if err != nil {
+			l.log.Error("Bad persons change notification", zap.String("payload", notification.Payload),
+				zap.Error(err))
+			continue
+		}
+		l.hub.Publish(event)
+	}
+}
+
+// notification is the payload of the persons_notify_change trigger.
+type notification struct {
+	ID     int64           `json:"id"`
+	Type   string          `json:"type"`
+	Person json.RawMessage `json:"person"`
+}
+
+func parseNotification(payload string) (Event, error) {
+	var n notification
+	err := json.Unmarshal([]byte(payload), &n)
+	if err != nil {
+		return Event{}, err
+	}
+	if n.ID <= 0 || n.Type == "" || len(n.Person) == 0 {
+		return Event{}, errors.New("notification misses id, type or person")
+	}
+	return Event{ID: n.ID, Type: n.Type, Data: n.Person}, nil
+}
@@ -0,0 +1,44 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNotification(t *testing.T) {
+	type testCase struct {
+		payload string
+		event   Event
+		isErr   bool
+	}
+
+	tests := map[string]testCase{
+		"change": {
+			payload: `{"id": 7, "type": "delete", "person": {"id": 3, "name": "Johnny", "version": 2}}`,
+			event:   Event{ID: 7, Type: EventDelete, Data: []byte(`{"id": 3, "name": "Johnny", "version": 2}`)},
+		},
+		"missing person": {
+			payload: `{"id": 7, "type": "delete"}`,
+			isErr:   true,
+		},
+		"malformed": {
+			payload: `{"id": 7,`,
+			isErr:   true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			event, err := parseNotification(test.payload)
+			if (err != nil) != test.isErr {
+				t.Fatalf("\nExpected error: %t\nGot: %v", test.isErr, err)
+			}
+			if !reflect.DeepEqual(event, test.event) {
+				t.Errorf("\nExpected: %+v\nGot: %+v", test.event, event)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package persons
+
+import "github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+
+const (
+	NameMaxLength    = 255
+	AddressMaxLength = 255
+	WorkMaxLength    = 255
+
+	MinAge = 0
+	MaxAge = 150
+)
+
+// Validate checks a new person against the rules shared by every way of creating one: the API and imports.
+func (params *CreateParams) Validate() error {
+	return validation.Validate(
+		validation.Field("name", params.Name, validation.Required(), validation.MaxLength(NameMaxLength)),
+		validation.Field("age", params.Age, validation.Range(MinAge, MaxAge)),
+		validation.Field("address", params.Address, validation.MaxLength(AddressMaxLength)),
+		validation.Field("work", params.Work, validation.MaxLength(WorkMaxLength)),
+	)
+}
@@ -0,0 +1,17 @@
+// Package backoff computes delays between attempts of operations that are retried until they succeed.
+package backoff
+
+import "time"
+
+// Exponential returns the delay after the given number of failed attempts: min after the first one,
+// doubled after every next one and capped at max.
+func Exponential(attempts int, min, max time.Duration) time.Duration {
+	delay := min
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
@@ -0,0 +1,23 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponential(t *testing.T) {
+	tests := map[int]time.Duration{
+		0:    time.Second,
+		1:    time.Second,
+		2:    2 * time.Second,
+		4:    8 * time.Second,
+		5:    10 * time.Second,
+		1000: 10 * time.Second,
+	}
+
+	for attempts, expected := range tests {
+		if delay := Exponential(attempts, time.Second, 10*time.Second); delay != expected {
+			t.Errorf("\nAttempts %d\nExpected: %s\nGot: %s", attempts, expected, delay)
+		}
+	}
+}
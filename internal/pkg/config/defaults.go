@@ -1,9 +1,116 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
+// Server
+
+func SetDefaultServerConfig() {
+	viper.SetDefault(ServerPort, 8080)
+	viper.SetDefault(ServerShutdownTimeout, 15*time.Second)
+	// Empty token disables admin only features.
+	viper.SetDefault(ServerAdminToken, "")
+}
+
+// Pagination
+
+func SetDefaultPaginationConfig() {
+	// Empty secret makes every process sign cursors with a random key of its own.
+	viper.SetDefault(PaginationCursorSecret, "")
+}
+
+// Purge
+
+func SetDefaultPurgeConfig() {
+	viper.SetDefault(PurgeRetention, 30*24*time.Hour)
+	viper.SetDefault(PurgeInterval, time.Hour)
+	viper.SetDefault(PurgeBatchSize, 1000)
+}
+
+// Outbox
+
+func SetDefaultOutboxConfig() {
+	// Empty URL publishes events to registered webhooks only.
+	viper.SetDefault(OutboxWebhookURL, "")
+	viper.SetDefault(OutboxWebhookTimeout, 5*time.Second)
+	viper.SetDefault(OutboxPollInterval, time.Second)
+	viper.SetDefault(OutboxBatchSize, 100)
+	viper.SetDefault(OutboxLease, time.Minute)
+	viper.SetDefault(OutboxMaxBackoff, 10*time.Minute)
+}
+
+// Webhooks
+
+func SetDefaultWebhookConfig() {
+	viper.SetDefault(WebhookPollInterval, time.Second)
+	viper.SetDefault(WebhookBatchSize, 50)
+	viper.SetDefault(WebhookLease, time.Minute)
+	viper.SetDefault(WebhookTimeout, 10*time.Second)
+	viper.SetDefault(WebhookMinBackoff, 10*time.Second)
+	viper.SetDefault(WebhookMaxBackoff, time.Hour)
+	viper.SetDefault(WebhookMaxAttempts, 10)
+	viper.SetDefault(WebhookAllowPrivateNetworks, false)
+}
+
+// Cache
+
+func SetDefaultCacheConfig() {
+	// One of none, memory or redis.
+	viper.SetDefault(CacheBackend, "memory")
+	viper.SetDefault(CacheTTL, time.Minute)
+	viper.SetDefault(CacheSize, 10000)
+	viper.SetDefault(CacheRedisAddr, "localhost:6379")
+	viper.SetDefault(CacheRedisPassword, "")
+	viper.SetDefault(CacheRedisDB, 0)
+	viper.SetDefault(CacheRedisPoolSize, 10)
+	viper.SetDefault(CacheRedisTimeout, 200*time.Millisecond)
+}
+
+// Idempotency
+
+func SetDefaultIdempotencyConfig() {
+	viper.SetDefault(IdempotencyTTL, 24*time.Hour)
+	viper.SetDefault(IdempotencyLockTimeout, time.Minute)
+	viper.SetDefault(IdempotencySweepInterval, time.Hour)
+	viper.SetDefault(IdempotencySweepBatchSize, 1000)
+}
+
+// Rate limiting
+
+func SetDefaultRateLimitConfig() {
+	// One of memory (per instance) or postgres (shared by instances).
+	viper.SetDefault(RateLimitStore, "memory")
+	// No rules, no limits.
+	viper.SetDefault(RateLimitRules, []map[string]any{})
+	viper.SetDefault(RateLimitAPIKeyHeader, "X-API-Key")
+	viper.SetDefault(RateLimitTrustedProxies, 0)
+	// Must exceed the time the slowest rule takes to refill its bucket.
+	viper.SetDefault(RateLimitIdleTimeout, time.Hour)
+	viper.SetDefault(RateLimitSweepInterval, 10*time.Minute)
+}
+
+// Tracing
+
+func SetDefaultTracingConfig() {
+	// One of none, stdout, file or otlp.
+	viper.SetDefault(TracingExporter, "none")
+	viper.SetDefault(TracingServiceName, "persons-api")
+	// The share of traces started by this service that are recorded.
+	viper.SetDefault(TracingSampleRatio, 1.0)
+	viper.SetDefault(TracingFile, "traces.jsonl")
+	viper.SetDefault(TracingOTLPEndpoint, "http://localhost:4318/v1/traces")
+	viper.SetDefault(TracingOTLPTimeout, 10*time.Second)
+}
+
+// Stream
+
+func SetDefaultStreamConfig() {
+	viper.SetDefault(StreamReplaySize, 1000)
+}
+
 // Postgres
 
 func SetDefaultPostgresConfig() {
@@ -13,6 +120,21 @@ func SetDefaultPostgresConfig() {
 	viper.SetDefault(PostgresUser, "moderator")
 	viper.SetDefault(PostgresPassword, "2222")
 	viper.SetDefault(PostgresSSLMode, "disable")
+	viper.SetDefault(PostgresDriver, "pgx")
+
+	viper.SetDefault(PostgresMigrateOnStart, true)
+
+	viper.SetDefault(PostgresHealthCheckTimeout, 2*time.Second)
+	viper.SetDefault(PostgresCreateTimeout, 3*time.Second)
+	viper.SetDefault(PostgresGetTimeout, 2*time.Second)
+	viper.SetDefault(PostgresListTimeout, 10*time.Second)
+	viper.SetDefault(PostgresSearchTimeout, 5*time.Second)
+	viper.SetDefault(PostgresUpdateTimeout, 3*time.Second)
+	viper.SetDefault(PostgresDeleteTimeout, 3*time.Second)
+	viper.SetDefault(PostgresBatchTimeout, 30*time.Second)
+	viper.SetDefault(PostgresExportTimeout, 10*time.Minute)
+	viper.SetDefault(PostgresPurgeTimeout, 30*time.Second)
+	viper.SetDefault(PostgresOutboxTimeout, 3*time.Second)
 }
 
 func SetTestPostgresConfig() {
@@ -22,4 +144,19 @@ func SetTestPostgresConfig() {
 	viper.SetDefault(PostgresUser, "moderator")
 	viper.SetDefault(PostgresPassword, "2222")
 	viper.SetDefault(PostgresSSLMode, "disable")
+	viper.SetDefault(PostgresDriver, "pgx")
+
+	viper.SetDefault(PostgresMigrateOnStart, true)
+
+	viper.SetDefault(PostgresHealthCheckTimeout, 2*time.Second)
+	viper.SetDefault(PostgresCreateTimeout, 3*time.Second)
+	viper.SetDefault(PostgresGetTimeout, 2*time.Second)
+	viper.SetDefault(PostgresListTimeout, 10*time.Second)
+	viper.SetDefault(PostgresSearchTimeout, 5*time.Second)
+	viper.SetDefault(PostgresUpdateTimeout, 3*time.Second)
+	viper.SetDefault(PostgresDeleteTimeout, 3*time.Second)
+	viper.SetDefault(PostgresBatchTimeout, 30*time.Second)
+	viper.SetDefault(PostgresExportTimeout, 10*time.Minute)
+	viper.SetDefault(PostgresPurgeTimeout, 30*time.Second)
+	viper.SetDefault(PostgresOutboxTimeout, 3*time.Second)
 }
@@ -2,7 +2,88 @@ package config
 
 // Server
 const (
-	ServerPort = "PORT"
+	ServerPort            = "PORT"
+	ServerShutdownTimeout = "SHUTDOWN_TIMEOUT"
+	ServerAdminToken      = "ADMIN_TOKEN"
+)
+
+// Pagination
+const (
+	PaginationCursorSecret = "CURSOR_SECRET"
+)
+
+// Purge
+const (
+	PurgeRetention = "PURGE_RETENTION"
+	PurgeInterval  = "PURGE_INTERVAL"
+	PurgeBatchSize = "PURGE_BATCH_SIZE"
+)
+
+// Outbox
+const (
+	OutboxWebhookURL     = "OUTBOX_WEBHOOK_URL"
+	OutboxWebhookTimeout = "OUTBOX_WEBHOOK_TIMEOUT"
+	OutboxPollInterval   = "OUTBOX_POLL_INTERVAL"
+	OutboxBatchSize      = "OUTBOX_BATCH_SIZE"
+	OutboxLease          = "OUTBOX_LEASE"
+	OutboxMaxBackoff     = "OUTBOX_MAX_BACKOFF"
+)
+
+// Webhooks
+const (
+	WebhookPollInterval         = "WEBHOOK_POLL_INTERVAL"
+	WebhookBatchSize            = "WEBHOOK_BATCH_SIZE"
+	WebhookLease                = "WEBHOOK_LEASE"
+	WebhookTimeout              = "WEBHOOK_TIMEOUT"
+	WebhookMinBackoff           = "WEBHOOK_MIN_BACKOFF"
+	WebhookMaxBackoff           = "WEBHOOK_MAX_BACKOFF"
+	WebhookMaxAttempts          = "WEBHOOK_MAX_ATTEMPTS"
+	WebhookAllowPrivateNetworks = "WEBHOOK_ALLOW_PRIVATE_NETWORKS"
+)
+
+// Cache
+const (
+	CacheBackend       = "CACHE_BACKEND"
+	CacheTTL           = "CACHE_TTL"
+	CacheSize          = "CACHE_SIZE"
+	CacheRedisAddr     = "CACHE_REDIS_ADDR"
+	CacheRedisPassword = "CACHE_REDIS_PASSWORD"
+	CacheRedisDB       = "CACHE_REDIS_DB"
+	CacheRedisPoolSize = "CACHE_REDIS_POOL_SIZE"
+	CacheRedisTimeout  = "CACHE_REDIS_TIMEOUT"
+)
+
+// Idempotency
+const (
+	IdempotencyTTL            = "IDEMPOTENCY_TTL"
+	IdempotencyLockTimeout    = "IDEMPOTENCY_LOCK_TIMEOUT"
+	IdempotencySweepInterval  = "IDEMPOTENCY_SWEEP_INTERVAL"
+	IdempotencySweepBatchSize = "IDEMPOTENCY_SWEEP_BATCH_SIZE"
+)
+
+// Rate limiting
+const (
+	RateLimitStore          = "RATE_LIMIT_STORE"
+	RateLimitRules          = "RATE_LIMIT_RULES"
+	RateLimitAPIKeyHeader   = "RATE_LIMIT_API_KEY_HEADER"
+	RateLimitTrustedProxies = "RATE_LIMIT_TRUSTED_PROXIES"
+	RateLimitIdleTimeout    = "RATE_LIMIT_IDLE_TIMEOUT"
+	RateLimitSweepInterval  = "RATE_LIMIT_SWEEP_INTERVAL"
+)
+
+// Tracing
+const (
+	TracingExporter     = "TRACING_EXPORTER"
+	TracingServiceName  = "TRACING_SERVICE_NAME"
+	TracingSampleRatio  = "TRACING_SAMPLE_RATIO"
+	TracingFile         = "TRACING_FILE"
+	TracingOTLPEndpoint = "TRACING_OTLP_ENDPOINT"
+	TracingOTLPTimeout  = "TRACING_OTLP_TIMEOUT"
+)
+
+// Stream
+const (
+	StreamReplaySize = "STREAM_REPLAY_SIZE"
 )
 
 // Postgres
@@ -13,4 +94,22 @@ const (
 	PostgresUser     = "PG_USER"
 	PostgresPassword = "PG_PASSWORD"
 	PostgresSSLMode  = "PG_SSL_MODE"
+	PostgresDriver   = "PG_DRIVER"
+
+	PostgresMigrateOnStart = "PG_MIGRATE_ON_START"
+)
+
+// Postgres query timeouts
+const (
+	PostgresHealthCheckTimeout = "PG_HEALTH_CHECK_TIMEOUT"
+	PostgresCreateTimeout      = "PG_CREATE_TIMEOUT"
+	PostgresGetTimeout         = "PG_GET_TIMEOUT"
+	PostgresListTimeout        = "PG_LIST_TIMEOUT"
+	PostgresSearchTimeout      = "PG_SEARCH_TIMEOUT"
+	PostgresUpdateTimeout      = "PG_UPDATE_TIMEOUT"
+	PostgresDeleteTimeout      = "PG_DELETE_TIMEOUT"
+	PostgresBatchTimeout       = "PG_BATCH_TIMEOUT"
+	PostgresExportTimeout      = "PG_EXPORT_TIMEOUT"
+	PostgresPurgeTimeout       = "PG_PURGE_TIMEOUT"
+	PostgresOutboxTimeout      = "PG_OUTBOX_TIMEOUT"
 )
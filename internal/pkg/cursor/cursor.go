@@ -0,0 +1,81 @@
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+// Codec turns pagination state into opaque tokens signed with HMAC-SHA256, so clients can neither read nor forge them.
+// Each token is bound to the purpose it was encoded for, a token of one listing is rejected by another.
+type Codec struct {
+	secret []byte
+}
+
+// secretSize is the size of random secrets, the block size of SHA-256.
+const secretSize = 64
+
+// NewRandomSecret returns a random secret for a process that is not given one.
+func NewRandomSecret() []byte {
+	secret := make([]byte, secretSize)
+	_, _ = rand.Read(secret)
+	return secret
+}
+
+func NewCodec(secret []byte) *Codec {
+	return &Codec{
+		secret: secret,
+	}
+}
+
+func (c *Codec) Encode(purpose string, value any) (string, error) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(c.sign(purpose, payload)), nil
+}
+
+func (c *Codec) Decode(purpose, token string, value any) error {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return pErrors.ErrInvalidCursor
+	}
+
+	encoding := base64.RawURLEncoding
+	payload, err := encoding.DecodeString(encodedPayload)
+	if err != nil {
+		return errors.Wrap(pErrors.ErrInvalidCursor, err.Error())
+	}
+	signature, err := encoding.DecodeString(encodedSignature)
+	if err != nil {
+		return errors.Wrap(pErrors.ErrInvalidCursor, err.Error())
+	}
+	if !hmac.Equal(signature, c.sign(purpose, payload)) {
+		return errors.Wrap(pErrors.ErrInvalidCursor, "signature mismatch")
+	}
+
+	err = json.Unmarshal(payload, value)
+	if err != nil {
+		return errors.Wrap(pErrors.ErrInvalidCursor, err.Error())
+	}
+	return nil
+}
+
+// sign signs the purpose along with the payload, it ends with a zero byte so that it can not run into the payload.
+func (c *Codec) sign(purpose string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,89 @@
+package cursor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+const purpose = "test.page"
+
+type position struct {
+	ID       int64 `json:"id"`
+	Backward bool  `json:"backward,omitempty"`
+}
+
+func TestDecode(t *testing.T) {
+	type testCase struct {
+		token    func(c *Codec) string
+		position position
+		err      error
+	}
+
+	tests := map[string]testCase{
+		"round trip": {
+			token: func(c *Codec) string {
+				token, _ := c.Encode(purpose, position{ID: 42, Backward: true})
+				return token
+			},
+			position: position{ID: 42, Backward: true},
+			err:      nil,
+		},
+		"foreign secret": {
+			token: func(c *Codec) string {
+				token, _ := NewCodec([]byte("other")).Encode(purpose, position{ID: 42})
+				return token
+			},
+			err: pErrors.ErrInvalidCursor,
+		},
+		"random secret of another process": {
+			token: func(c *Codec) string {
+				token, _ := NewCodec(NewRandomSecret()).Encode(purpose, position{ID: 42})
+				return token
+			},
+			err: pErrors.ErrInvalidCursor,
+		},
+		"other purpose": {
+			token: func(c *Codec) string {
+				token, _ := c.Encode("test.history", position{ID: 42})
+				return token
+			},
+			err: pErrors.ErrInvalidCursor,
+		},
+		"tampered payload": {
+			token: func(c *Codec) string {
+				token, _ := c.Encode(purpose, position{ID: 42})
+				forged, _ := c.Encode(purpose, position{ID: 1})
+				return strings.Split(forged, ".")[0] + "." + strings.Split(token, ".")[1]
+			},
+			err: pErrors.ErrInvalidCursor,
+		},
+		"garbage": {
+			token: func(c *Codec) string {
+				return "not-a-cursor"
+			},
+			err: pErrors.ErrInvalidCursor,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewCodec([]byte("secret"))
+
+			var got position
+			err := c.Decode(purpose, test.token(c), &got)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if err == nil && got != test.position {
+				t.Errorf("\nExpected: %v\nGot: %v", test.position, got)
+			}
+		})
+	}
+}
@@ -6,12 +6,39 @@ import (
 
 var (
 	// Common repository
-	ErrDb = errors.New("db error")
+	ErrDb               = errors.New("db error")
+	ErrDeadlineExceeded = errors.New("db query deadline exceeded")
+	ErrRequestCanceled  = errors.New("request canceled")
 
 	// Persons
 	ErrPersonNotFound      = errors.New("person not found")
 	ErrPersonAlreadyExists = errors.New("person already exists")
+	ErrVersionMismatch     = errors.New("person version mismatch")
+	ErrBatchAborted        = errors.New("batch aborted")
+	ErrBatchTooLarge       = errors.New("batch too large")
+
+	// Webhooks
+	ErrWebhookNotFound = errors.New("webhook not found")
 
 	// HTTP
-	ErrReadBody = errors.New("read request body error")
+	ErrReadBody      = errors.New("read request body error")
+	ErrBadQueryParam = errors.New("bad query parameter")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrForbidden     = errors.New("forbidden")
+
+	// Rate limiting
+	ErrTooManyRequests = errors.New("too many requests")
+
+	// Idempotency
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key reused with another request")
+	ErrIdempotencyKeyInUse   = errors.New("request with the same idempotency key is in progress")
+
+	// Patch
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrInvalidPatch         = errors.New("invalid patch document")
+	ErrPatchNotApplicable   = errors.New("patch can not be applied")
+
+	// Validation
+	ErrInvalidData = errors.New("invalid data")
 )
@@ -2,16 +2,46 @@ package errors
 
 import "net/http"
 
+// StatusClientClosedRequest is the non-standard status used when the client went away before the response was ready.
+const StatusClientClosedRequest = 499
+
 var httpCodes = map[error]int{
 	// Common repository
-	ErrDb: http.StatusInternalServerError,
+	ErrDb:               http.StatusInternalServerError,
+	ErrDeadlineExceeded: http.StatusGatewayTimeout,
+	ErrRequestCanceled:  StatusClientClosedRequest,
 
 	// Users
 	ErrPersonNotFound:      http.StatusNotFound,
 	ErrPersonAlreadyExists: http.StatusConflict,
+	ErrVersionMismatch:     http.StatusPreconditionFailed,
+	ErrBatchAborted:        http.StatusFailedDependency,
+	ErrBatchTooLarge:       http.StatusRequestEntityTooLarge,
+
+	// Webhooks
+	ErrWebhookNotFound: http.StatusNotFound,
 
 	// HTTP
-	ErrReadBody: http.StatusBadRequest,
+	ErrReadBody:      http.StatusBadRequest,
+	ErrBadQueryParam: http.StatusBadRequest,
+	ErrInvalidCursor: http.StatusBadRequest,
+	ErrForbidden:     http.StatusForbidden,
+
+	// Rate limiting
+	ErrTooManyRequests: http.StatusTooManyRequests,
+
+	// Idempotency
+	ErrInvalidIdempotencyKey: http.StatusBadRequest,
+	ErrIdempotencyKeyReused:  http.StatusUnprocessableEntity,
+	ErrIdempotencyKeyInUse:   http.StatusConflict,
+
+	// Patch
+	ErrUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	ErrInvalidPatch:         http.StatusBadRequest,
+	ErrPatchNotApplicable:   http.StatusUnprocessableEntity,
+
+	// Validation
+	ErrInvalidData: http.StatusBadRequest,
 }
 
 func GetHTTPCodeByError(err error) (int, bool) {
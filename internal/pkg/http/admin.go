@@ -0,0 +1,19 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+const authorizationHeader = "Authorization"
+
+// IsAdmin reports whether r carries token as its bearer token. Admin access is disabled if token is empty.
+func IsAdmin(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	bearer, ok := strings.CutPrefix(r.Header.Get(authorizationHeader), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
 	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"io"
@@ -30,10 +31,24 @@ type JSONError struct {
 	Error string `json:"error"`
 }
 
+type ValidationErrorResponse struct {
+	Message string            `json:"message"`
+	Errors  map[string]string `json:"errors"`
+}
+
 func HandleError(w http.ResponseWriter, r *http.Request, err error) {
 	errCause := errors.Cause(err)
 	httpCode, _ := pErrors.GetHTTPCodeByError(errCause)
 
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		SendJSON(w, r, httpCode, ValidationErrorResponse{
+			Message: errCause.Error(),
+			Errors:  validationErrs,
+		})
+		return
+	}
+
 	jsonError := JSONError{
 		Error: errCause.Error(),
 	}
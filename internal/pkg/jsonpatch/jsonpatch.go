@@ -0,0 +1,278 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+const (
+	// MergePatchMediaType is the content type of RFC 7396 JSON Merge Patch documents.
+	MergePatchMediaType = "application/merge-patch+json"
+	// JSONPatchMediaType is the content type of RFC 6902 JSON Patch documents.
+	JSONPatchMediaType = "application/json-patch+json"
+)
+
+const (
+	opAdd     = "add"
+	opRemove  = "remove"
+	opReplace = "replace"
+	opTest    = "test"
+)
+
+type Operation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// Value is nil if the operation has no value member, a JSON null is kept as "null".
+	Value json.RawMessage `json:"value"`
+}
+
+// MergePatch applies an RFC 7396 merge patch to doc: members of patch objects replace members of doc objects,
+// null members remove them and any other patch value replaces the target as a whole.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, patchValue any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, errors.Wrap(pErrors.ErrInvalidPatch, err.Error())
+	}
+
+	return json.Marshal(mergePatch(target, patchValue))
+}
+
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any, len(patchObject))
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// Apply applies an RFC 6902 patch to doc. Only add, remove, replace and test operations are supported.
+// Operations are applied in order and the patch fails as a whole if any of them fails.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	var operations []Operation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, errors.Wrap(pErrors.ErrInvalidPatch, err.Error())
+	}
+
+	for i, operation := range operations {
+		var err error
+		target, err = apply(target, operation)
+		if err != nil {
+			return nil, errors.Wrapf(err, "operation %d", i)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func apply(doc any, operation Operation) (any, error) {
+	tokens, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch operation.Op {
+	case opAdd, opReplace, opTest:
+		if operation.Value == nil {
+			return nil, errors.Wrapf(pErrors.ErrInvalidPatch, "%s operation has no value", operation.Op)
+		}
+		if err = json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, errors.Wrap(pErrors.ErrInvalidPatch, err.Error())
+		}
+	case opRemove:
+	default:
+		return nil, errors.Wrapf(pErrors.ErrInvalidPatch, "unsupported operation %q", operation.Op)
+	}
+
+	switch operation.Op {
+	case opAdd:
+		if len(tokens) == 0 {
+			return value, nil
+		}
+		return update(doc, tokens, func(container any, token string) (any, error) {
+			return add(container, token, value)
+		})
+	case opRemove:
+		if len(tokens) == 0 {
+			return nil, errors.Wrap(pErrors.ErrPatchNotApplicable, "the whole document can not be removed")
+		}
+		return update(doc, tokens, remove)
+	case opReplace:
+		if len(tokens) == 0 {
+			return value, nil
+		}
+		return update(doc, tokens, func(container any, token string) (any, error) {
+			if _, err := child(container, token); err != nil {
+				return nil, err
+			}
+			return set(container, token, value)
+		})
+	default:
+		current, err := lookup(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, errors.Wrapf(pErrors.ErrPatchNotApplicable, "test of %s failed", operation.Path)
+		}
+		return doc, nil
+	}
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Wrapf(pErrors.ErrInvalidPatch, "path %q does not start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// update walks tokens down from node and lets fn change the container that holds the last token.
+// Containers on the way are rebuilt, since adding to or removing from an array makes a new slice.
+func update(node any, tokens []string, fn func(container any, token string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+
+	next, err := child(node, tokens[0])
+	if err != nil {
+		return nil, err
+	}
+	next, err = update(next, tokens[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	return set(node, tokens[0], next)
+}
+
+func lookup(node any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		var err error
+		node, err = child(node, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func child(node any, token string) (any, error) {
+	switch container := node.(type) {
+	case map[string]any:
+		value, ok := container[token]
+		if !ok {
+			return nil, errors.Wrapf(pErrors.ErrPatchNotApplicable, "member %q does not exist", token)
+		}
+		return value, nil
+	case []any:
+		i, err := index(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		return container[i], nil
+	default:
+		return nil, errors.Wrapf(pErrors.ErrPatchNotApplicable, "%q refers into a scalar value", token)
+	}
+}
+
+func set(node any, token string, value any) (any, error) {
+	switch container := node.(type) {
+	case map[string]any:
+		container[token] = value
+		return container, nil
+	case []any:
+		i, err := index(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		container[i] = value
+		return container, nil
+	default:
+		return nil, errors.Wrapf(pErrors.ErrPatchNotApplicable, "%q refers into a scalar value", token)
+	}
+}
+
+func add(node any, token string, value any) (any, error) {
+	container, ok := node.([]any)
+	if !ok {
+		return set(node, token, value)
+	}
+
+	if token == "-" {
+		return append(container, value), nil
+	}
+	i, err := index(token, len(container))
+	if err != nil {
+		return nil, err
+	}
+	container = append(container, nil)
+	copy(container[i+1:], container[i:])
+	container[i] = value
+	return container, nil
+}
+
+func remove(node any, token string) (any, error) {
+	switch container := node.(type) {
+	case map[string]any:
+		if _, ok := container[token]; !ok {
+			return nil, errors.Wrapf(pErrors.ErrPatchNotApplicable, "member %q does not exist", token)
+		}
+		delete(container, token)
+		return container, nil
+	case []any:
+		i, err := index(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		return append(container[:i], container[i+1:]...), nil
+	default:
+		return nil, errors.Wrapf(pErrors.ErrPatchNotApplicable, "%q refers into a scalar value", token)
+	}
+}
+
+// index parses an array index token, which must be a decimal number without leading zeros not greater than last.
+func index(token string, last int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.Wrapf(pErrors.ErrInvalidPatch, "bad array index %q", token)
+	}
+	if i > last {
+		return 0, errors.Wrapf(pErrors.ErrPatchNotApplicable, "array index %d is out of range", i)
+	}
+	return i, nil
+}
@@ -0,0 +1,157 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+const person = `{"id": 1, "name": "Johnny", "age": 22, "address": "Moscow", "work": "Yandex"}`
+
+func equalJSON(t *testing.T, expected string, got []byte) {
+	t.Helper()
+
+	var expectedValue, gotValue any
+	if err := json.Unmarshal([]byte(expected), &expectedValue); err != nil {
+		t.Fatalf("bad expected document: %s", err)
+	}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("bad patched document: %s", err)
+	}
+	if !reflect.DeepEqual(expectedValue, gotValue) {
+		t.Errorf("\nExpected: %s\nGot: %s", expected, got)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	type testCase struct {
+		doc    string
+		patch  string
+		result string
+		err    error
+	}
+
+	tests := map[string]testCase{
+		"replace and remove members": {
+			doc:    person,
+			patch:  `{"name": "Den", "address": null, "salary": null}`,
+			result: `{"id": 1, "name": "Den", "age": 22, "work": "Yandex"}`,
+		},
+		"nested objects": {
+			doc:    `{"a": {"b": "c", "d": "e"}, "f": [1, 2]}`,
+			patch:  `{"a": {"b": null, "x": {"y": 1}}, "f": [3]}`,
+			result: `{"a": {"d": "e", "x": {"y": 1}}, "f": [3]}`,
+		},
+		"non-object patch replaces document": {
+			doc:    person,
+			patch:  `["a"]`,
+			result: `["a"]`,
+		},
+		"malformed patch": {
+			doc:   person,
+			patch: `{"name": `,
+			err:   pErrors.ErrInvalidPatch,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := MergePatch([]byte(test.doc), []byte(test.patch))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if err == nil {
+				equalJSON(t, test.result, result)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	type testCase struct {
+		doc    string
+		patch  string
+		result string
+		err    error
+	}
+
+	tests := map[string]testCase{
+		"person operations": {
+			doc: person,
+			patch: `[
+				{"op": "test", "path": "/name", "value": "Johnny"},
+				{"op": "replace", "path": "/name", "value": "Den"},
+				{"op": "remove", "path": "/address"},
+				{"op": "add", "path": "/work", "value": "VK"}
+			]`,
+			result: `{"id": 1, "name": "Den", "age": 22, "work": "VK"}`,
+		},
+		"arrays and escaped pointers": {
+			doc: `{"a/b": {"m~n": [1, 3]}}`,
+			patch: `[
+				{"op": "add", "path": "/a~1b/m~0n/1", "value": 2},
+				{"op": "add", "path": "/a~1b/m~0n/-", "value": 4},
+				{"op": "remove", "path": "/a~1b/m~0n/0"},
+				{"op": "test", "path": "/a~1b/m~0n", "value": [2, 3, 4]}
+			]`,
+			result: `{"a/b": {"m~n": [2, 3, 4]}}`,
+		},
+		"null value": {
+			doc:    person,
+			patch:  `[{"op": "replace", "path": "/work", "value": null}]`,
+			result: `{"id": 1, "name": "Johnny", "age": 22, "address": "Moscow", "work": null}`,
+		},
+		"failed test": {
+			doc:   person,
+			patch: `[{"op": "test", "path": "/age", "value": 23}, {"op": "remove", "path": "/age"}]`,
+			err:   pErrors.ErrPatchNotApplicable,
+		},
+		"replace missing member": {
+			doc:   person,
+			patch: `[{"op": "replace", "path": "/salary", "value": 100}]`,
+			err:   pErrors.ErrPatchNotApplicable,
+		},
+		"index out of range": {
+			doc:   `[1, 2]`,
+			patch: `[{"op": "add", "path": "/3", "value": 4}]`,
+			err:   pErrors.ErrPatchNotApplicable,
+		},
+		"unsupported operation": {
+			doc:   person,
+			patch: `[{"op": "move", "from": "/name", "path": "/work"}]`,
+			err:   pErrors.ErrInvalidPatch,
+		},
+		"missing value": {
+			doc:   person,
+			patch: `[{"op": "add", "path": "/work"}]`,
+			err:   pErrors.ErrInvalidPatch,
+		},
+		"bad path": {
+			doc:   person,
+			patch: `[{"op": "remove", "path": "name"}]`,
+			err:   pErrors.ErrInvalidPatch,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := Apply([]byte(test.doc), []byte(test.patch))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if err == nil {
+				equalJSON(t, test.result, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package lifecycle
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type closer struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Lifecycle runs an HTTP server until a termination signal arrives, then drains in-flight requests
+// and releases registered resources in registration order.
+type Lifecycle struct {
+	server          *http.Server
+	log             *zap.Logger
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+	closers         []closer
+}
+
+func New(server *http.Server, shutdownTimeout time.Duration, log *zap.Logger) *Lifecycle {
+	return &Lifecycle{
+		server:          server,
+		log:             log,
+		shutdownTimeout: shutdownTimeout,
+		signals:         []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+}
+
+// OnStop registers fn to be called after the server has stopped accepting requests.
+func (lc *Lifecycle) OnStop(name string, fn func(ctx context.Context) error) {
+	lc.closers = append(lc.closers, closer{name: name, fn: fn})
+}
+
+// Run listens on the server address and blocks until the server is shut down.
+func (lc *Lifecycle) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", lc.server.Addr)
+	if err != nil {
+		lc.log.Error("Failed to listen", zap.String("addr", lc.server.Addr), zap.Error(err))
+		lc.stop()
+		return err
+	}
+	return lc.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is done, a termination signal arrives or the server fails.
+func (lc *Lifecycle) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, stopNotify := signal.NotifyContext(ctx, lc.signals...)
+	defer stopNotify()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- lc.server.Serve(ln)
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		lc.log.Error("API server stopped", zap.Error(err))
+	case <-ctx.Done():
+		lc.log.Info("Shutting down API server...", zap.Duration("timeout", lc.shutdownTimeout))
+		err = lc.shutdown()
+	}
+
+	lc.stop()
+	return err
+}
+
+func (lc *Lifecycle) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), lc.shutdownTimeout)
+	defer cancel()
+
+	err := lc.server.Shutdown(ctx)
+	if err != nil {
+		lc.log.Error("Failed to shut down API server gracefully", zap.Error(err))
+		return errors.Wrap(err, "shutdown")
+	}
+
+	lc.log.Info("API server stopped")
+	return nil
+}
+
+func (lc *Lifecycle) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), lc.shutdownTimeout)
+	defer cancel()
+
+	for _, c := range lc.closers {
+		if err := c.fn(ctx); err != nil {
+			lc.log.Error("Failed to stop", zap.String("resource", c.name), zap.Error(err))
+		}
+	}
+}
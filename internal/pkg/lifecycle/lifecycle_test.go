@@ -0,0 +1,115 @@
+package lifecycle
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+func init() {
+	var err error
+	logger, err = zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	type testCase struct {
+		shutdownTimeout time.Duration
+		handlerDelay    time.Duration
+		status          int
+		err             error
+	}
+
+	tests := map[string]testCase{
+		"drains in-flight request": {
+			shutdownTimeout: 5 * time.Second,
+			handlerDelay:    100 * time.Millisecond,
+			status:          http.StatusOK,
+			err:             nil,
+		},
+		"shutdown timeout exceeded": {
+			shutdownTimeout: 50 * time.Millisecond,
+			handlerDelay:    500 * time.Millisecond,
+			status:          http.StatusOK,
+			err:             context.DeadlineExceeded,
+		},
+	}
+
+	// Subtests are not parallel: the signal is delivered to the whole process.
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			started := make(chan struct{})
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				time.Sleep(test.handlerDelay)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("can't listen: %s", err)
+			}
+
+			var stopped []string
+			lc := New(&http.Server{Handler: handler}, test.shutdownTimeout, logger)
+			lc.OnStop("db", func(ctx context.Context) error {
+				stopped = append(stopped, "db")
+				return nil
+			})
+			lc.OnStop("logger", func(ctx context.Context) error {
+				stopped = append(stopped, "logger")
+				return nil
+			})
+
+			serveErr := make(chan error, 1)
+			go func() {
+				serveErr <- lc.Serve(context.Background(), ln)
+			}()
+
+			status := make(chan int, 1)
+			go func() {
+				resp, err := http.Get("http://" + ln.Addr().String())
+				if err != nil {
+					status <- 0
+					return
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+				status <- resp.StatusCode
+			}()
+
+			<-started
+			if err = syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+				t.Fatalf("can't send signal: %s", err)
+			}
+
+			select {
+			case err = <-serveErr:
+			case <-time.After(5 * time.Second):
+				t.Fatal("server did not stop")
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.err, err)
+			}
+			if got := <-status; test.err == nil && got != test.status {
+				t.Errorf("\nExpected: %d\nGot: %d", test.status, got)
+			}
+			if expected := []string{"db", "logger"}; !reflect.DeepEqual(stopped, expected) {
+				t.Errorf("\nExpected: %v\nGot: %v", expected, stopped)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"database/sql"
+	"runtime"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type goCollector struct{}
+
+// NewGoCollector reports the goroutines, the memory and the garbage collections of the process.
+func NewGoCollector() Collector {
+	return goCollector{}
+}
+
+func (goCollector) Collect() []Family {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return []Family{
+		gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())),
+		{
+			Name: "go_info",
+			Help: "Information about the Go environment.",
+			Type: TypeGauge,
+			Samples: []Sample{{
+				Name:   "go_info",
+				Labels: []Label{{Name: "version", Value: runtime.Version()}},
+				Value:  1,
+			}},
+		},
+		gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(stats.Alloc)),
+		counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.",
+			float64(stats.TotalAlloc)),
+		gauge("go_memstats_sys_bytes", "Number of bytes obtained from the system.", float64(stats.Sys)),
+		counter("go_memstats_mallocs_total", "Total number of mallocs.", float64(stats.Mallocs)),
+		counter("go_memstats_frees_total", "Total number of frees.", float64(stats.Frees)),
+		gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(stats.HeapInuse)),
+		gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(stats.HeapObjects)),
+		gauge("go_memstats_stack_inuse_bytes", "Number of bytes in use by the stack allocator.",
+			float64(stats.StackInuse)),
+		gauge("go_memstats_next_gc_bytes", "Number of heap bytes when the next garbage collection will take place.",
+			float64(stats.NextGC)),
+		gauge("go_memstats_last_gc_time_seconds", "Number of seconds since 1970 of the last garbage collection.",
+			float64(stats.LastGC)/1e9),
+		counter("go_gc_cycles_total", "Number of completed garbage collection cycles.", float64(stats.NumGC)),
+		counter("go_gc_pause_seconds_total", "Total time the world was stopped by garbage collections.",
+			float64(stats.PauseTotalNs)/1e9),
+	}
+}
+
+type dbStatsCollector struct {
+	db     *sql.DB
+	labels []Label
+}
+
+// NewDBStatsCollector reports the connection pool of db, labeled with its name.
+func NewDBStatsCollector(db *sql.DB, name string) Collector {
+	return &dbStatsCollector{db: db, labels: []Label{{Name: "db_name", Value: name}}}
+}
+
+func (c *dbStatsCollector) Collect() []Family {
+	stats := c.db.Stats()
+
+	families := []Family{
+		gauge("go_sql_max_open_connections", "Maximum number of open connections to the database.",
+			float64(stats.MaxOpenConnections)),
+		gauge("go_sql_open_connections", "The number of established connections both in use and idle.",
+			float64(stats.OpenConnections)),
+		gauge("go_sql_in_use_connections", "The number of connections currently in use.", float64(stats.InUse)),
+		gauge("go_sql_idle_connections", "The number of idle connections.", float64(stats.Idle)),
+		counter("go_sql_wait_count_total", "The total number of connections waited for.", float64(stats.WaitCount)),
+		counter("go_sql_wait_duration_seconds_total", "The total time blocked waiting for a new connection.",
+			stats.WaitDuration.Seconds()),
+		counter("go_sql_max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns.",
+			float64(stats.MaxIdleClosed)),
+		counter("go_sql_max_idle_time_closed_total",
+			"The total number of connections closed due to SetConnMaxIdleTime.", float64(stats.MaxIdleTimeClosed)),
+		counter("go_sql_max_lifetime_closed_total",
+			"The total number of connections closed due to SetConnMaxLifetime.", float64(stats.MaxLifetimeClosed)),
+	}
+	for i := range families {
+		families[i].Samples[0].Labels = c.labels
+	}
+	return families
+}
+
+type pgxPoolStatsCollector struct {
+	pool   *pgxpool.Pool
+	labels []Label
+}
+
+// NewPgxPoolStatsCollector reports the connection pool of pool, labeled with its name. The go_sql stats of
+// a database opened from the pool only see the connections it borrowed.
+func NewPgxPoolStatsCollector(pool *pgxpool.Pool, name string) Collector {
+	return &pgxPoolStatsCollector{pool: pool, labels: []Label{{Name: "db_name", Value: name}}}
+}
+
+func (c *pgxPoolStatsCollector) Collect() []Family {
+	stats := c.pool.Stat()
+
+	families := []Family{
+		gauge("pgxpool_max_conns", "Maximum number of connections in the pool.", float64(stats.MaxConns())),
+		gauge("pgxpool_total_conns", "The number of connections in the pool, acquired, idle or being established.",
+			float64(stats.TotalConns())),
+		gauge("pgxpool_acquired_conns", "The number of connections currently acquired.",
+			float64(stats.AcquiredConns())),
+		gauge("pgxpool_idle_conns", "The number of idle connections.", float64(stats.IdleConns())),
+		counter("pgxpool_acquire_count_total", "The total number of connections acquired.",
+			float64(stats.AcquireCount())),
+		counter("pgxpool_acquire_duration_seconds_total", "The total time spent acquiring connections.",
+			stats.AcquireDuration().Seconds()),
+		counter("pgxpool_empty_acquire_count_total",
+			"The total number of acquires that waited for a connection because the pool was empty.",
+			float64(stats.EmptyAcquireCount())),
+		counter("pgxpool_canceled_acquire_count_total",
+			"The total number of acquires canceled by their context.", float64(stats.CanceledAcquireCount())),
+	}
+	for i := range families {
+		families[i].Samples[0].Labels = c.labels
+	}
+	return families
+}
+
+func gauge(name, help string, value float64) Family {
+	return Family{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Name: name, Value: value}}}
+}
+
+func counter(name, help string, value float64) Family {
+	return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Name: name, Value: value}}}
+}
@@ -0,0 +1,154 @@
+// Package metrics collects counters, gauges and histograms and serves them in the Prometheus text exposition
+// format, see https://prometheus.io/docs/instrumenting/exposition_formats/.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type Type string
+
+const (
+	TypeCounter   Type = "counter"
+	TypeGauge     Type = "gauge"
+	TypeHistogram Type = "histogram"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a family. Its name is the name of the family, histograms add the _bucket,
+// _sum and _count suffixes.
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a metric with all of its samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Collector reports the current values of one or more families. Collect is called on every scrape,
+// concurrently with updates of the values.
+type Collector interface {
+	Collect() []Family
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+	names      map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// MustRegister adds collectors to the registry. It panics if a family is registered twice, which is
+// a programming error.
+func (r *Registry) MustRegister(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range collectors {
+		for _, family := range c.Collect() {
+			if r.names[family.Name] {
+				panic(fmt.Sprintf("metrics: family %q is already registered", family.Name))
+			}
+			r.names[family.Name] = true
+		}
+		r.collectors = append(r.collectors, c)
+	}
+}
+
+// Gather returns the families of all collectors sorted by name.
+func (r *Registry) Gather() []Family {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	var families []Family
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+	slices.SortFunc(families, func(a, b Family) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return families
+}
+
+// WriteText writes the families of all collectors in the text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range r.Gather() {
+		fmt.Fprintf(bw, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			bw.WriteString(sample.Name)
+			if len(sample.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, label := range sample.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, `%s="%s"`, label.Name, escapeLabelValue(label.Value))
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(sample.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the scrapes of Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = r.WriteText(w)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
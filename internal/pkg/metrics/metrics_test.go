@@ -0,0 +1,169 @@
+package metrics_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/metrics/metricstest"
+)
+
+func scrape(t *testing.T, registry *metrics.Registry) (metricstest.Samples, metricstest.Types) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Header().Get("Content-Type") != metrics.ContentType {
+		t.Errorf("\nExpected: %s\nGot: %s", metrics.ContentType, w.Header().Get("Content-Type"))
+	}
+
+	body := w.Body.String()
+	samples, types, err := metricstest.Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %v\n%s", nil, err, body)
+	}
+	return samples, types
+}
+
+func TestCounterVec(t *testing.T) {
+	registry := metrics.NewRegistry()
+	requests := metrics.NewCounterVec("requests_total", "Requests.", "method", "path")
+	registry.MustRegister(requests)
+
+	requests.Inc("GET", "/a")
+	requests.Inc("GET", "/a")
+	requests.Add(0.5, "POST", `/"quoted"\path`+"\n")
+
+	samples, types := scrape(t, registry)
+	expected := metricstest.Samples{
+		metricstest.Key("requests_total", "method", "GET", "path", "/a"):                   2,
+		metricstest.Key("requests_total", "method", "POST", "path", `/"quoted"\path`+"\n"): 0.5,
+	}
+	if len(samples) != len(expected) {
+		t.Errorf("\nExpected: %v\nGot: %v", expected, samples)
+	}
+	for key, value := range expected {
+		if samples[key] != value {
+			t.Errorf("%s\nExpected: %v\nGot: %v", key, value, samples[key])
+		}
+	}
+	if types["requests_total"] != "counter" {
+		t.Errorf("\nExpected: counter\nGot: %s", types["requests_total"])
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	registry := metrics.NewRegistry()
+	latency := metrics.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1, 0.5}, "operation")
+	registry.MustRegister(latency)
+
+	for _, value := range []float64{0.05, 0.1, 0.3, 2, 7} {
+		latency.Observe(value, "get")
+	}
+	latency.Observe(0.2, "list")
+
+	samples, types := scrape(t, registry)
+	expected := map[string]float64{
+		metricstest.Key("latency_seconds_bucket", "operation", "get", "le", "0.1"):  2,
+		metricstest.Key("latency_seconds_bucket", "operation", "get", "le", "0.5"):  3,
+		metricstest.Key("latency_seconds_bucket", "operation", "get", "le", "1"):    3,
+		metricstest.Key("latency_seconds_bucket", "operation", "get", "le", "+Inf"): 5,
+		metricstest.Key("latency_seconds_sum", "operation", "get"):                  9.45,
+		metricstest.Key("latency_seconds_count", "operation", "get"):                5,
+		metricstest.Key("latency_seconds_bucket", "operation", "list", "le", "0.1"): 0,
+		metricstest.Key("latency_seconds_count", "operation", "list"):               1,
+	}
+	for key, value := range expected {
+		if got, ok := samples[key]; !ok || math.Abs(got-value) > 1e-9 {
+			t.Errorf("%s\nExpected: %v\nGot: %v", key, value, got)
+		}
+	}
+	if types["latency_seconds"] != "histogram" {
+		t.Errorf("\nExpected: histogram\nGot: %s", types["latency_seconds"])
+	}
+}
+
+func TestCollectors(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	// The pool connects lazily, nothing listens on the address.
+	pool, err := pgxpool.New(context.TODO(), "postgres://localhost:1/persons?pool_max_conns=5")
+	if err != nil {
+		t.Fatalf("can't create pool: %s", err)
+	}
+	defer pool.Close()
+
+	registry := metrics.NewRegistry()
+	registry.MustRegister(
+		metrics.NewGoCollector(),
+		metrics.NewDBStatsCollector(db, "postgres"),
+		metrics.NewPgxPoolStatsCollector(pool, "postgres"),
+		metrics.NewGaugeFunc("queue_size", "Queue size.", func() float64 { return 3 }),
+		metrics.NewCounterFunc("hits_total", "Hits.", func() float64 { return 42 }),
+	)
+
+	samples, types := scrape(t, registry)
+	if samples["go_goroutines"] < 1 || samples["go_memstats_sys_bytes"] <= 0 {
+		t.Errorf("\nExpected: runtime stats\nGot: %v", samples)
+	}
+	if samples[metricstest.Key("go_sql_max_open_connections", "db_name", "postgres")] != 7 {
+		t.Errorf("\nExpected: 7 max open connections\nGot: %v", samples)
+	}
+	if samples[metricstest.Key("pgxpool_max_conns", "db_name", "postgres")] != 5 ||
+		types["pgxpool_acquire_count_total"] != "counter" {
+		t.Errorf("\nExpected: 5 max pool connections\nGot: %v", samples)
+	}
+	if samples["queue_size"] != 3 || types["queue_size"] != "gauge" {
+		t.Errorf("\nExpected: queue_size gauge of 3\nGot: %v %s", samples["queue_size"], types["queue_size"])
+	}
+	if samples["hits_total"] != 42 || types["hits_total"] != "counter" {
+		t.Errorf("\nExpected: hits_total counter of 42\nGot: %v %s", samples["hits_total"], types["hits_total"])
+	}
+}
+
+func TestMustRegisterDuplicate(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.MustRegister(metrics.NewCounterVec("requests_total", "Requests."))
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("\nExpected: panic\nGot: %v", nil)
+		}
+	}()
+	registry.MustRegister(metrics.NewGaugeFunc("requests_total", "Requests.", func() float64 { return 0 }))
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"untyped sample": "requests_total 1\n",
+		"second type":    "# TYPE a counter\n# TYPE a counter\n",
+		"bad value":      "# TYPE a counter\na one\n",
+		"bad label":      "# TYPE a counter\na{b=\"c} 1\n",
+		"repeated":       "# TYPE a counter\na{b=\"c\"} 1\na{b=\"c\"} 2\n",
+		"not cumulative": "# TYPE h histogram\nh_bucket{le=\"1\"} 2\nh_bucket{le=\"+Inf\"} 1\nh_sum 3\nh_count 1\n",
+		"no inf bucket":  "# TYPE h histogram\nh_bucket{le=\"1\"} 1\nh_sum 0.5\nh_count 1\n",
+	}
+
+	for name, text := range tests {
+		text := text
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, _, err := metricstest.Parse(strings.NewReader(text)); err == nil {
+				t.Errorf("\nExpected: error\nGot: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Package metricstest parses the text exposition format for tests, so that they check what Prometheus
+// would scrape.
+package metricstest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Samples maps the samples of a scrape to their values. A key is the sample name followed by its labels
+// sorted by name, such as `http_requests_total{method="GET",status="200"}`.
+type Samples map[string]float64
+
+// Key returns the key of the sample with name and labels given as name, value pairs.
+func Key(name string, labels ...string) string {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	slices.Sort(pairs)
+	if len(pairs) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Types maps family names to their types.
+type Types map[string]string
+
+// Parse reads a scrape and checks that it is well-formed: every family is typed once before its samples,
+// samples are not repeated and histogram buckets are cumulative up to the count.
+func Parse(r io.Reader) (Samples, Types, error) {
+	samples := make(Samples)
+	types := make(Types)
+	helps := make(map[string]bool)
+	histograms := make(map[string]map[float64]float64)
+	var family string
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "#") {
+			fields := strings.SplitN(text, " ", 4)
+			if len(fields) < 3 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+				continue
+			}
+			name := fields[2]
+			if !metricName.MatchString(name) {
+				return nil, nil, fmt.Errorf("line %d: invalid metric name %q", line, name)
+			}
+			switch fields[1] {
+			case "HELP":
+				if helps[name] {
+					return nil, nil, fmt.Errorf("line %d: second HELP of %s", line, name)
+				}
+				helps[name] = true
+			case "TYPE":
+				if _, ok := types[name]; ok || len(fields) != 4 {
+					return nil, nil, fmt.Errorf("line %d: invalid or second TYPE of %s", line, name)
+				}
+				switch fields[3] {
+				case "counter", "gauge", "histogram", "summary", "untyped":
+				default:
+					return nil, nil, fmt.Errorf("line %d: unknown type %q", line, fields[3])
+				}
+				types[name] = fields[3]
+				family = name
+			}
+			continue
+		}
+
+		name, labels, value, err := parseSample(text)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !belongsTo(name, family, types[family]) {
+			return nil, nil, fmt.Errorf("line %d: sample %s outside of its family", line, name)
+		}
+		key := Key(name, labels...)
+		if _, ok := samples[key]; ok {
+			return nil, nil, fmt.Errorf("line %d: second sample %s", line, key)
+		}
+		samples[key] = value
+
+		if name == family+"_bucket" {
+			base, upperBound, err := bucket(family, labels)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if histograms[base] == nil {
+				histograms[base] = make(map[float64]float64)
+			}
+			histograms[base][upperBound] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkHistograms(samples, histograms); err != nil {
+		return nil, nil, err
+	}
+	return samples, types, nil
+}
+
+func belongsTo(name, family, typ string) bool {
+	if typ == "histogram" {
+		return name == family+"_bucket" || name == family+"_sum" || name == family+"_count"
+	}
+	return name == family
+}
+
+// parseSample parses `name{label="value",...} value [timestamp]`, labels are returned as name, value pairs.
+func parseSample(text string) (string, []string, float64, error) {
+	end := strings.IndexAny(text, "{ ")
+	if end < 0 {
+		return "", nil, 0, fmt.Errorf("no value in %q", text)
+	}
+	name := text[:end]
+	if !metricName.MatchString(name) {
+		return "", nil, 0, fmt.Errorf("invalid metric name %q", name)
+	}
+	rest := text[end:]
+
+	var labels []string
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		labels, rest, err = parseLabels(rest[1:])
+		if err != nil {
+			return "", nil, 0, err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) != 1 && len(fields) != 2 {
+		return "", nil, 0, fmt.Errorf("invalid value in %q", text)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("invalid value in %q", text)
+	}
+	return name, labels, value, nil
+}
+
+// bucket returns the series of a histogram bucket, its family with the labels other than le, and its upper bound.
+func bucket(family string, labels []string) (string, float64, error) {
+	var others []string
+	le := ""
+	for i := 0; i+1 < len(labels); i += 2 {
+		if labels[i] == "le" {
+			le = labels[i+1]
+		} else {
+			others = append(others, labels[i], labels[i+1])
+		}
+	}
+	upperBound, err := strconv.ParseFloat(le, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid le %q of %s", le, family)
+	}
+	return Key(family, others...), upperBound, nil
+}
+
+// parseLabels parses labels up to the closing brace and returns them as name, value pairs.
+func parseLabels(text string) ([]string, string, error) {
+	var labels []string
+	seen := make(map[string]bool)
+	for {
+		if strings.HasPrefix(text, "}") {
+			return labels, text[1:], nil
+		}
+		name, rest, ok := strings.Cut(text, `="`)
+		if !ok || !metricName.MatchString(name) || seen[name] {
+			return nil, "", fmt.Errorf("invalid label in %q", text)
+		}
+		seen[name] = true
+
+		var value strings.Builder
+		i := 0
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] != '\\' {
+				value.WriteByte(rest[i])
+				continue
+			}
+			i++
+			if i == len(rest) {
+				break
+			}
+			switch rest[i] {
+			case '\\', '"':
+				value.WriteByte(rest[i])
+			case 'n':
+				value.WriteByte('\n')
+			default:
+				return nil, "", fmt.Errorf("invalid escape in %q", text)
+			}
+		}
+		if i >= len(rest) {
+			return nil, "", fmt.Errorf("unterminated label value in %q", text)
+		}
+		labels = append(labels, name, value.String())
+
+		text = strings.TrimPrefix(rest[i+1:], ",")
+	}
+}
+
+// checkHistograms checks the buckets of every series of a histogram, keyed by its family and labels without le.
+func checkHistograms(samples Samples, histograms map[string]map[float64]float64) error {
+	for base, buckets := range histograms {
+		family, labels, _ := strings.Cut(base, "{")
+		if labels != "" {
+			labels = "{" + labels
+		}
+		upperBounds := make([]float64, 0, len(buckets))
+		for upperBound := range buckets {
+			upperBounds = append(upperBounds, upperBound)
+		}
+		slices.Sort(upperBounds)
+		for i := 1; i < len(upperBounds); i++ {
+			if buckets[upperBounds[i]] < buckets[upperBounds[i-1]] {
+				return fmt.Errorf("buckets of %s are not cumulative", base)
+			}
+		}
+
+		count, ok := samples[family+"_count"+labels]
+		if _, hasSum := samples[family+"_sum"+labels]; !ok || !hasSum {
+			return fmt.Errorf("no count or sum of %s", base)
+		}
+		if last := upperBounds[len(upperBounds)-1]; !math.IsInf(last, 1) || buckets[last] != count {
+			return fmt.Errorf("+Inf bucket of %s does not match its count", base)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit latencies in seconds of network services.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// labelSet is one combination of label values of a vector.
+type labelSet struct {
+	values []string
+	// key joins values with a byte that is not valid in UTF-8, so that distinct sets never collide.
+	key string
+}
+
+func newLabelSet(names, values []string) labelSet {
+	if len(values) != len(names) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(values), names))
+	}
+	return labelSet{values: values, key: strings.Join(values, "\xff")}
+}
+
+func labels(names, values []string, extra ...Label) []Label {
+	result := make([]Label, 0, len(names)+len(extra))
+	for i, name := range names {
+		result = append(result, Label{Name: name, Value: values[i]})
+	}
+	return append(result, extra...)
+}
+
+// CounterVec counts events partitioned by label values.
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labelSet
+	value float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*counterValue),
+	}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the counter of labelValues.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counters can not decrease")
+	}
+	set := newLabelSet(c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.values[set.key]
+	if !ok {
+		v = &counterValue{labelSet: set}
+		c.values[set.key] = v
+	}
+	v.value += delta
+}
+
+func (c *CounterVec) Collect() []Family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	family := Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	for _, v := range sortedValues(c.values) {
+		family.Samples = append(family.Samples, Sample{
+			Name:   c.name,
+			Labels: labels(c.labels, v.values),
+			Value:  v.value,
+		})
+	}
+	return []Family{family}
+}
+
+// HistogramVec counts observations in buckets partitioned by label values.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelSet
+	// counts[i] is the number of observations in (buckets[i-1], buckets[i]], the last one counts those
+	// above every bucket.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec returns a histogram with the upper bounds of buckets, DefaultBuckets if there are none.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	buckets = slices.Compact(buckets)
+	if math.IsInf(buckets[len(buckets)-1], 1) {
+		buckets = buckets[:len(buckets)-1]
+	}
+
+	return &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	set := newLabelSet(h.labels, labelValues)
+	i, _ := slices.BinarySearch(h.buckets, value)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v, ok := h.values[set.key]
+	if !ok {
+		v = &histogramValue{labelSet: set, counts: make([]uint64, len(h.buckets)+1)}
+		h.values[set.key] = v
+	}
+	v.counts[i]++
+	v.sum += value
+	v.count++
+}
+
+func (h *HistogramVec) Collect() []Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	family := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, v := range sortedValues(h.values) {
+		var cumulative uint64
+		for i, upperBound := range h.buckets {
+			cumulative += v.counts[i]
+			family.Samples = append(family.Samples, Sample{
+				Name:   h.name + "_bucket",
+				Labels: labels(h.labels, v.values, Label{Name: "le", Value: formatValue(upperBound)}),
+				Value:  float64(cumulative),
+			})
+		}
+		family.Samples = append(family.Samples,
+			Sample{
+				Name:   h.name + "_bucket",
+				Labels: labels(h.labels, v.values, Label{Name: "le", Value: "+Inf"}),
+				Value:  float64(v.count),
+			},
+			Sample{Name: h.name + "_sum", Labels: labels(h.labels, v.values), Value: v.sum},
+			Sample{Name: h.name + "_count", Labels: labels(h.labels, v.values), Value: float64(v.count)},
+		)
+	}
+	return []Family{family}
+}
+
+func sortedValues[V interface{ setKey() string }](values map[string]V) []V {
+	sorted := make([]V, 0, len(values))
+	for _, v := range values {
+		sorted = append(sorted, v)
+	}
+	slices.SortFunc(sorted, func(a, b V) int {
+		return strings.Compare(a.setKey(), b.setKey())
+	})
+	return sorted
+}
+
+func (s labelSet) setKey() string {
+	return s.key
+}
+
+type funcCollector struct {
+	family Family
+	fn     func() float64
+}
+
+// NewGaugeFunc returns a gauge that reports the value of fn.
+func NewGaugeFunc(name, help string, fn func() float64) Collector {
+	return &funcCollector{family: Family{Name: name, Help: help, Type: TypeGauge}, fn: fn}
+}
+
+// NewCounterFunc returns a counter that reports the value of fn, which must not decrease.
+func NewCounterFunc(name, help string, fn func() float64) Collector {
+	return &funcCollector{family: Family{Name: name, Help: help, Type: TypeCounter}, fn: fn}
+}
+
+func (c *funcCollector) Collect() []Family {
+	family := c.family
+	family.Samples = []Sample{{Name: family.Name, Value: c.fn()}}
+	return []Family{family}
+}
@@ -0,0 +1,404 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+)
+
+var (
+	ErrBadFileName       = errors.New("bad migration file name")
+	ErrDuplicateVersion  = errors.New("duplicate migration version")
+	ErrMissingUp         = errors.New("migration has no up file")
+	ErrMissingDown       = errors.New("migration has no down file")
+	ErrUnknownVersion    = errors.New("unknown migration version")
+	ErrChecksumMismatch  = errors.New("migration checksum mismatch")
+	ErrPendingMigrations = errors.New("pending migrations")
+)
+
+// lockKey identifies the advisory lock held while migrations are applied.
+const lockKey int64 = 0x64732d6c616231
+
+var fileNameRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// Load reads <version>_<name>.up.sql and optional <version>_<name>.down.sql files from dir.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := fileNameRe.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, errors.Wrap(ErrBadFileName, entry.Name())
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(ErrBadFileName, entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		} else if m.Name != matches[2] {
+			return nil, errors.Wrap(ErrDuplicateVersion, entry.Name())
+		}
+
+		if matches[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, errors.Wrap(ErrMissingUp, fmt.Sprintf("version %d", m.Version))
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+type Migrator struct {
+	db                 *sql.DB
+	migrations         []Migration
+	healthCheckTimeout time.Duration
+	log                *zap.Logger
+}
+
+func New(db *sql.DB, migrations []Migration, log *zap.Logger) *Migrator {
+	return &Migrator{
+		db:                 db,
+		migrations:         migrations,
+		healthCheckTimeout: viper.GetDuration(config.PostgresHealthCheckTimeout),
+		log:                log,
+	}
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	return m.Goto(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			m.log.Info("No migrations to roll back")
+			return nil
+		}
+
+		var target int64
+		if len(versions) > 1 {
+			target = versions[len(versions)-2]
+		}
+		return m.migrateTo(ctx, conn, target)
+	})
+}
+
+// Goto applies or rolls back migrations until version is the latest applied one. Version 0 rolls back everything.
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 {
+		if _, ok := m.find(version); !ok {
+			return errors.Wrap(ErrUnknownVersion, strconv.FormatInt(version, 10))
+		}
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		return m.migrateTo(ctx, conn, version)
+	})
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, createTableCmd); err != nil {
+		return nil, errors.Wrap(err, "create schema_migrations")
+	}
+
+	appliedByVersion, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if a, ok := appliedByVersion[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// HealthCheck reports an error if any known migration has not been applied yet.
+func (m *Migrator) HealthCheck(ctx context.Context) error {
+	ctx, cancel := postgres.WithTimeout(ctx, m.healthCheckTimeout)
+	defer cancel()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	appliedByVersion, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, migration := range m.migrations {
+		if _, ok := appliedByVersion[migration.Version]; !ok {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return errors.Wrap(ErrPendingMigrations, strconv.Itoa(pending))
+	}
+	return nil
+}
+
+const (
+	createTableCmd = `
+	CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+		version    bigint primary key,
+		name       text        not null,
+		checksum   text        not null,
+		applied_at timestamptz not null default now()
+	);`
+
+	lockCmd   = `SELECT pg_advisory_lock($1);`
+	unlockCmd = `SELECT pg_advisory_unlock($1);`
+
+	appliedCmd = `
+	SELECT version, checksum, applied_at
+	FROM schema_migrations
+	ORDER BY version;`
+
+	insertVersionCmd = `
+	INSERT INTO schema_migrations (version, name, checksum)
+	VALUES ($1, $2, $3);`
+
+	deleteVersionCmd = `
+	DELETE FROM schema_migrations
+	WHERE version = $1;`
+)
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, lockCmd, lockKey); err != nil {
+		return errors.Wrap(err, "acquire migration lock")
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), unlockCmd, lockKey); err != nil {
+			m.log.Error("Failed to release migration lock", zap.Error(err))
+		}
+	}()
+
+	if _, err = conn.ExecContext(ctx, createTableCmd); err != nil {
+		return errors.Wrap(err, "create schema_migrations")
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]applied, error) {
+	rows, err := conn.QueryContext(ctx, appliedCmd)
+	if err != nil {
+		return nil, errors.Wrap(err, "read schema_migrations")
+	}
+	defer rows.Close()
+
+	result := make(map[int64]applied)
+	for rows.Next() {
+		var version int64
+		var a applied
+		if err = rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, errors.Wrap(err, "scan schema_migrations")
+		}
+		result[version] = a
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "read schema_migrations")
+	}
+
+	for version, a := range result {
+		migration, ok := m.find(version)
+		if !ok {
+			return nil, errors.Wrap(ErrUnknownVersion, strconv.FormatInt(version, 10))
+		}
+		if migration.Checksum != a.checksum {
+			return nil, errors.Wrap(ErrChecksumMismatch, fmt.Sprintf("version %d", version))
+		}
+	}
+
+	return result, nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) ([]int64, error) {
+	appliedByVersion, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, 0, len(appliedByVersion))
+	for version := range appliedByVersion {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+	return versions, nil
+}
+
+func (m *Migrator) migrateTo(ctx context.Context, conn *sql.Conn, target int64) error {
+	appliedByVersion, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := appliedByVersion[migration.Version]; ok && migration.Version > target {
+			if err = m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := appliedByVersion[migration.Version]; !ok && migration.Version <= target {
+			if err = m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) (err error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	direction := "down"
+	if up {
+		direction = "up"
+		if _, err = tx.ExecContext(ctx, migration.Up); err != nil {
+			return errors.Wrapf(err, "migration %d_%s up", migration.Version, migration.Name)
+		}
+		if _, err = tx.ExecContext(ctx, insertVersionCmd, migration.Version, migration.Name, migration.Checksum); err != nil {
+			return errors.Wrap(err, "record migration")
+		}
+	} else {
+		if migration.Down == "" {
+			return errors.Wrap(ErrMissingDown, fmt.Sprintf("version %d", migration.Version))
+		}
+		if _, err = tx.ExecContext(ctx, migration.Down); err != nil {
+			return errors.Wrapf(err, "migration %d_%s down", migration.Version, migration.Name)
+		}
+		if _, err = tx.ExecContext(ctx, deleteVersionCmd, migration.Version); err != nil {
+			return errors.Wrap(err, "record migration")
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	m.log.Info("Migration applied",
+		zap.Int64("version", migration.Version),
+		zap.String("name", migration.Name),
+		zap.String("direction", direction))
+	return nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+func init() {
+	var err error
+	logger, err = zap.NewDevelopment()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+var testFS = fstest.MapFS{
+	"migrations/0002_add_index.up.sql":     {Data: []byte("create index persons_name_idx on persons (name);")},
+	"migrations/0002_add_index.down.sql":   {Data: []byte("drop index persons_name_idx;")},
+	"migrations/0001_add_persons.up.sql":   {Data: []byte("create table persons (id bigserial primary key);")},
+	"migrations/0001_add_persons.down.sql": {Data: []byte("drop table persons;")},
+}
+
+func TestLoad(t *testing.T) {
+	type testCase struct {
+		fsys     fstest.MapFS
+		versions []int64
+		err      error
+	}
+
+	tests := map[string]testCase{
+		"sorted by version": {
+			fsys:     testFS,
+			versions: []int64{1, 2},
+			err:      nil,
+		},
+		"bad file name": {
+			fsys: fstest.MapFS{
+				"migrations/add_persons.sql": {Data: []byte("select 1;")},
+			},
+			err: ErrBadFileName,
+		},
+		"missing up": {
+			fsys: fstest.MapFS{
+				"migrations/0001_add_persons.down.sql": {Data: []byte("drop table persons;")},
+			},
+			err: ErrMissingUp,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			migrations, err := Load(test.fsys, "migrations")
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if len(migrations) != len(test.versions) {
+				t.Fatalf("\nExpected: %d migrations\nGot: %d", len(test.versions), len(migrations))
+			}
+			for i, m := range migrations {
+				if m.Version != test.versions[i] || m.Up == "" || m.Down == "" || m.Checksum == "" {
+					t.Errorf("\nUnexpected migration: %+v", m)
+				}
+			}
+		})
+	}
+}
+
+func TestGoto(t *testing.T) {
+	type fields struct {
+		mock       sqlmock.Sqlmock
+		migrations []Migration
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		version int64
+		err     error
+	}
+
+	expectLock := func(f *fields) {
+		f.mock.ExpectExec(regexp.QuoteMeta(lockCmd)).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+		f.mock.ExpectExec(regexp.QuoteMeta(createTableCmd)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	expectUnlock := func(f *fields) {
+		f.mock.ExpectExec(regexp.QuoteMeta(unlockCmd)).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	appliedRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"version", "checksum", "applied_at"})
+	}
+
+	tests := map[string]testCase{
+		"apply pending": {
+			prepare: func(f *fields) {
+				expectLock(f)
+				f.mock.ExpectQuery(regexp.QuoteMeta(appliedCmd)).
+					WillReturnRows(appliedRows().AddRow(1, f.migrations[0].Checksum, time.Now()))
+				f.mock.ExpectBegin()
+				f.mock.ExpectExec(regexp.QuoteMeta(f.migrations[1].Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+				f.mock.ExpectExec(regexp.QuoteMeta(insertVersionCmd)).
+					WithArgs(2, "add_index", f.migrations[1].Checksum).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				f.mock.ExpectCommit()
+				expectUnlock(f)
+			},
+			version: 2,
+			err:     nil,
+		},
+		"roll back everything": {
+			prepare: func(f *fields) {
+				expectLock(f)
+				f.mock.ExpectQuery(regexp.QuoteMeta(appliedCmd)).
+					WillReturnRows(appliedRows().
+						AddRow(1, f.migrations[0].Checksum, time.Now()).
+						AddRow(2, f.migrations[1].Checksum, time.Now()))
+				for _, i := range []int{1, 0} {
+					f.mock.ExpectBegin()
+					f.mock.ExpectExec(regexp.QuoteMeta(f.migrations[i].Down)).WillReturnResult(sqlmock.NewResult(0, 0))
+					f.mock.ExpectExec(regexp.QuoteMeta(deleteVersionCmd)).
+						WithArgs(f.migrations[i].Version).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					f.mock.ExpectCommit()
+				}
+				expectUnlock(f)
+			},
+			version: 0,
+			err:     nil,
+		},
+		"checksum mismatch": {
+			prepare: func(f *fields) {
+				expectLock(f)
+				f.mock.ExpectQuery(regexp.QuoteMeta(appliedCmd)).
+					WillReturnRows(appliedRows().AddRow(1, "edited", time.Now()))
+				expectUnlock(f)
+			},
+			version: 2,
+			err:     ErrChecksumMismatch,
+		},
+		"unknown version": {
+			version: 42,
+			err:     ErrUnknownVersion,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("can't create mock: %s", err)
+			}
+			defer db.Close()
+
+			migrations, err := Load(testFS, "migrations")
+			if err != nil {
+				t.Fatalf("can't load migrations: %s", err)
+			}
+			migrator := New(db, migrations, logger)
+
+			f := fields{mock: mock, migrations: migrations}
+			if test.prepare != nil {
+				test.prepare(&f)
+			}
+
+			err = migrator.Goto(context.TODO(), test.version)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("\nThere were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Package requestinfo carries who made a request, its id and its logger through the context, down to
+// the audit trail and the repository logs.
+package requestinfo
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// AnonymousActor is reported for requests that do not name their actor.
+const AnonymousActor = "anonymous"
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+	loggerKey
+)
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the actor stored in ctx or AnonymousActor.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request id stored in ctx, an empty string if there is none.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithLogger stores the logger of the request, which carries the fields that identify it.
+func WithLogger(ctx context.Context, log *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, log)
+}
+
+// Logger returns the logger stored in ctx, or log outside of a request. Logging with it lets failures
+// be traced back to the request that caused them.
+func Logger(ctx context.Context, log *zap.Logger) *zap.Logger {
+	if requestLog, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return requestLog
+	}
+	return log
+}
@@ -0,0 +1,227 @@
+// Package resp is a minimal client of the Redis serialization protocol, enough to use Redis or a compatible
+// server (Valkey, KeyDB, Dragonfly) as a cache.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNil is returned for a nil reply, such as GET of a missing key.
+var ErrNil = errors.New("resp: nil reply")
+
+// Error is an error reply of the server.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+type Config struct {
+	Addr     string
+	Password string
+	DB       int
+	// PoolSize is the number of idle connections kept for reuse.
+	PoolSize int
+	// Timeout bounds dialing and every command unless the context expires earlier.
+	Timeout time.Duration
+}
+
+// Client runs commands over a pool of connections. Connections are dialed on demand, so a server that is down
+// does not keep the client from being created, and it is used once it is up.
+type Client struct {
+	config Config
+	idle   chan *conn
+	closed atomic.Bool
+}
+
+func NewClient(config Config) *Client {
+	return &Client{
+		config: config,
+		idle:   make(chan *conn, max(config.PoolSize, 1)),
+	}
+}
+
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// Do runs a command and returns its reply: string for simple and bulk strings, int64 for integers
+// and []any for arrays. Error replies are returned as Error.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, c.config.Timeout, args)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) && !errors.Is(err, ErrNil) {
+		// The connection state is unknown after a network or protocol error.
+		_ = cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Close closes the idle connections, connections in use are closed once their commands complete.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+	for {
+		select {
+		case cn := <-c.idle:
+			_ = cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.config.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: netConn, reader: bufio.NewReader(netConn), writer: bufio.NewWriter(netConn)}
+
+	if c.config.Password != "" {
+		_, err = cn.do(ctx, c.config.Timeout, []string{"AUTH", c.config.Password})
+	}
+	if err == nil && c.config.DB != 0 {
+		_, err = cn.do(ctx, c.config.Timeout, []string{"SELECT", strconv.Itoa(c.config.DB)})
+	}
+	if err != nil {
+		_ = cn.Close()
+		return nil, errors.Wrap(err, "resp: failed to set up connection")
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	if c.closed.Load() {
+		_ = cn.Close()
+		return
+	}
+	select {
+	case c.idle <- cn:
+	default:
+		_ = cn.Close()
+	}
+}
+
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if timeout > 0 && (!ok || time.Until(deadline) > timeout) {
+		deadline, ok = time.Now().Add(timeout), true
+	}
+	if ok {
+		_ = cn.SetDeadline(deadline)
+	} else {
+		_ = cn.SetDeadline(time.Time{})
+	}
+
+	err := writeCommand(cn.writer, args)
+	if err == nil {
+		err = cn.writer.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return readReply(cn.reader)
+}
+
+func writeCommand(w *bufio.Writer, args []string) error {
+	_, err := fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return err
+}
+
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("resp: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrap(err, "resp: bad bulk string size")
+		}
+		if size < 0 {
+			return nil, ErrNil
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrap(err, "resp: bad array size")
+		}
+		if size < 0 {
+			return nil, ErrNil
+		}
+		// Nil and error items are kept as nil and Error, the rest of the array has to be read anyway.
+		items := make([]any, size)
+		for i := range items {
+			var replyErr Error
+			items[i], err = readReply(r)
+			switch {
+			case errors.Is(err, ErrNil):
+			case errors.As(err, &replyErr):
+				items[i] = replyErr
+			case err != nil:
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, errors.Errorf("resp: unexpected reply type %q", line[0])
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("resp: reply line does not end with CRLF")
+	}
+	return line[:len(line)-2], nil
+}
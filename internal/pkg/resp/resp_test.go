@@ -0,0 +1,100 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/resp/resptest"
+)
+
+func TestReadReply(t *testing.T) {
+	type testCase struct {
+		input string
+		reply any
+		err   error
+	}
+
+	tests := map[string]testCase{
+		"simple string": {input: "+OK\r\n", reply: "OK"},
+		"error":         {input: "-ERR unknown command\r\n", err: Error("ERR unknown command")},
+		"integer":       {input: ":42\r\n", reply: int64(42)},
+		"bulk string":   {input: "$5\r\nab\r\nc\r\n", reply: "ab\r\nc"},
+		"nil":           {input: "$-1\r\n", err: ErrNil},
+		"array": {
+			input: "*3\r\n$1\r\na\r\n$-1\r\n-ERR bad\r\n",
+			reply: []any{"a", nil, Error("ERR bad")},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reply, err := readReply(bufio.NewReader(strings.NewReader(test.input)))
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.err, err)
+			}
+			if !reflect.DeepEqual(reply, test.reply) {
+				t.Errorf("\nExpected: %#v\nGot: %#v", test.reply, reply)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("can't start server: %s", err)
+	}
+	defer server.Close()
+
+	client := NewClient(Config{Addr: server.Addr(), Password: "secret", DB: 1, PoolSize: 2, Timeout: time.Second})
+	defer client.Close()
+	ctx := context.Background()
+
+	if reply, err := client.Do(ctx, "SET", "key", "value", "PX", "60000"); err != nil || reply != "OK" {
+		t.Fatalf("\nExpected: OK\nGot: %v, %v", reply, err)
+	}
+	if reply, err := client.Do(ctx, "GET", "key"); err != nil || reply != "value" {
+		t.Errorf("\nExpected: value\nGot: %v, %v", reply, err)
+	}
+	if reply, err := client.Do(ctx, "DEL", "key", "other"); err != nil || reply != int64(1) {
+		t.Errorf("\nExpected: 1\nGot: %v, %v", reply, err)
+	}
+	if _, err = client.Do(ctx, "GET", "key"); !errors.Is(err, ErrNil) {
+		t.Errorf("\nExpected: %v\nGot: %v", ErrNil, err)
+	}
+	var replyErr Error
+	if _, err = client.Do(ctx, "INCR", "key"); !errors.As(err, &replyErr) {
+		t.Errorf("\nExpected: an error reply\nGot: %v", err)
+	}
+
+	// The connection is reused after nil and error replies: AUTH and SELECT are sent once.
+	if commands := server.Commands(); commands != 7 {
+		t.Errorf("\nExpected: %d commands\nGot: %d", 7, commands)
+	}
+}
+
+func TestClientServerDown(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("can't start server: %s", err)
+	}
+	client := NewClient(Config{Addr: server.Addr(), Timeout: time.Second})
+	defer client.Close()
+
+	if _, err = client.Do(context.Background(), "PING"); err != nil {
+		t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+	}
+	server.Close()
+	if _, err = client.Do(context.Background(), "PING"); err == nil {
+		t.Errorf("\nExpected: an error\nGot: %v", nil)
+	}
+}
@@ -0,0 +1,205 @@
+// Package resptest provides an in-memory server speaking the Redis protocol for tests. It supports the commands
+// used by the cache: PING, AUTH, SELECT, GET, SET with EX or PX, DEL and FLUSHDB.
+package resptest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type item struct {
+	value   string
+	expires time.Time
+}
+
+type Server struct {
+	listener net.Listener
+	mu       sync.Mutex
+	items    map[string]item
+	conns    map[net.Conn]struct{}
+	commands int
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a random local port.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		items:    make(map[string]item),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Commands returns the number of commands served so far.
+func (s *Server) Commands() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commands
+}
+
+// Close stops the server and drops its connections.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		_, _ = writer.WriteString(s.exec(args))
+		if writer.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands++
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if len(args) != 2 {
+			return "-ERR wrong number of arguments for 'get' command\r\n"
+		}
+		it, ok := s.items[args[1]]
+		if !ok || (!it.expires.IsZero() && !time.Now().Before(it.expires)) {
+			delete(s.items, args[1])
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(it.value), it.value)
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			return "-ERR syntax error\r\n"
+		}
+		it := item{value: args[2]}
+		if len(args) == 5 {
+			ttl, err := strconv.ParseInt(args[4], 10, 64)
+			if err != nil || ttl <= 0 {
+				return "-ERR invalid expire time in 'set' command\r\n"
+			}
+			switch strings.ToUpper(args[3]) {
+			case "EX":
+				it.expires = time.Now().Add(time.Duration(ttl) * time.Second)
+			case "PX":
+				it.expires = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+		s.items[args[1]] = it
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.items[key]; ok {
+				delete(s.items, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "FLUSHDB":
+		s.items = make(map[string]item)
+		return "+OK\r\n"
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	count, err := readHeader(r, '*')
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		size, err := readHeader(r, '$')
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readHeader(r *bufio.Reader, prefix byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) < 2 || line[0] != prefix {
+		return 0, fmt.Errorf("expected %q, got %q", prefix, line)
+	}
+	return strconv.Atoi(line[1:])
+}
@@ -7,12 +7,19 @@ import (
 	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
 	"strconv"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/lib/pq"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
+const (
+	DriverStd = "std"
+	DriverPgx = "pgx"
+)
+
 func NewStd(log *zap.Logger) (*sql.DB, error) {
 	log.Info("Connecting to Postgres...",
 		zap.String("host", viper.GetString(config.PostgresHost)),
@@ -45,26 +52,56 @@ func NewStd(log *zap.Logger) (*sql.DB, error) {
 	return db, nil
 }
 
-func NewPgx(log *zap.Logger) (*pgxpool.Pool, error) {
+// NewPgx opens a pool whose queries are passed to tracer, which may be nil.
+func NewPgx(log *zap.Logger, tracer pgx.QueryTracer) (*pgxpool.Pool, error) {
 	log.Info("Connecting to Postgres PGX...",
 		zap.String("host", viper.GetString(config.PostgresHost)),
 		zap.Int("port", viper.GetInt(config.PostgresPort)),
 		zap.String("dbname", viper.GetString(config.PostgresDB)),
 	)
 
-	dbUser := viper.GetString(config.PostgresUser)
-	dbPassword := viper.GetString(config.PostgresPassword)
-	dbName := viper.GetString(config.PostgresDB)
-	dbHost := viper.GetString(config.PostgresHost)
-	dbPort := strconv.Itoa(viper.GetInt(config.PostgresPort))
-
-	conf, _ := pgxpool.ParseConfig("postgres://" + dbUser + ":" + dbPassword + "@" + dbHost + ":" + dbPort + "/" + dbName + "?" + "pool_max_conns=100")
+	conf, err := pgxpool.ParseConfig(pgxConnString() + "&pool_max_conns=100")
+	if err != nil {
+		log.Error("Failed to parse PGX config", zap.Error(err))
+		return nil, err
+	}
+	conf.ConnConfig.Tracer = tracer
 	pool, err := pgxpool.NewWithConfig(context.Background(), conf)
 	if err != nil {
 		log.Error("Failed to connect to db PGX", zap.Error(err))
 		return nil, err
 	}
 
+	err = pool.Ping(context.Background())
+	if err != nil {
+		log.Error("Failed to connect to Postgres PGX", zap.Error(err))
+		pool.Close()
+		return nil, err
+	}
+
 	log.Info("Postgres PGX connection created successfully")
 	return pool, nil
 }
+
+// NewPgxConn opens a single connection outside of any pool, for sessions that must stay on one connection
+// such as LISTEN.
+func NewPgxConn(ctx context.Context) (*pgx.Conn, error) {
+	return pgx.Connect(ctx, pgxConnString())
+}
+
+func pgxConnString() string {
+	dbUser := viper.GetString(config.PostgresUser)
+	dbPassword := viper.GetString(config.PostgresPassword)
+	dbName := viper.GetString(config.PostgresDB)
+	dbHost := viper.GetString(config.PostgresHost)
+	dbPort := strconv.Itoa(viper.GetInt(config.PostgresPort))
+
+	dbSSLMode := viper.GetString(config.PostgresSSLMode)
+
+	return "postgres://" + dbUser + ":" + dbPassword + "@" + dbHost + ":" + dbPort + "/" + dbName + "?" + "sslmode=" + dbSSLMode
+}
+
+// NewStdFromPgx exposes pool as *sql.DB for code that needs database/sql, such as migrations.
+func NewStdFromPgx(pool *pgxpool.Pool) *sql.DB {
+	return stdlib.OpenDBFromPool(pool)
+}
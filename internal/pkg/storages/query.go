@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+// WithTimeout bounds ctx by timeout unless timeout is not configured.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// QueryError reports why a query failed: its deadline expired, the caller went away or the database failed.
+// Repositories map the errors that are specific to them, such as unique violations, before calling it.
+func QueryError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return errors.Wrap(pErrors.ErrDeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return errors.Wrap(pErrors.ErrRequestCanceled, err.Error())
+	default:
+		return errors.Wrap(pErrors.ErrDb, err.Error())
+	}
+}
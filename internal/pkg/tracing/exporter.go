@@ -0,0 +1,254 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Exporter names, see the TRACING_EXPORTER setting.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Exporter sends ended spans to where they are stored. Export is not called concurrently.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// writerExporter writes every span as a line of JSON.
+type writerExporter struct {
+	service string
+	mu      sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+}
+
+// NewWriterExporter writes spans to w as lines of JSON, w is not closed on shutdown.
+func NewWriterExporter(w io.Writer, service string) Exporter {
+	return &writerExporter{service: service, w: w}
+}
+
+// NewFileExporter appends spans to the file at path as lines of JSON.
+func NewFileExporter(path, service string) (Exporter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &writerExporter{service: service, w: file, closer: file}, nil
+}
+
+type jsonSpan struct {
+	Service       string         `json:"service"`
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind"`
+	TraceID       string         `json:"trace_id"`
+	SpanID        string         `json:"span_id"`
+	ParentSpanID  string         `json:"parent_span_id,omitempty"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	DurationMs    float64        `json:"duration_ms"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Error         bool           `json:"error,omitempty"`
+	StatusMessage string         `json:"status_message,omitempty"`
+}
+
+var kindNames = map[SpanKind]string{
+	SpanKindInternal: "internal",
+	SpanKindServer:   "server",
+	SpanKindClient:   "client",
+}
+
+func (e *writerExporter) Export(_ context.Context, spans []SpanData) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range spans {
+		span := &spans[i]
+		line := jsonSpan{
+			Service:       e.service,
+			Name:          span.Name,
+			Kind:          kindNames[span.Kind],
+			TraceID:       span.SpanContext.TraceID.String(),
+			SpanID:        span.SpanContext.SpanID.String(),
+			Start:         span.Start,
+			End:           span.End,
+			DurationMs:    float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+			Error:         span.Status == StatusError,
+			StatusMessage: span.StatusMessage,
+		}
+		if span.Parent.IsValid() {
+			line.ParentSpanID = span.Parent.String()
+		}
+		if len(span.Attributes) > 0 {
+			line.Attributes = make(map[string]any, len(span.Attributes))
+			for _, attribute := range span.Attributes {
+				line.Attributes[attribute.Key] = attribute.Value
+			}
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func (e *writerExporter) Shutdown(context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// otlpExporter sends spans to an OpenTelemetry collector with OTLP over HTTP in its JSON encoding, see
+// https://opentelemetry.io/docs/specs/otlp/#otlphttp.
+type otlpExporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+}
+
+// NewOTLPExporter posts spans to endpoint, the traces URL of a collector such as
+// http://localhost:4318/v1/traces.
+func NewOTLPExporter(endpoint, service string, timeout time.Duration) Exporter {
+	return &otlpExporter{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+const scopeName = "github.com/SlavaShagalov/ds-lab1/internal/pkg/tracing"
+
+func (e *otlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for i := range spans {
+		span := &spans[i]
+		s := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+		if span.Parent.IsValid() {
+			s.ParentSpanID = span.Parent.String()
+		}
+		otlpSpans = append(otlpSpans, s)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes([]Attribute{String("service.name", e.service)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: otlpSpans}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("collector responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// otlpAttributes encodes attributes as OTLP AnyValues, 64-bit integers are strings in the JSON encoding.
+func otlpAttributes(attributes []Attribute) []otlpAttribute {
+	result := make([]otlpAttribute, 0, len(attributes))
+	for _, attribute := range attributes {
+		var value map[string]any
+		switch v := attribute.Value.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			continue
+		}
+		result = append(result, otlpAttribute{Key: attribute.Key, Value: value})
+	}
+	return result
+}
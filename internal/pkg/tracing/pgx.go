@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryTracer traces the round trips of pgx connections to Postgres. Only queries made on behalf of a traced
+// operation get a span, so that background polling does not start a trace per query.
+type QueryTracer struct {
+	tracer *Tracer
+}
+
+func NewQueryTracer(tracer *Tracer) *QueryTracer {
+	return &QueryTracer{tracer: tracer}
+}
+
+func (qt *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if SpanFromContext(ctx) == nil {
+		return ctx
+	}
+	ctx, span := qt.tracer.Start(ctx, queryName(data.SQL), SpanKindClient,
+		String("db.system", "postgresql"),
+		String("db.query.text", strings.TrimSpace(data.SQL)))
+	return context.WithValue(ctx, querySpanKey, span)
+}
+
+func (qt *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(querySpanKey).(*Span)
+	if !ok {
+		return
+	}
+	if data.Err != nil {
+		span.RecordError(data.Err)
+	} else {
+		span.SetAttributes(Int64("db.response.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	span.End()
+}
+
+// queryName names a query span after the SQL command, such as SELECT, as its text is too long for a name.
+func queryName(sql string) string {
+	command := strings.TrimSpace(sql)
+	if end := strings.IndexFunc(command, unicode.IsSpace); end >= 0 {
+		command = command[:end]
+	}
+	if command == "" {
+		return "postgresql"
+	}
+	return "postgresql " + strings.ToUpper(command)
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const TraceparentHeader = "Traceparent"
+
+const (
+	traceparentVersion = "00"
+	flagSampled        = 0x01
+)
+
+// Traceparent formats sc as the value of the traceparent header.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return traceparentVersion + "-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses the value of a traceparent header. Versions after 00 are parsed as far as
+// version 00 goes, as the specification requires.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !lowerHex(version, 2) || version == "ff" || (version == traceparentVersion && len(parts) != 4) ||
+		!lowerHex(traceID, 32) || !lowerHex(spanID, 16) || !lowerHex(flags, 2) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	_, _ = hex.Decode(sc.TraceID[:], []byte(traceID))
+	_, _ = hex.Decode(sc.SpanID[:], []byte(spanID))
+	var flagBits [1]byte
+	_, _ = hex.Decode(flagBits[:], []byte(flags))
+	sc.Sampled = flagBits[0]&flagSampled != 0
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+func lowerHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if (value[i] < '0' || value[i] > '9') && (value[i] < 'a' || value[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Inject sets the traceparent header to the current span of ctx, so that the receiver continues the trace.
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// Extract returns ctx with the span of the traceparent header as the remote parent. An invalid header
+// is ignored and a new trace is started.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if sc, ok := ParseTraceparent(header.Get(TraceparentHeader)); ok {
+		return ContextWithRemoteSpanContext(ctx, sc)
+	}
+	return ctx
+}
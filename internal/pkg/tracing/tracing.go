@@ -0,0 +1,359 @@
+// Package tracing records the spans of requests and propagates their context with the W3C Trace Context
+// traceparent header, see https://www.w3.org/TR/trace-context/. Spans are modeled after OpenTelemetry,
+// so that they can be sent to its collectors.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext identifies a span across processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Sampled spans are recorded, the decision of the root span is followed by the whole trace.
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+type SpanKind int
+
+// Span kinds, the values are those of OTLP.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type StatusCode int
+
+// Status codes, the values are those of OTLP.
+const (
+	StatusUnset StatusCode = 0
+	StatusError StatusCode = 2
+)
+
+// Attribute describes a span, its value is a string, an int64, a float64 or a bool.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is an ended span as it is exported.
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span is an operation within a trace. Spans that are not sampled only carry their context, so that
+// it is propagated. The methods of a nil span do nothing.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetName renames the span, such as once the route of a request is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Name = name
+}
+
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil || !s.data.SpanContext.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+}
+
+// RecordError marks the span as failed with err.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status = StatusError
+	s.data.StatusMessage = err.Error()
+}
+
+// End finishes the span and queues it for export if it is sampled. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.SpanContext.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteKey
+	// querySpanKey marks the span of a query, which is ended by the query tracer.
+	querySpanKey
+)
+
+// ContextWithSpan makes span the parent of the spans started with ctx.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// SpanFromContext returns the current span of ctx, nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext makes a span of another process the parent of the spans started with ctx.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+// Batching of the export.
+const (
+	queueSize      = 2048
+	maxBatchSize   = 512
+	exportInterval = 5 * time.Second
+	exportTimeout  = 30 * time.Second
+)
+
+// Tracer starts spans and exports the sampled ones in batches in the background.
+type Tracer struct {
+	exporter Exporter
+	// threshold is compared with the random part of trace ids to sample root spans.
+	threshold uint64
+	log       *zap.Logger
+
+	queue   chan SpanData
+	dropped atomic.Int64
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewTracer samples sampleRatio of the traces that start in this process, traces continued from
+// other processes follow their sampling decision.
+func NewTracer(exporter Exporter, sampleRatio float64, log *zap.Logger) *Tracer {
+	t := &Tracer{
+		exporter:  exporter,
+		threshold: ratioThreshold(sampleRatio),
+		log:       log,
+		queue:     make(chan SpanData, queueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+func ratioThreshold(ratio float64) uint64 {
+	switch {
+	case ratio >= 1:
+		return math.MaxUint64
+	case ratio <= 0:
+		return 0
+	default:
+		return uint64(ratio * math.MaxUint64)
+	}
+}
+
+// Start starts a span that is a child of the span of ctx and returns a context that carries it.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (
+	context.Context, *Span) {
+	var parent SpanContext
+	if span := SpanFromContext(ctx); span != nil {
+		parent = span.SpanContext()
+	} else if remote, ok := ctx.Value(remoteKey).(SpanContext); ok {
+		parent = remote
+	}
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = binary.BigEndian.Uint64(sc.TraceID[8:]) < t.threshold || t.threshold == math.MaxUint64
+	}
+
+	span := &Span{tracer: t, data: SpanData{
+		Name:        name,
+		Kind:        kind,
+		SpanContext: sc,
+		Parent:      parent.SpanID,
+		Start:       time.Now(),
+	}}
+	if sc.Sampled {
+		span.data.Attributes = attributes
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, maxBatchSize)
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) < maxBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-t.stop:
+			for {
+				select {
+				case data := <-t.queue:
+					batch = append(batch, data)
+					if len(batch) == maxBatchSize {
+						t.export(batch)
+						batch = batch[:0]
+					}
+				default:
+					t.export(batch)
+					return
+				}
+			}
+		}
+
+		t.export(batch)
+		batch = batch[:0]
+	}
+}
+
+func (t *Tracer) export(batch []SpanData) {
+	if dropped := t.dropped.Swap(0); dropped > 0 {
+		t.log.Warn("Spans dropped, the export queue is full", zap.Int64("count", dropped))
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	if err := t.exporter.Export(ctx, batch); err != nil {
+		t.log.Warn("Failed to export spans", zap.Error(err), zap.Int("count", len(batch)))
+	}
+}
+
+// Shutdown exports the spans that ended so far and releases the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.once.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
@@ -0,0 +1,273 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *memoryExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// ended shuts tracer down and returns the spans it exported.
+func (e *memoryExporter) ended(t *testing.T, tracer *Tracer) []SpanData {
+	t.Helper()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("\nExpected: nil\nGot: %s", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.spans
+}
+
+func TestParseTraceparent(t *testing.T) {
+	type testCase struct {
+		value   string
+		ok      bool
+		sampled bool
+	}
+
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	tests := map[string]testCase{
+		"sampled":         {value: "00-" + traceID + "-" + spanID + "-01", ok: true, sampled: true},
+		"not sampled":     {value: "00-" + traceID + "-" + spanID + "-00", ok: true},
+		"future version":  {value: "cc-" + traceID + "-" + spanID + "-01-extra", ok: true, sampled: true},
+		"extra in 00":     {value: "00-" + traceID + "-" + spanID + "-01-extra"},
+		"invalid version": {value: "ff-" + traceID + "-" + spanID + "-01"},
+		"zero trace id":   {value: "00-00000000000000000000000000000000-" + spanID + "-01"},
+		"zero span id":    {value: "00-" + traceID + "-0000000000000000-01"},
+		"upper case":      {value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01"},
+		"short trace id":  {value: "00-4bf92f35-" + spanID + "-01"},
+		"empty":           {value: ""},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sc, ok := ParseTraceparent(test.value)
+			if ok != test.ok {
+				t.Fatalf("\nExpected: %t\nGot: %t", test.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != test.sampled {
+				t.Errorf("\nExpected: %s %s %t\nGot: %+v", traceID, spanID, test.sampled, sc)
+			}
+			if sc.Traceparent()[3:] != test.value[3:55] {
+				t.Errorf("\nExpected: %s\nGot: %s", test.value[:55], sc.Traceparent())
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	exporter := &memoryExporter{}
+	tracer := NewTracer(exporter, 1, zap.NewNop())
+
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindServer, String("key", "value"))
+	_, child := tracer.Start(ctx, "child", SpanKindInternal)
+	child.RecordError(io.EOF)
+	child.End()
+	root.End()
+	root.End()
+
+	spans := exporter.ended(t, tracer)
+	if len(spans) != 2 {
+		t.Fatalf("\nExpected: 2 spans\nGot: %+v", spans)
+	}
+	if spans[0].Name != "child" || spans[1].Name != "root" {
+		t.Errorf("\nExpected: child, root\nGot: %s, %s", spans[0].Name, spans[1].Name)
+	}
+	if spans[0].SpanContext.TraceID != spans[1].SpanContext.TraceID || spans[0].Parent != spans[1].SpanContext.SpanID {
+		t.Errorf("\nExpected: child of root\nGot: %+v", spans)
+	}
+	if spans[1].Parent.IsValid() || len(spans[1].Attributes) != 1 {
+		t.Errorf("\nExpected: root with an attribute\nGot: %+v", spans[1])
+	}
+	if spans[0].Status != StatusError || spans[0].StatusMessage != io.EOF.Error() {
+		t.Errorf("\nExpected: %s\nGot: %+v", io.EOF, spans[0])
+	}
+}
+
+func TestSampling(t *testing.T) {
+	type testCase struct {
+		ratio       float64
+		traceparent string
+		exported    bool
+	}
+
+	const remote = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-"
+	tests := map[string]testCase{
+		"always":             {ratio: 1, exported: true},
+		"never":              {ratio: 0, exported: false},
+		"remote sampled":     {ratio: 0, traceparent: remote + "01", exported: true},
+		"remote not sampled": {ratio: 1, traceparent: remote + "00", exported: false},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			exporter := &memoryExporter{}
+			tracer := NewTracer(exporter, test.ratio, zap.NewNop())
+
+			header := http.Header{}
+			header.Set(TraceparentHeader, test.traceparent)
+			ctx, span := tracer.Start(Extract(context.Background(), header), "span", SpanKindServer)
+			span.End()
+
+			// Spans that are not sampled are still propagated.
+			outgoing := http.Header{}
+			Inject(ctx, outgoing)
+			sc, ok := ParseTraceparent(outgoing.Get(TraceparentHeader))
+			if !ok || sc.SpanID != span.SpanContext().SpanID || sc.Sampled != test.exported {
+				t.Errorf("\nExpected: traceparent of the span\nGot: %q", outgoing.Get(TraceparentHeader))
+			}
+			if test.traceparent != "" && sc.TraceID.String() != test.traceparent[3:35] {
+				t.Errorf("\nExpected: %s\nGot: %s", test.traceparent[3:35], sc.TraceID)
+			}
+
+			if spans := exporter.ended(t, tracer); (len(spans) == 1) != test.exported {
+				t.Errorf("\nExpected: exported %t\nGot: %+v", test.exported, spans)
+			}
+		})
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&buf, "persons-api"), 1, zap.NewNop())
+
+	ctx, parent := tracer.Start(context.Background(), "GET /api/v1/persons", SpanKindServer)
+	_, span := tracer.Start(ctx, "postgresql SELECT", SpanKindClient, Int64("db.response.rows_affected", 2))
+	span.End()
+	parent.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("\nExpected: nil\nGot: %s", err)
+	}
+
+	var line map[string]any
+	if err := json.NewDecoder(&buf).Decode(&line); err != nil {
+		t.Fatalf("\nExpected: JSON line\nGot: %s", err)
+	}
+	expected := map[string]any{
+		"service":        "persons-api",
+		"name":           "postgresql SELECT",
+		"kind":           "client",
+		"trace_id":       parent.SpanContext().TraceID.String(),
+		"parent_span_id": parent.SpanContext().SpanID.String(),
+	}
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("\nExpected: %s %v\nGot: %v", key, value, line)
+		}
+	}
+	if attributes, _ := line["attributes"].(map[string]any); attributes["db.response.rows_affected"] != 2.0 {
+		t.Errorf("\nExpected: rows affected\nGot: %v", line)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var request otlpRequest
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		} else if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		close(received)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer(NewOTLPExporter(server.URL, "persons-api", time.Second), 1, zap.NewNop())
+	_, span := tracer.Start(context.Background(), "GET /api/v1/persons/{id}", SpanKindServer,
+		String("http.route", "/api/v1/persons/{id}"), Int64("http.response.status_code", 404))
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("\nExpected: nil\nGot: %s", err)
+	}
+	<-received
+
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("\nExpected: 1 resource and scope\nGot: %+v", request)
+	}
+	resource := request.ResourceSpans[0].Resource
+	if len(resource.Attributes) != 1 || resource.Attributes[0].Value["stringValue"] != "persons-api" {
+		t.Errorf("\nExpected: service name\nGot: %+v", resource)
+	}
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("\nExpected: 1 span\nGot: %+v", spans)
+	}
+	got := spans[0]
+	if got.TraceID != span.SpanContext().TraceID.String() || got.Kind != SpanKindServer || got.ParentSpanID != "" {
+		t.Errorf("\nExpected: server root span\nGot: %+v", got)
+	}
+	if len(got.Attributes) != 2 || got.Attributes[1].Value["intValue"] != "404" {
+		t.Errorf("\nExpected: string encoded integer\nGot: %+v", got.Attributes)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(TraceparentHeader)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := &memoryExporter{}
+	tracer := NewTracer(exporter, 1, zap.NewNop())
+	client := &http.Client{Transport: NewTransport(tracer, nil)}
+
+	r, err := http.NewRequest(http.MethodPost, server.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		t.Fatalf("\nExpected: nil\nGot: %s", err)
+	}
+	_ = resp.Body.Close()
+	if r.Header.Get(TraceparentHeader) != "" {
+		t.Errorf("\nExpected: request left unmodified\nGot: %v", r.Header)
+	}
+
+	spans := exporter.ended(t, tracer)
+	if len(spans) != 1 {
+		t.Fatalf("\nExpected: 1 span\nGot: %+v", spans)
+	}
+	if traceparent != spans[0].SpanContext.Traceparent() {
+		t.Errorf("\nExpected: %s\nGot: %s", spans[0].SpanContext.Traceparent(), traceparent)
+	}
+	if spans[0].Kind != SpanKindClient || spans[0].Status != StatusError {
+		t.Errorf("\nExpected: failed client span\nGot: %+v", spans[0])
+	}
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Transport traces outgoing requests and passes their span to the receivers in the traceparent header.
+type Transport struct {
+	tracer *Tracer
+	base   http.RoundTripper
+}
+
+// NewTransport sends requests with base, http.DefaultTransport if it is nil.
+func NewTransport(tracer *Tracer, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{tracer: tracer, base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), req.Method, SpanKindClient,
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Host),
+		String("url.path", req.URL.Path))
+	defer span.End()
+
+	// A round tripper must not modify the request it was given.
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int64("http.response.status_code", int64(resp.StatusCode)))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.RecordError(statusError(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+type statusError int
+
+func (e statusError) Error() string {
+	return "status " + strconv.Itoa(int(e))
+}
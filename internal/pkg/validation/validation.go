@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+// Errors maps a field name to the description of its first failed rule.
+type Errors map[string]string
+
+func (errs Errors) Error() string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(errs))
+	for _, field := range fields {
+		messages = append(messages, field+": "+errs[field])
+	}
+	return pErrors.ErrInvalidData.Error() + ": " + strings.Join(messages, "; ")
+}
+
+// Cause lets errors.Cause resolve validation failures to pErrors.ErrInvalidData.
+func (errs Errors) Cause() error {
+	return pErrors.ErrInvalidData
+}
+
+func (errs Errors) Unwrap() error {
+	return pErrors.ErrInvalidData
+}
+
+// Rule checks a single value and returns a message describing the violation, or "" if the value is valid.
+// Absent optional values (nil pointers) are passed as nil.
+type Rule func(value any) string
+
+type FieldRules struct {
+	name  string
+	value any
+	rules []Rule
+}
+
+func Field(name string, value any, rules ...Rule) FieldRules {
+	return FieldRules{
+		name:  name,
+		value: value,
+		rules: rules,
+	}
+}
+
+// Validate applies rules to every field and returns Errors if at least one of them fails.
+func Validate(fields ...FieldRules) error {
+	errs := Errors{}
+	for _, field := range fields {
+		value := indirect(field.value)
+		for _, rule := range field.rules {
+			if message := rule(value); message != "" {
+				errs[field.name] = message
+				break
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func Required() Rule {
+	return func(value any) string {
+		switch v := value.(type) {
+		case nil:
+			return "is required"
+		case string:
+			if strings.TrimSpace(v) == "" {
+				return "is required"
+			}
+		}
+		return ""
+	}
+}
+
+// NotBlank rejects empty strings but accepts absent values.
+func NotBlank() Rule {
+	return func(value any) string {
+		if v, ok := value.(string); ok && strings.TrimSpace(v) == "" {
+			return "must not be blank"
+		}
+		return ""
+	}
+}
+
+func MinLength(min int) Rule {
+	return func(value any) string {
+		if v, ok := value.(string); ok && utf8.RuneCountInString(v) < min {
+			return fmt.Sprintf("must be at least %d characters long", min)
+		}
+		return ""
+	}
+}
+
+func MaxLength(max int) Rule {
+	return func(value any) string {
+		if v, ok := value.(string); ok && utf8.RuneCountInString(v) > max {
+			return fmt.Sprintf("must be at most %d characters long", max)
+		}
+		return ""
+	}
+}
+
+func Range(min, max int) Rule {
+	return func(value any) string {
+		if v, ok := value.(int); ok && (v < min || v > max) {
+			return fmt.Sprintf("must be between %d and %d", min, max)
+		}
+		return ""
+	}
+}
+
+func indirect(value any) any {
+	switch v := value.(type) {
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return value
+}
@@ -0,0 +1,100 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+)
+
+func TestValidate(t *testing.T) {
+	type testCase struct {
+		fields []FieldRules
+		errs   Errors
+	}
+
+	name := "Johnny"
+	blank := "  "
+	age := 200
+
+	tests := map[string]testCase{
+		"valid": {
+			fields: []FieldRules{
+				Field("name", name, Required(), MaxLength(10)),
+				Field("age", 22, Range(0, 150)),
+			},
+			errs: nil,
+		},
+		"absent optional fields": {
+			fields: []FieldRules{
+				Field("name", (*string)(nil), NotBlank(), MaxLength(10)),
+				Field("age", (*int)(nil), Range(0, 150)),
+			},
+			errs: nil,
+		},
+		"required": {
+			fields: []FieldRules{
+				Field("name", "", Required()),
+				Field("work", (*string)(nil), Required()),
+			},
+			errs: Errors{
+				"name": "is required",
+				"work": "is required",
+			},
+		},
+		"invalid pointers": {
+			fields: []FieldRules{
+				Field("name", &blank, NotBlank()),
+				Field("age", &age, Range(0, 150)),
+			},
+			errs: Errors{
+				"name": "must not be blank",
+				"age":  "must be between 0 and 150",
+			},
+		},
+		"first failed rule wins": {
+			fields: []FieldRules{
+				Field("name", "", Required(), MaxLength(0)),
+				Field("address", "Moscow", MaxLength(3)),
+			},
+			errs: Errors{
+				"name":    "is required",
+				"address": "must be at most 3 characters long",
+			},
+		},
+		"length bounds": {
+			fields: []FieldRules{
+				Field("secret", "short", MinLength(16)),
+				Field("name", "Johnny", MinLength(6), MaxLength(6)),
+			},
+			errs: Errors{
+				"secret": "must be at least 16 characters long",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Validate(test.fields...)
+			if test.errs == nil {
+				if err != nil {
+					t.Errorf("\nExpected: nil\nGot: %s", err)
+				}
+				return
+			}
+
+			if errors.Cause(err) != pErrors.ErrInvalidData || !errors.Is(err, pErrors.ErrInvalidData) {
+				t.Errorf("\nExpected: %s\nGot: %s", pErrors.ErrInvalidData, err)
+			}
+			var errs Errors
+			if !errors.As(err, &errs) || !reflect.DeepEqual(errs, test.errs) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.errs, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps buckets in process, each service instance limits the requests it gets on its own.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	var allowed bool
+	b.tokens, allowed = TakeToken(b.tokens, now.Sub(b.updated), limit)
+	b.updated = now
+	return NewResult(b.tokens, allowed, limit), nil
+}
+
+func (m *Memory) DeleteIdle(_ context.Context, idle time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	idleSince := m.now().Add(-idle)
+	for key, b := range m.buckets {
+		if b.updated.Before(idleSince) {
+			delete(m.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
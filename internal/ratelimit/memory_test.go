@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+
+	take := func(key string) Result {
+		t.Helper()
+		result, err := m.Take(context.Background(), key, limit)
+		if err != nil {
+			t.Fatalf("\nExpected: %v\nGot: %v", nil, err)
+		}
+		return result
+	}
+
+	for i := 2; i >= 0; i-- {
+		result := take("a")
+		if !result.Allowed || result.Remaining != i || result.Limit != 3 {
+			t.Fatalf("\nExpected: allowed with %d remaining\nGot: %+v", i, result)
+		}
+	}
+
+	result := take("a")
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond || result.Reset != 1500*time.Millisecond {
+		t.Errorf("\nExpected: rejected, retry after 500ms, reset in 1.5s\nGot: %+v", result)
+	}
+	if result := take("b"); !result.Allowed {
+		t.Errorf("\nExpected: allowed\nGot: %+v", result)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if result := take("a"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("\nExpected: allowed with 0 remaining\nGot: %+v", result)
+	}
+
+	now = now.Add(time.Hour)
+	if result := take("a"); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("\nExpected: allowed with 2 remaining\nGot: %+v", result)
+	}
+
+	now = now.Add(time.Minute)
+	deleted, err := m.DeleteIdle(context.Background(), 30*time.Minute)
+	if err != nil || deleted != 1 {
+		t.Errorf("\nExpected: 1 deleted\nGot: %d, %v", deleted, err)
+	}
+	if _, ok := m.buckets["a"]; !ok {
+		t.Errorf("\nExpected: bucket a is kept\nGot: %v", m.buckets)
+	}
+}
+
+func TestTakeToken(t *testing.T) {
+	type testCase struct {
+		tokens  float64
+		elapsed time.Duration
+		left    float64
+		allowed bool
+	}
+
+	limit := Limit{Rate: 0.5, Burst: 4}
+	tests := map[string]testCase{
+		"full":            {tokens: 4, elapsed: 0, left: 3, allowed: true},
+		"refilled":        {tokens: 0.5, elapsed: time.Second, left: 0, allowed: true},
+		"capped at burst": {tokens: 2, elapsed: time.Hour, left: 3, allowed: true},
+		"empty":           {tokens: 0.25, elapsed: time.Second, left: 0.75, allowed: false},
+		"clock went back": {tokens: 0.5, elapsed: -time.Hour, left: 0.5, allowed: false},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			left, allowed := TakeToken(test.tokens, test.elapsed, limit)
+			if left != test.left || allowed != test.allowed {
+				t.Errorf("\nExpected: %v, %t\nGot: %v, %t", test.left, test.allowed, left, allowed)
+			}
+		})
+	}
+}
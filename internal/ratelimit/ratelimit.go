@@ -0,0 +1,68 @@
+// Package ratelimit implements token bucket rate limits kept in a pluggable store.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Store names, see the RATE_LIMIT_STORE setting.
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Limit is a token bucket that holds up to Burst tokens and gains Rate tokens per second. Every request takes
+// a token, so Burst requests may come at once and Rate requests per second are allowed on average.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the state of a bucket after a request tried to take a token from it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is the time until a token is available, it is set for requests that were not allowed.
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+}
+
+type Store interface {
+	// Take takes a token from the bucket of key.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// DeleteIdle forgets the buckets that were not used for idle, which must be long enough for them
+	// to have been refilled.
+	DeleteIdle(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// TakeToken refills a bucket that had tokens elapsed ago and takes a token from it if there is one.
+// It returns the tokens left and whether a token was taken.
+func TakeToken(tokens float64, elapsed time.Duration, limit Limit) (float64, bool) {
+	tokens = math.Min(float64(limit.Burst), tokens+max(elapsed.Seconds(), 0)*limit.Rate)
+	if tokens < 1 {
+		return tokens, false
+	}
+	return tokens - 1, true
+}
+
+// NewResult describes a bucket with tokens left after a request that was allowed or not.
+func NewResult(tokens float64, allowed bool, limit Limit) Result {
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
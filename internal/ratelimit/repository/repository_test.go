@@ -0,0 +1,104 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	pkgErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/ratelimit"
+	"github.com/SlavaShagalov/ds-lab1/internal/ratelimit/repository/std"
+)
+
+func TestTake(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		result  ratelimit.Result
+		err     error
+	}
+
+	// The statement is long, its start is enough to tell it apart.
+	takeCmd := regexp.QuoteMeta(`
+	INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+	VALUES ($1, $3::double precision - 1, true, statement_timestamp())
+	ON CONFLICT (key) DO UPDATE`)
+
+	limit := ratelimit.Limit{Rate: 2, Burst: 10}
+	columns := []string{"tokens", "allowed"}
+
+	tests := map[string]testCase{
+		"allowed": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(takeCmd).
+					WithArgs("client:10.0.0.1", 2.0, 10).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(7.5, true))
+			},
+			result: ratelimit.Result{Allowed: true, Limit: 10, Remaining: 7, Reset: 1250 * time.Millisecond},
+			err:    nil,
+		},
+		"rejected": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(takeCmd).
+					WithArgs("client:10.0.0.1", 2.0, 10).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(0.5, false))
+			},
+			result: ratelimit.Result{Allowed: false, Limit: 10, Remaining: 0, RetryAfter: 250 * time.Millisecond,
+				Reset: 4750 * time.Millisecond},
+			err: nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(takeCmd).
+					WithArgs("client:10.0.0.1", 2.0, 10).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			result: ratelimit.Result{},
+			err:    pkgErrors.ErrDb,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("can't create mock: %s", err)
+			}
+			defer db.Close()
+
+			repo := std.New(db, zap.NewNop())
+
+			f := fields{mock: mock}
+			if test.prepare != nil {
+				test.prepare(&f)
+			}
+
+			result, err := repo.Take(context.TODO(), "client:10.0.0.1", limit)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if !reflect.DeepEqual(result, test.result) {
+				t.Errorf("\nExpected: %+v\nGot: %+v", test.result, result)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("\nThere were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
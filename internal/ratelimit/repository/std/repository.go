@@ -0,0 +1,93 @@
+package std
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+	"github.com/SlavaShagalov/ds-lab1/internal/ratelimit"
+)
+
+type timeouts struct {
+	get   time.Duration
+	purge time.Duration
+}
+
+type repository struct {
+	db       *sql.DB
+	log      *zap.Logger
+	timeouts timeouts
+}
+
+// New returns a store that keeps buckets in Postgres, so that they are shared by all service instances.
+func New(db *sql.DB, log *zap.Logger) ratelimit.Store {
+	return &repository{
+		db:  db,
+		log: log,
+		timeouts: timeouts{
+			get:   viper.GetDuration(config.PostgresGetTimeout),
+			purge: viper.GetDuration(config.PostgresPurgeTimeout),
+		},
+	}
+}
+
+// refilledTokens is the number of tokens in the bucket once it is refilled for the time since its last use.
+const refilledTokens = `LEAST($3::double precision,
+		b.tokens + GREATEST(EXTRACT(EPOCH FROM statement_timestamp() - b.updated_at), 0) * $2::double precision)`
+
+// takeCmd refills the bucket and takes a token in one statement, the row lock serializes concurrent requests
+// of the same key. The clock of the database is used, so that instances with skewed clocks agree.
+const takeCmd = `
+	INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+	VALUES ($1, $3::double precision - 1, true, statement_timestamp())
+	ON CONFLICT (key) DO UPDATE
+	SET tokens = ` + refilledTokens + ` - (` + refilledTokens + ` >= 1)::integer,
+		allowed = ` + refilledTokens + ` >= 1,
+		updated_at = statement_timestamp()
+	RETURNING tokens, allowed;`
+
+func (repo *repository) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.get)
+	defer cancel()
+
+	var (
+		tokens  float64
+		allowed bool
+	)
+	err := repo.db.QueryRowContext(ctx, takeCmd, key, limit.Rate, limit.Burst).Scan(&tokens, &allowed)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", takeCmd))
+		return ratelimit.Result{}, postgres.QueryError(ctx, err)
+	}
+	return ratelimit.NewResult(tokens, allowed, limit), nil
+}
+
+const deleteIdleCmd = `
+	DELETE FROM rate_limit_buckets
+	WHERE updated_at < statement_timestamp() - $1 * interval '1 millisecond';`
+
+func (repo *repository) DeleteIdle(ctx context.Context, idle time.Duration) (int64, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.purge)
+	defer cancel()
+
+	result, err := repo.db.ExecContext(ctx, deleteIdleCmd, idle.Milliseconds())
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", deleteIdleCmd))
+		return 0, postgres.QueryError(ctx, err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// logger returns the logger of the request that ctx belongs to.
+func (repo *repository) logger(ctx context.Context) *zap.Logger {
+	return requestinfo.Logger(ctx, repo.log)
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Sweeper periodically removes buckets that are no longer used, so that the store does not grow
+// with every client ever seen.
+type Sweeper struct {
+	store    Store
+	idle     time.Duration
+	interval time.Duration
+	log      *zap.Logger
+}
+
+func NewSweeper(store Store, idle, interval time.Duration, log *zap.Logger) *Sweeper {
+	return &Sweeper{
+		store:    store,
+		idle:     idle,
+		interval: interval,
+		log:      log,
+	}
+}
+
+// Run removes idle buckets once per interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := s.store.DeleteIdle(ctx, s.idle)
+		if err != nil && ctx.Err() == nil {
+			s.log.Error("Failed to delete idle rate limit buckets", zap.Error(err))
+		} else if deleted > 0 {
+			s.log.Debug("Idle rate limit buckets deleted", zap.Int64("deleted", deleted))
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/cursor"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pHTTP "github.com/SlavaShagalov/ds-lab1/internal/pkg/http"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	pWebhooks "github.com/SlavaShagalov/ds-lab1/internal/webhooks"
+)
+
+const (
+	webhooksPrefix = "/webhooks"
+
+	webhooksPath   = constants.ApiPrefix + webhooksPrefix
+	webhookPath    = webhooksPath + "/{id:[0-9]+}"
+	deliveriesPath = webhookPath + "/deliveries"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 1000
+)
+
+type delivery struct {
+	repo       pWebhooks.Repository
+	cursors    *cursor.Codec
+	adminToken string
+	log        *zap.Logger
+}
+
+// RegisterHandlers registers the webhook endpoints, which are for admins only: deliveries are posted to any
+// URL and their log reports how it responded, so webhooks could be used to probe the network of the service.
+func RegisterHandlers(mux *mux.Router, repo pWebhooks.Repository, cursors *cursor.Codec, adminToken string,
+	log *zap.Logger) {
+	del := delivery{
+		repo:       repo,
+		cursors:    cursors,
+		adminToken: adminToken,
+		log:        log,
+	}
+
+	mux.Handle(webhooksPath, del.adminOnly(del.create)).Methods(http.MethodPost)
+	mux.Handle(webhookPath, del.adminOnly(del.get)).Methods(http.MethodGet)
+	mux.Handle(webhookPath, del.adminOnly(del.delete)).Methods(http.MethodDelete)
+	mux.Handle(deliveriesPath, del.adminOnly(del.deliveries)).Methods(http.MethodGet)
+}
+
+func (del *delivery) adminOnly(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !pHTTP.IsAdmin(r, del.adminToken) {
+			pHTTP.HandleError(w, r, errors.Wrap(pErrors.ErrForbidden, "webhooks are for admins only"))
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// create godoc
+//
+//	@Summary		Register a webhook
+//	@Description	Subscribes url to person events. Every delivery is a POST of the event with
+//	@Description	X-Webhook-Timestamp and X-Webhook-Signature headers, the signature is
+//	@Description	sha256=hex(HMAC-SHA256(secret, "<timestamp>.<body>")). Failed deliveries are retried
+//	@Description	with exponential backoff and dead-lettered after too many attempts.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			WebhookCreateData	body		createRequest	true	"URL, event types (empty for all) and secret"
+//	@Success		201					{object}	webhookResponse	"Created webhook"
+//	@Failure		400					{object}	http.ValidationErrorResponse
+//	@Failure		401					{object}	http.JSONError
+//	@Failure		403					{object}	http.JSONError	"Request without the admin token"
+//	@Failure		405
+//	@Failure		500
+//	@Router			/webhooks [post]
+//
+//	@Security		cookieAuth
+func (del *delivery) create(w http.ResponseWriter, r *http.Request) {
+	body, err := pHTTP.ReadBody(r, del.logger(r))
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	var request createRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		pHTTP.HandleError(w, r, pErrors.ErrReadBody)
+		return
+	}
+
+	params := request.params()
+	err = params.Validate()
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	webhook, err := del.repo.Create(r.Context(), params)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Location", fmt.Sprintf(webhooksPath+"/%d", webhook.ID))
+	response := newWebhookResponse(webhook)
+	pHTTP.SendJSON(w, r, http.StatusCreated, response)
+}
+
+// get godoc
+//
+//	@Summary		Returns webhook by id
+//	@Description	Returns webhook by id, the secret is never returned
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id	path		int				true	"Webhook ID"
+//	@Success		200	{object}	webhookResponse	"Webhook data"
+//	@Failure		400	{object}	http.JSONError
+//	@Failure		401	{object}	http.JSONError
+//	@Failure		403	{object}	http.JSONError	"Request without the admin token"
+//	@Failure		404	{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/webhooks/{id} [get]
+//
+//	@Security		cookieAuth
+func (del *delivery) get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	webhook, err := del.repo.Get(r.Context(), webhookID)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	response := newWebhookResponse(webhook)
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
+}
+
+// delete godoc
+//
+//	@Summary		Delete webhook by id
+//	@Description	Unsubscribes the webhook, its pending deliveries are dropped
+//	@Tags			webhooks
+//	@Param			id	path	int	true	"Webhook ID"
+//	@Success		204	"Webhook deleted successfully"
+//	@Failure		400	{object}	http.JSONError
+//	@Failure		401	{object}	http.JSONError
+//	@Failure		403	{object}	http.JSONError	"Request without the admin token"
+//	@Failure		404	{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/webhooks/{id} [delete]
+//
+//	@Security		cookieAuth
+func (del *delivery) delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	err = del.repo.Delete(r.Context(), webhookID)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deliveries godoc
+//
+//	@Summary		Returns webhook deliveries
+//	@Description	Returns the delivery log of a webhook, newest deliveries first. Status is pending
+//	@Description	(waiting for the next attempt), delivered or dead (no more attempts).
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id		path		int					true	"Webhook ID"
+//	@Param			limit	query		int					false	"Page size"
+//	@Param			cursor	query		string				false	"next_cursor of the previous page"
+//	@Success		200		{object}	deliveriesResponse	"Webhook deliveries"
+//	@Failure		400		{object}	http.JSONError
+//	@Failure		401		{object}	http.JSONError
+//	@Failure		403		{object}	http.JSONError	"Request without the admin token"
+//	@Failure		404		{object}	http.JSONError
+//	@Failure		405
+//	@Failure		500
+//	@Router			/webhooks/{id}/deliveries [get]
+//
+//	@Security		cookieAuth
+func (del *delivery) deliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	limit, err := parseInt64Param(queryParams, "limit")
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	var current deliveriesCursor
+	if token := queryParams.Get("cursor"); token != "" {
+		err = del.cursors.Decode(deliveriesCursorPurpose, token, &current)
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+	}
+
+	// An unknown webhook is reported as such rather than as an empty log.
+	_, err = del.repo.Get(r.Context(), webhookID)
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	deliveries, err := del.repo.Deliveries(r.Context(), &pWebhooks.DeliveriesParams{
+		WebhookID: webhookID,
+		BeforeID:  current.ID,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		pHTTP.HandleError(w, r, err)
+		return
+	}
+
+	response := newDeliveriesResponse(deliveries)
+	if int64(len(deliveries)) > limit {
+		response.Deliveries = deliveries[:limit]
+		token, err := del.cursors.Encode(deliveriesCursorPurpose, deliveriesCursor{ID: deliveries[limit-1].ID})
+		if err != nil {
+			pHTTP.HandleError(w, r, err)
+			return
+		}
+		response.NextCursor = &token
+	}
+
+	pHTTP.SendJSON(w, r, http.StatusOK, response)
+}
+
+func parseInt64Param(queryParams url.Values, name string) (int64, error) {
+	if queryParams.Get(name) == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(queryParams.Get(name), 10, 64)
+	if err != nil || value < 0 {
+		return 0, errors.Wrapf(pErrors.ErrBadQueryParam, "%s must be a non-negative integer", name)
+	}
+	return value, nil
+}
+
+// logger returns the logger of the request.
+func (del *delivery) logger(r *http.Request) *zap.Logger {
+	return requestinfo.Logger(r.Context(), del.log)
+}
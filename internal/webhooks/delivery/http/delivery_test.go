@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pWebhooks "github.com/SlavaShagalov/ds-lab1/internal/webhooks"
+)
+
+// repository has a single webhook.
+type repository struct {
+	pWebhooks.Repository
+}
+
+func (repository) Get(context.Context, int64) (*models.Webhook, error) {
+	return &models.Webhook{ID: 2, URL: "https://example.com/events"}, nil
+}
+
+func TestAdminOnly(t *testing.T) {
+	type testCase struct {
+		adminToken    string
+		authorization string
+		status        int
+	}
+
+	tests := map[string]testCase{
+		"admin": {
+			adminToken:    "token",
+			authorization: "Bearer token",
+			status:        http.StatusOK,
+		},
+		"wrong token": {
+			adminToken:    "token",
+			authorization: "Bearer other",
+			status:        http.StatusForbidden,
+		},
+		"no token": {
+			adminToken: "token",
+			status:     http.StatusForbidden,
+		},
+		"admin access disabled": {
+			adminToken:    "",
+			authorization: "Bearer ",
+			status:        http.StatusForbidden,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			router := mux.NewRouter()
+			RegisterHandlers(router, repository{}, nil, test.adminToken, zap.NewNop())
+
+			r := httptest.NewRequest(http.MethodGet, webhooksPath+"/2", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Errorf("\nExpected: %d\nGot: %d %s", test.status, w.Code, w.Body)
+			}
+		})
+	}
+}
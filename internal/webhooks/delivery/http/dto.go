@@ -0,0 +1,62 @@
+package http
+
+import (
+	"time"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pWebhooks "github.com/SlavaShagalov/ds-lab1/internal/webhooks"
+)
+
+// deliveriesCursorPurpose binds deliveries cursors to the listing that issued them.
+const deliveriesCursorPurpose = "webhooks.deliveries"
+
+// deliveriesCursor is the state behind opaque next_cursor tokens, ID is the last delivery of the page.
+type deliveriesCursor struct {
+	ID int64 `json:"id"`
+}
+
+// API requests
+type createRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+func (req *createRequest) params() *pWebhooks.CreateParams {
+	return &pWebhooks.CreateParams{
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     req.Secret,
+	}
+}
+
+// API responses
+type webhookResponse struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newWebhookResponse(webhook *models.Webhook) *webhookResponse {
+	return &webhookResponse{
+		ID:         webhook.ID,
+		URL:        webhook.URL,
+		EventTypes: webhook.EventTypes,
+		CreatedAt:  webhook.CreatedAt,
+	}
+}
+
+type deliveriesResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+	NextCursor *string                  `json:"next_cursor"`
+}
+
+func newDeliveriesResponse(deliveries []models.WebhookDelivery) *deliveriesResponse {
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	return &deliveriesResponse{
+		Deliveries: deliveries,
+	}
+}
@@ -0,0 +1,40 @@
+// Package dispatch delivers person events to the webhooks subscribed to them.
+package dispatch
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+)
+
+// Enqueuer turns an event into deliveries to the subscribed webhooks.
+type Enqueuer interface {
+	Enqueue(ctx context.Context, event *models.PersonEvent) (int64, error)
+}
+
+// Dispatcher is the publisher of the outbox relay that hands events over to the Sender. Deliveries are queued
+// in the database, so a failing receiver neither blocks the outbox nor affects the other webhooks.
+type Dispatcher struct {
+	enqueuer Enqueuer
+	log      *zap.Logger
+}
+
+func NewDispatcher(enqueuer Enqueuer, log *zap.Logger) *Dispatcher {
+	return &Dispatcher{
+		enqueuer: enqueuer,
+		log:      log,
+	}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event *models.PersonEvent) error {
+	enqueued, err := d.enqueuer.Enqueue(ctx, event)
+	if err != nil {
+		return err
+	}
+	if enqueued > 0 {
+		d.log.Debug("Webhook deliveries enqueued", zap.Int64("event_id", event.ID), zap.Int64("count", enqueued))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/backoff"
+	pWebhooks "github.com/SlavaShagalov/ds-lab1/internal/webhooks"
+)
+
+// Store is the queue of webhook deliveries.
+type Store interface {
+	ClaimDeliveries(ctx context.Context, limit int64, lease time.Duration) ([]pWebhooks.PendingDelivery, error)
+	RecordAttempt(ctx context.Context, attempt *pWebhooks.Attempt) error
+}
+
+type Config struct {
+	// Interval is how often the queue is polled for due deliveries.
+	Interval time.Duration
+	// BatchSize is the number of deliveries claimed and sent concurrently.
+	BatchSize int64
+	// Lease is how long claimed deliveries stay hidden from other senders, it must exceed Timeout.
+	Lease time.Duration
+	// Timeout limits a single request to a receiver.
+	Timeout time.Duration
+	// MinBackoff and MaxBackoff bound the delay between attempts, which doubles with every failed attempt.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of failed attempts after which a delivery is dead-lettered.
+	MaxAttempts int
+	// Transport sends the requests, PublicTransport if nil.
+	Transport http.RoundTripper
+}
+
+// Sender sends the queued deliveries as signed POST requests. Any 2xx response acknowledges a delivery,
+// anything else is retried with exponential backoff until the delivery runs out of attempts.
+type Sender struct {
+	store  Store
+	client *http.Client
+	config Config
+	now    func() time.Time
+	log    *zap.Logger
+}
+
+func NewSender(store Store, config Config, log *zap.Logger) *Sender {
+	if config.Transport == nil {
+		config.Transport = PublicTransport()
+	}
+	return &Sender{
+		store:  store,
+		client: &http.Client{Timeout: config.Timeout, Transport: config.Transport},
+		config: config,
+		now:    time.Now,
+		log:    log,
+	}
+}
+
+// Run sends due deliveries right away and then once per interval until ctx is done.
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		delivered, err := s.SendPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.log.Error("Failed to send webhook deliveries", zap.Int("delivered", delivered), zap.Error(err))
+		} else if delivered > 0 {
+			s.log.Debug("Webhook deliveries sent", zap.Int("delivered", delivered))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendPending sends due deliveries in batches until none are left and returns the number of acknowledged ones.
+func (s *Sender) SendPending(ctx context.Context) (int, error) {
+	var total int
+	for {
+		deliveries, err := s.store.ClaimDeliveries(ctx, s.config.BatchSize, s.config.Lease)
+		if err != nil {
+			return total, err
+		}
+
+		attempts := make([]pWebhooks.Attempt, len(deliveries))
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				attempts[i] = s.send(ctx, &deliveries[i])
+			}()
+		}
+		wg.Wait()
+
+		if ctx.Err() != nil {
+			// The lease runs out and the deliveries are claimed again.
+			return total, ctx.Err()
+		}
+		for i := range attempts {
+			err = s.store.RecordAttempt(ctx, &attempts[i])
+			if err != nil {
+				return total, err
+			}
+			if attempts[i].Status == models.DeliveryDelivered {
+				total++
+			}
+		}
+
+		if int64(len(deliveries)) < s.config.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+func (s *Sender) send(ctx context.Context, delivery *pWebhooks.PendingDelivery) pWebhooks.Attempt {
+	attempt := pWebhooks.Attempt{DeliveryID: delivery.ID}
+
+	status, err := s.post(ctx, delivery)
+	if status != 0 {
+		attempt.ResponseStatus = &status
+	}
+	if err == nil {
+		attempt.Status = models.DeliveryDelivered
+		return attempt
+	}
+
+	reason := err.Error()
+	attempt.Error = &reason
+	if delivery.Attempts >= s.config.MaxAttempts {
+		attempt.Status = models.DeliveryDead
+		s.log.Warn("Webhook delivery dead-lettered",
+			zap.Int64("delivery_id", delivery.ID),
+			zap.Int64("webhook_id", delivery.WebhookID),
+			zap.Int("attempts", delivery.Attempts),
+			zap.Error(err))
+		return attempt
+	}
+
+	attempt.Status = models.DeliveryPending
+	attempt.RetryIn = backoff.Exponential(delivery.Attempts, s.config.MinBackoff, s.config.MaxBackoff)
+	s.log.Debug("Webhook delivery failed",
+		zap.Int64("delivery_id", delivery.ID),
+		zap.Int64("webhook_id", delivery.WebhookID),
+		zap.Int("attempts", delivery.Attempts),
+		zap.Duration("retry_in", attempt.RetryIn),
+		zap.Error(err))
+	return attempt
+}
+
+// post sends the delivery and returns the response status, 0 if there was no response.
+func (s *Sender) post(ctx context.Context, delivery *pWebhooks.PendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := s.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, strconv.FormatInt(delivery.WebhookID, 10))
+	req.Header.Set(DeliveryIDHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Draining the body lets the connection be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,212 @@
+package dispatch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pWebhooks "github.com/SlavaShagalov/ds-lab1/internal/webhooks"
+)
+
+const secret = "0123456789abcdef"
+
+// store hands out the pending deliveries once and records the attempts.
+type store struct {
+	mu       sync.Mutex
+	pending  []pWebhooks.PendingDelivery
+	attempts []pWebhooks.Attempt
+}
+
+func (s *store) ClaimDeliveries(_ context.Context, limit int64, _ time.Duration) (
+	[]pWebhooks.PendingDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := s.pending[:min(int64(len(s.pending)), limit)]
+	s.pending = s.pending[len(claimed):]
+	return claimed, nil
+}
+
+func (s *store) RecordAttempt(_ context.Context, attempt *pWebhooks.Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts = append(s.attempts, *attempt)
+	return nil
+}
+
+// receiver is a webhook endpoint that checks signatures and responds with status.
+func receiver(t *testing.T, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("\nCan't read delivery: %s", err)
+		}
+		timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if err != nil {
+			t.Errorf("\nBad timestamp header: %s", err)
+		}
+		if !Verify(secret, timestamp, body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("\nBad signature %q of %s", r.Header.Get(SignatureHeader), body)
+		}
+		if r.Header.Get(EventTypeHeader) != models.EventPersonCreated || r.Header.Get(DeliveryIDHeader) != "9" {
+			t.Errorf("\nUnexpected delivery headers: %v", r.Header)
+		}
+		w.WriteHeader(status)
+	}))
+}
+
+func TestSendPending(t *testing.T) {
+	type testCase struct {
+		status   int
+		attempts int
+		// down makes the receiver unreachable.
+		down bool
+		// public sends with the default transport, which refuses the receiver on loopback.
+		public  bool
+		attempt pWebhooks.Attempt
+	}
+
+	intPtr := func(v int) *int { return &v }
+	stringPtr := func(v string) *string { return &v }
+
+	tests := map[string]testCase{
+		"delivered": {
+			status:   http.StatusNoContent,
+			attempts: 1,
+			attempt: pWebhooks.Attempt{
+				DeliveryID:     9,
+				Status:         models.DeliveryDelivered,
+				ResponseStatus: intPtr(http.StatusNoContent),
+			},
+		},
+		"receiver error": {
+			status:   http.StatusInternalServerError,
+			attempts: 3,
+			attempt: pWebhooks.Attempt{
+				DeliveryID:     9,
+				Status:         models.DeliveryPending,
+				RetryIn:        4 * time.Second,
+				ResponseStatus: intPtr(http.StatusInternalServerError),
+				Error:          stringPtr("receiver responded with status 500"),
+			},
+		},
+		"last attempt": {
+			status:   http.StatusBadRequest,
+			attempts: 5,
+			attempt: pWebhooks.Attempt{
+				DeliveryID:     9,
+				Status:         models.DeliveryDead,
+				ResponseStatus: intPtr(http.StatusBadRequest),
+				Error:          stringPtr("receiver responded with status 400"),
+			},
+		},
+		"receiver down": {
+			down:     true,
+			attempts: 1,
+			attempt: pWebhooks.Attempt{
+				DeliveryID: 9,
+				Status:     models.DeliveryPending,
+				RetryIn:    time.Second,
+			},
+		},
+		"private receiver": {
+			status:   http.StatusNoContent,
+			public:   true,
+			attempts: 1,
+			attempt: pWebhooks.Attempt{
+				DeliveryID: 9,
+				Status:     models.DeliveryPending,
+				RetryIn:    time.Second,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := receiver(t, test.status)
+			if test.down {
+				server.Close()
+			} else {
+				defer server.Close()
+			}
+
+			s := &store{pending: []pWebhooks.PendingDelivery{{
+				WebhookDelivery: models.WebhookDelivery{
+					ID:        9,
+					WebhookID: 2,
+					EventID:   40,
+					EventType: models.EventPersonCreated,
+					Payload:   []byte(`{"id":40,"type":"PersonCreated","person_id":3}`),
+					Status:    models.DeliveryPending,
+					Attempts:  test.attempts,
+				},
+				URL:    server.URL,
+				Secret: secret,
+			}}}
+			config := Config{
+				BatchSize:   10,
+				Timeout:     time.Second,
+				MinBackoff:  time.Second,
+				MaxBackoff:  time.Minute,
+				MaxAttempts: 5,
+				Transport:   http.DefaultTransport,
+			}
+			if test.public {
+				config.Transport = nil
+			}
+			sender := NewSender(s, config, zap.NewNop())
+
+			delivered, err := sender.SendPending(context.TODO())
+			if err != nil {
+				t.Fatalf("\nExpected: %v\nGot: %s", nil, err)
+			}
+			if expected := test.attempt.Status == models.DeliveryDelivered; (delivered == 1) != expected {
+				t.Errorf("\nExpected delivered: %t\nGot: %d", expected, delivered)
+			}
+			if len(s.attempts) != 1 {
+				t.Fatalf("\nExpected: 1 attempt\nGot: %v", s.attempts)
+			}
+
+			attempt := s.attempts[0]
+			if test.down {
+				// The transport error depends on the platform.
+				if attempt.Error == nil {
+					t.Errorf("\nExpected: an error\nGot: %v", nil)
+				}
+				attempt.Error = nil
+			}
+			if test.public {
+				if attempt.Error == nil || !strings.Contains(*attempt.Error, "127.0.0.1 is not a public address") {
+					t.Errorf("\nExpected: refused private address\nGot: %v", attempt.Error)
+				}
+				attempt.Error = nil
+			}
+			if attempt.DeliveryID != test.attempt.DeliveryID || attempt.Status != test.attempt.Status ||
+				attempt.RetryIn != test.attempt.RetryIn ||
+				!equal(attempt.ResponseStatus, test.attempt.ResponseStatus) ||
+				!equal(attempt.Error, test.attempt.Error) {
+				t.Errorf("\nExpected: %+v\nGot: %+v", test.attempt, attempt)
+			}
+		})
+	}
+}
+
+func equal[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
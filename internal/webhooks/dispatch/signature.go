@@ -0,0 +1,35 @@
+package dispatch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Headers of every delivery request.
+const (
+	WebhookIDHeader  = "X-Webhook-ID"
+	DeliveryIDHeader = "X-Webhook-Delivery"
+	EventTypeHeader  = "X-Event-Type"
+	TimestampHeader  = "X-Webhook-Timestamp"
+	SignatureHeader  = "X-Webhook-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the signature header of a delivery body sent at timestamp (Unix seconds): the hex-encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret. Signing the timestamp lets receivers
+// reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body sent at timestamp, it is what receivers do.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
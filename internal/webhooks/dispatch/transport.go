@@ -0,0 +1,51 @@
+package dispatch
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, which clusters use for internal addresses too.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicTransport returns a transport that only connects to public addresses, so that webhooks can not reach
+// loopback, link-local (such as cloud metadata services) or private hosts. The check is made on the address
+// that is dialed, after the host name is resolved, so that a name can not be pointed at a private address.
+func PublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   refusePrivate,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would be dialed instead of the receiver.
+	transport.Proxy = nil
+	return transport
+}
+
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addr) {
+		return errors.Errorf("%s is not a public address", addr)
+	}
+	return nil
+}
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
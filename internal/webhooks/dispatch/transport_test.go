@@ -0,0 +1,37 @@
+package dispatch
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14":        true,
+		"2606:2800:21f:cb07::": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"169.254.169.254":      false,
+		"10.0.0.5":             false,
+		"172.20.1.1":           false,
+		"192.168.1.10":         false,
+		"100.64.3.2":           false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"0.0.0.0":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.215.14": true,
+		"224.0.0.1":            false,
+	}
+
+	for addr, expected := range tests {
+		addr, expected := addr, expected
+		t.Run(addr, func(t *testing.T) {
+			t.Parallel()
+
+			if public := isPublic(netip.MustParseAddr(addr)); public != expected {
+				t.Errorf("\nExpected: %t\nGot: %t", expected, public)
+			}
+		})
+	}
+}
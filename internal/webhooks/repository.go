@@ -0,0 +1,60 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+)
+
+type CreateParams struct {
+	URL string
+	// EventTypes filters the delivered events, empty subscribes to all of them.
+	EventTypes []string
+	Secret     string
+}
+
+type DeliveriesParams struct {
+	WebhookID int64
+	// BeforeID continues the log with deliveries older than the delivery with this id, 0 starts from the latest.
+	BeforeID int64
+	// Limit of 0 means no limit.
+	Limit int64
+}
+
+// PendingDelivery is a claimed delivery along with the webhook it goes to.
+type PendingDelivery struct {
+	models.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// Attempt is the outcome of sending a delivery.
+type Attempt struct {
+	DeliveryID int64
+	// Status is models.DeliveryDelivered, models.DeliveryPending to try again after RetryIn
+	// or models.DeliveryDead to give up.
+	Status  string
+	RetryIn time.Duration
+	// ResponseStatus is nil if the receiver did not respond.
+	ResponseStatus *int
+	// Error is nil if the delivery succeeded.
+	Error *string
+}
+
+type Repository interface {
+	Create(ctx context.Context, params *CreateParams) (*models.Webhook, error)
+	Get(ctx context.Context, webhookID int64) (*models.Webhook, error)
+	// Delete removes the webhook along with its deliveries.
+	Delete(ctx context.Context, webhookID int64) error
+	// Deliveries returns the delivery log of a webhook, newest deliveries first.
+	Deliveries(ctx context.Context, params *DeliveriesParams) ([]models.WebhookDelivery, error)
+
+	// Enqueue creates a delivery of the event for every webhook subscribed to its type and returns their number.
+	// Enqueueing an event again creates no duplicate deliveries.
+	Enqueue(ctx context.Context, event *models.PersonEvent) (int64, error)
+	// ClaimDeliveries leases at most limit deliveries that are due, oldest first, and counts the attempt.
+	// Deliveries without a recorded attempt by the end of the lease are claimed again.
+	ClaimDeliveries(ctx context.Context, limit int64, lease time.Duration) ([]PendingDelivery, error)
+	RecordAttempt(ctx context.Context, attempt *Attempt) error
+}
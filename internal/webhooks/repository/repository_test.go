@@ -0,0 +1,361 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	pkgErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	pWebhooks "github.com/SlavaShagalov/ds-lab1/internal/webhooks"
+	"github.com/SlavaShagalov/ds-lab1/internal/webhooks/repository/std"
+)
+
+func TestCreate(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		params  pWebhooks.CreateParams
+		webhook *models.Webhook
+		err     error
+	}
+
+	const createCmd = `
+	INSERT INTO webhooks (url, event_types, secret)
+	VALUES ($1, $2, $3)
+	RETURNING id, url, event_types, secret, created_at;`
+
+	columns := []string{"id", "url", "event_types", "secret", "created_at"}
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]testCase{
+		"filtered events": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("https://example.com/hook", `["PersonCreated","PersonDeleted"]`, "0123456789abcdef").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "https://example.com/hook",
+						[]byte(`["PersonCreated", "PersonDeleted"]`), "0123456789abcdef", createdAt))
+			},
+			params: pWebhooks.CreateParams{
+				URL:        "https://example.com/hook",
+				EventTypes: []string{models.EventPersonCreated, models.EventPersonDeleted},
+				Secret:     "0123456789abcdef",
+			},
+			webhook: &models.Webhook{
+				ID:         2,
+				URL:        "https://example.com/hook",
+				EventTypes: []string{models.EventPersonCreated, models.EventPersonDeleted},
+				Secret:     "0123456789abcdef",
+				CreatedAt:  createdAt,
+			},
+			err: nil,
+		},
+		"all events": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("https://example.com/hook", `[]`, "0123456789abcdef").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "https://example.com/hook",
+						[]byte(`[]`), "0123456789abcdef", createdAt))
+			},
+			params: pWebhooks.CreateParams{
+				URL:    "https://example.com/hook",
+				Secret: "0123456789abcdef",
+			},
+			webhook: &models.Webhook{
+				ID:         2,
+				URL:        "https://example.com/hook",
+				EventTypes: []string{},
+				Secret:     "0123456789abcdef",
+				CreatedAt:  createdAt,
+			},
+			err: nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectQuery(regexp.QuoteMeta(createCmd)).
+					WithArgs("https://example.com/hook", `[]`, "0123456789abcdef").
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			params: pWebhooks.CreateParams{
+				URL:    "https://example.com/hook",
+				Secret: "0123456789abcdef",
+			},
+			webhook: nil,
+			err:     pkgErrors.ErrDb,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("can't create mock: %s", err)
+			}
+			defer db.Close()
+
+			repo := std.New(db, zap.NewNop())
+
+			f := fields{mock: mock}
+			if test.prepare != nil {
+				test.prepare(&f)
+			}
+
+			webhook, err := repo.Create(context.TODO(), &test.params)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if !reflect.DeepEqual(webhook, test.webhook) {
+				t.Errorf("\nExpected: %v\nGot: %v", test.webhook, webhook)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("\nThere were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		err     error
+	}
+
+	const deleteCmd = `
+	DELETE FROM webhooks
+	WHERE id = $1;`
+
+	tests := map[string]testCase{
+		"deleted": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(deleteCmd)).
+					WithArgs(2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			err: nil,
+		},
+		"webhook not found": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(deleteCmd)).
+					WithArgs(2).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			err: pkgErrors.ErrWebhookNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("can't create mock: %s", err)
+			}
+			defer db.Close()
+
+			repo := std.New(db, zap.NewNop())
+
+			f := fields{mock: mock}
+			if test.prepare != nil {
+				test.prepare(&f)
+			}
+
+			err = repo.Delete(context.TODO(), 2)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("\nThere were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare  func(f *fields)
+		enqueued int64
+		err      error
+	}
+
+	const enqueueCmd = `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+	SELECT id, $1, $2, $3
+	FROM webhooks
+	WHERE event_types = '[]'::jsonb OR event_types @> jsonb_build_array($2::text)
+	ON CONFLICT (webhook_id, event_id) DO NOTHING;`
+
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := models.PersonEvent{
+		ID:        40,
+		Type:      models.EventPersonUpdated,
+		PersonID:  3,
+		Payload:   []byte(`{"operation":"update"}`),
+		Attempts:  1,
+		CreatedAt: createdAt,
+	}
+	payload := `{"id":40,"type":"PersonUpdated","person_id":3,"payload":{"operation":"update"},"attempts":1,` +
+		`"created_at":"2024-03-01T12:00:00Z"}`
+
+	tests := map[string]testCase{
+		"subscribed webhooks": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(enqueueCmd)).
+					WithArgs(40, "PersonUpdated", payload).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			enqueued: 2,
+			err:      nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(enqueueCmd)).
+					WithArgs(40, "PersonUpdated", payload).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			enqueued: 0,
+			err:      pkgErrors.ErrDb,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("can't create mock: %s", err)
+			}
+			defer db.Close()
+
+			repo := std.New(db, zap.NewNop())
+
+			f := fields{mock: mock}
+			if test.prepare != nil {
+				test.prepare(&f)
+			}
+
+			enqueued, err := repo.Enqueue(context.TODO(), &event)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if enqueued != test.enqueued {
+				t.Errorf("\nExpected: %d\nGot: %d", test.enqueued, enqueued)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("\nThere were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestRecordAttempt(t *testing.T) {
+	type fields struct {
+		mock sqlmock.Sqlmock
+	}
+
+	type testCase struct {
+		prepare func(f *fields)
+		attempt pWebhooks.Attempt
+		err     error
+	}
+
+	const recordAttemptCmd = `
+	UPDATE webhook_deliveries
+	SET status = $1, next_attempt_at = now() + $2 * interval '1 millisecond', response_status = $3,
+		last_error = $4, delivered_at = CASE WHEN $1 = 'delivered' THEN now() END
+	WHERE id = $5;`
+
+	status := 503
+	reason := "receiver responded with status 503"
+
+	tests := map[string]testCase{
+		"retry": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(recordAttemptCmd)).
+					WithArgs("pending", 20000, 503, reason, 9).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			attempt: pWebhooks.Attempt{
+				DeliveryID:     9,
+				Status:         models.DeliveryPending,
+				RetryIn:        20 * time.Second,
+				ResponseStatus: &status,
+				Error:          &reason,
+			},
+			err: nil,
+		},
+		"query error": {
+			prepare: func(f *fields) {
+				f.mock.
+					ExpectExec(regexp.QuoteMeta(recordAttemptCmd)).
+					WithArgs("delivered", 0, nil, nil, 9).
+					WillReturnError(fmt.Errorf("db error"))
+			},
+			attempt: pWebhooks.Attempt{
+				DeliveryID: 9,
+				Status:     models.DeliveryDelivered,
+			},
+			err: pkgErrors.ErrDb,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("can't create mock: %s", err)
+			}
+			defer db.Close()
+
+			repo := std.New(db, zap.NewNop())
+
+			f := fields{mock: mock}
+			if test.prepare != nil {
+				test.prepare(&f)
+			}
+
+			err = repo.RecordAttempt(context.TODO(), &test.attempt)
+			if !errors.Is(err, test.err) {
+				t.Errorf("\nExpected: %s\nGot: %s", test.err, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("\nThere were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package std
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/config"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/constants"
+	pErrors "github.com/SlavaShagalov/ds-lab1/internal/pkg/errors"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/requestinfo"
+	postgres "github.com/SlavaShagalov/ds-lab1/internal/pkg/storages"
+	pWebhooks "github.com/SlavaShagalov/ds-lab1/internal/webhooks"
+)
+
+type timeouts struct {
+	create time.Duration
+	get    time.Duration
+	list   time.Duration
+	delete time.Duration
+	outbox time.Duration
+}
+
+type repository struct {
+	db       *sql.DB
+	log      *zap.Logger
+	timeouts timeouts
+}
+
+func New(db *sql.DB, log *zap.Logger) pWebhooks.Repository {
+	return &repository{
+		db:  db,
+		log: log,
+		timeouts: timeouts{
+			create: viper.GetDuration(config.PostgresCreateTimeout),
+			get:    viper.GetDuration(config.PostgresGetTimeout),
+			list:   viper.GetDuration(config.PostgresListTimeout),
+			delete: viper.GetDuration(config.PostgresDeleteTimeout),
+			outbox: viper.GetDuration(config.PostgresOutboxTimeout),
+		},
+	}
+}
+
+const createCmd = `
+	INSERT INTO webhooks (url, event_types, secret)
+	VALUES ($1, $2, $3)
+	RETURNING id, url, event_types, secret, created_at;`
+
+func (repo *repository) Create(ctx context.Context, params *pWebhooks.CreateParams) (*models.Webhook, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.create)
+	defer cancel()
+
+	eventTypes := params.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	eventTypesDoc, err := json.Marshal(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	row := repo.db.QueryRowContext(ctx, createCmd, params.URL, string(eventTypesDoc), params.Secret)
+	webhook, err := scanWebhook(row)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", createCmd))
+		return nil, postgres.QueryError(ctx, err)
+	}
+
+	repo.logger(ctx).Debug("New webhook created", zap.Int64("id", webhook.ID), zap.String("url", webhook.URL))
+	return webhook, nil
+}
+
+const getCmd = `
+	SELECT id, url, event_types, secret, created_at
+	FROM webhooks
+	WHERE id = $1;`
+
+func (repo *repository) Get(ctx context.Context, id int64) (*models.Webhook, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.get)
+	defer cancel()
+
+	webhook, err := scanWebhook(repo.db.QueryRowContext(ctx, getCmd, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.Wrap(pErrors.ErrWebhookNotFound, err.Error())
+		}
+
+		repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", getCmd),
+			zap.Int64("id", id))
+		return nil, postgres.QueryError(ctx, err)
+	}
+
+	return webhook, nil
+}
+
+const deleteCmd = `
+	DELETE FROM webhooks
+	WHERE id = $1;`
+
+func (repo *repository) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.delete)
+	defer cancel()
+
+	result, err := repo.db.ExecContext(ctx, deleteCmd, id)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", deleteCmd),
+			zap.Int64("id", id))
+		return postgres.QueryError(ctx, err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	if deleted == 0 {
+		return errors.Wrapf(pErrors.ErrWebhookNotFound, "webhook %d", id)
+	}
+
+	repo.logger(ctx).Debug("Webhook deleted", zap.Int64("id", id))
+	return nil
+}
+
+const deliveriesCmd = `
+	SELECT id, webhook_id, event_id, event_type, payload, status, attempts, response_status, last_error,
+		next_attempt_at, created_at, delivered_at
+	FROM webhook_deliveries
+	WHERE webhook_id = $1 AND id < $2
+	ORDER BY id DESC
+	LIMIT $3;`
+
+func (repo *repository) Deliveries(ctx context.Context, params *pWebhooks.DeliveriesParams) (
+	[]models.WebhookDelivery, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.list)
+	defer cancel()
+
+	beforeID := params.BeforeID
+	if beforeID == 0 {
+		beforeID = math.MaxInt64
+	}
+	var limit any
+	if params.Limit != 0 {
+		limit = params.Limit
+	}
+
+	rows, err := repo.db.QueryContext(ctx, deliveriesCmd, params.WebhookID, beforeID, limit)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", deliveriesCmd))
+		return nil, postgres.QueryError(ctx, err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err = scanDelivery(rows, &delivery)
+		if err != nil {
+			repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", deliveriesCmd))
+			return nil, postgres.QueryError(ctx, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", deliveriesCmd))
+		return nil, postgres.QueryError(ctx, err)
+	}
+
+	return deliveries, nil
+}
+
+const enqueueCmd = `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+	SELECT id, $1, $2, $3
+	FROM webhooks
+	WHERE event_types = '[]'::jsonb OR event_types @> jsonb_build_array($2::text)
+	ON CONFLICT (webhook_id, event_id) DO NOTHING;`
+
+func (repo *repository) Enqueue(ctx context.Context, event *models.PersonEvent) (int64, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := repo.db.ExecContext(ctx, enqueueCmd, event.ID, event.Type, string(payload))
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", enqueueCmd))
+		return 0, postgres.QueryError(ctx, err)
+	}
+
+	enqueued, _ := result.RowsAffected()
+	return enqueued, nil
+}
+
+const claimDeliveriesCmd = `
+	UPDATE webhook_deliveries d
+	SET attempts = d.attempts + 1, next_attempt_at = now() + $1 * interval '1 millisecond'
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT id
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.response_status,
+		d.last_error, d.next_attempt_at, d.created_at, d.delivered_at, w.url, w.secret;`
+
+func (repo *repository) ClaimDeliveries(ctx context.Context, limit int64, lease time.Duration) (
+	[]pWebhooks.PendingDelivery, error) {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, claimDeliveriesCmd, lease.Milliseconds(), limit)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", claimDeliveriesCmd))
+		return nil, postgres.QueryError(ctx, err)
+	}
+	defer rows.Close()
+
+	deliveries := []pWebhooks.PendingDelivery{}
+	for rows.Next() {
+		var delivery pWebhooks.PendingDelivery
+		err = scanDelivery(rows, &delivery.WebhookDelivery, &delivery.URL, &delivery.Secret)
+		if err != nil {
+			repo.logger(ctx).Error(constants.DBScanError, zap.Error(err), zap.String("sql_query", claimDeliveriesCmd))
+			return nil, postgres.QueryError(ctx, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", claimDeliveriesCmd))
+		return nil, postgres.QueryError(ctx, err)
+	}
+
+	// The order of RETURNING rows is not guaranteed, while deliveries are sent in the order events happened.
+	slices.SortFunc(deliveries, func(a, b pWebhooks.PendingDelivery) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return deliveries, nil
+}
+
+const recordAttemptCmd = `
+	UPDATE webhook_deliveries
+	SET status = $1, next_attempt_at = now() + $2 * interval '1 millisecond', response_status = $3,
+		last_error = $4, delivered_at = CASE WHEN $1 = 'delivered' THEN now() END
+	WHERE id = $5;`
+
+func (repo *repository) RecordAttempt(ctx context.Context, attempt *pWebhooks.Attempt) error {
+	ctx, cancel := postgres.WithTimeout(ctx, repo.timeouts.outbox)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, recordAttemptCmd,
+		attempt.Status,
+		attempt.RetryIn.Milliseconds(),
+		attempt.ResponseStatus,
+		attempt.Error,
+		attempt.DeliveryID,
+	)
+	if err != nil {
+		repo.logger(ctx).Error(constants.DBError, zap.Error(err), zap.String("sql_query", recordAttemptCmd))
+		return postgres.QueryError(ctx, err)
+	}
+	return nil
+}
+
+func scanWebhook(row *sql.Row) (*models.Webhook, error) {
+	webhook := new(models.Webhook)
+	var eventTypes []byte
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&eventTypes,
+		&webhook.Secret,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(eventTypes, &webhook.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// scanDelivery reads the delivery columns followed by extra ones.
+func scanDelivery(rows *sql.Rows, delivery *models.WebhookDelivery, extra ...any) error {
+	var payload []byte
+	dest := []any{
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+	}
+	err := rows.Scan(append(dest, extra...)...)
+	delivery.Payload = payload
+	return err
+}
+
+// logger returns the logger of the request that ctx belongs to.
+func (repo *repository) logger(ctx context.Context) *zap.Logger {
+	return requestinfo.Logger(ctx, repo.log)
+}
@@ -0,0 +1,59 @@
+package webhooks
+
+import (
+	"net/url"
+	"slices"
+
+	"github.com/SlavaShagalov/ds-lab1/internal/models"
+	"github.com/SlavaShagalov/ds-lab1/internal/pkg/validation"
+)
+
+const (
+	URLMaxLength    = 2048
+	SecretMinLength = 16
+	SecretMaxLength = 256
+)
+
+// EventTypes lists the events webhooks can subscribe to.
+var EventTypes = []string{
+	models.EventPersonCreated,
+	models.EventPersonUpdated,
+	models.EventPersonDeleted,
+	models.EventPersonRestored,
+}
+
+func (params *CreateParams) Validate() error {
+	return validation.Validate(
+		validation.Field("url", params.URL, validation.Required(), validation.MaxLength(URLMaxLength), httpURL()),
+		validation.Field("event_types", params.EventTypes, knownEventTypes()),
+		validation.Field("secret", params.Secret, validation.Required(),
+			validation.MinLength(SecretMinLength), validation.MaxLength(SecretMaxLength)),
+	)
+}
+
+// httpURL accepts absolute http and https URLs.
+func httpURL() validation.Rule {
+	return func(value any) string {
+		v, ok := value.(string)
+		if !ok {
+			return ""
+		}
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+		return ""
+	}
+}
+
+func knownEventTypes() validation.Rule {
+	return func(value any) string {
+		types, _ := value.([]string)
+		for _, eventType := range types {
+			if !slices.Contains(EventTypes, eventType) {
+				return "unknown event type " + eventType
+			}
+		}
+		return ""
+	}
+}